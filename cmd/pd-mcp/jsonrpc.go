@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// maxMessageSize bounds a single line of the stdio transport
+const maxMessageSize = 16 << 20
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// isNotification reports whether the sender expects no response
+func (r *request) isNotification() bool {
+	return len(r.ID) == 0 || bytes.Equal(r.ID, []byte("null"))
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+func invalidParams(format string, args ...any) *rpcError {
+	return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// handlerFunc answers a request, returning an *rpcError sends that error and any
+// other error is reported as an internal error
+type handlerFunc func(ctx context.Context, params json.RawMessage) (any, error)
+
+// cancelledMethod is the notification cancelling an in-flight request
+const cancelledMethod = "notifications/cancelled"
+
+// serve reads newline delimited JSON-RPC messages from r and writes the
+// responses to w until r is exhausted or ctx is done. Requests are handled
+// concurrently, each with its own context, notifications in the order they
+// arrive. A notifications/cancelled message cancels the context of the request
+// it names, which is then not answered
+func serve(ctx context.Context, r io.Reader, w io.Writer, handlers map[string]handlerFunc) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	var mu sync.Mutex
+	var writeErr error
+	failed := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return writeErr
+	}
+	enc := json.NewEncoder(w)
+	write := func(resp response) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if writeErr != nil {
+			return writeErr
+		}
+		resp.JSONRPC = "2.0"
+		if resp.ID == nil {
+			resp.ID = json.RawMessage("null")
+		}
+		writeErr = enc.Encode(resp)
+		return writeErr
+	}
+
+	var wg sync.WaitGroup
+	// the responses of the requests still running are written before returning
+	defer wg.Wait()
+
+	var inflightMu sync.Mutex
+	inflight := map[string]context.CancelFunc{}
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// a response of a request handled in the background failed
+		if err := failed(); err != nil {
+			return err
+		}
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			if err := write(response{Error: &rpcError{Code: codeParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "" {
+			// responses to requests we never send
+			continue
+		}
+		if req.JSONRPC != "2.0" {
+			if !req.isNotification() {
+				err := write(response{ID: req.ID, Error: &rpcError{Code: codeInvalidRequest, Message: "jsonrpc must be 2.0"}})
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		handler, ok := handlers[req.Method]
+		if req.isNotification() {
+			if req.Method == cancelledMethod {
+				var p struct {
+					RequestID json.RawMessage `json:"requestId"`
+				}
+				if decodeParams(req.Params, &p) == nil {
+					inflightMu.Lock()
+					if cancel, ok := inflight[requestKey(p.RequestID)]; ok {
+						cancel()
+					}
+					inflightMu.Unlock()
+				}
+			} else if ok {
+				_, _ = handler(ctx, req.Params)
+			}
+			continue
+		}
+		if !ok {
+			err := write(response{ID: req.ID, Error: &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := requestKey(req.ID)
+		reqCtx, cancel := context.WithCancel(ctx)
+		inflightMu.Lock()
+		inflight[key] = cancel
+		inflightMu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := handler(reqCtx, req.Params)
+
+			inflightMu.Lock()
+			delete(inflight, key)
+			inflightMu.Unlock()
+			cancelled := reqCtx.Err() != nil && ctx.Err() == nil
+			cancel()
+			if cancelled {
+				return
+			}
+			_ = write(reply(req.ID, result, err))
+		}()
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	wg.Wait()
+	return failed()
+}
+
+// reply builds the response to a handled request
+func reply(id json.RawMessage, result any, err error) response {
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		return response{ID: id, Error: rpcErr}
+	}
+	if result == nil {
+		result = struct{}{}
+	}
+	return response{ID: id, Result: result}
+}
+
+// requestKey identifies a request by its ID, which is a number or a string
+func requestKey(id json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, id); err != nil {
+		return string(id)
+	}
+	return b.String()
+}
+
+// decodeParams unmarshals params into v, absent params leave v untouched
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams("decoding params: %v", err)
+	}
+	return nil
+}
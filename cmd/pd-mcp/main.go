@@ -0,0 +1,64 @@
+// Command pd-mcp is a Model Context Protocol server on stdio exposing the
+// Connect actions of an allowlist of apps as tools of one end user.
+//
+// Credentials are read from PIPEDREAM_CLIENT_ID, PIPEDREAM_CLIENT_SECRET,
+// PIPEDREAM_PROJECT_ID and PIPEDREAM_ENVIRONMENT:
+//
+//	pd-mcp -user jverce -apps slack,google_sheets
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+)
+
+func main() {
+	// stdout carries the protocol, logs go to stderr
+	log.SetFlags(0)
+	log.SetPrefix("pd-mcp: ")
+
+	externalUserID := flag.String("user", "", "external user ID whose accounts are used")
+	apps := flag.String("apps", "", "comma separated app slugs whose actions are exposed")
+	flag.Parse()
+
+	if *externalUserID == "" || *apps == "" {
+		log.Fatal("-user and -apps are required")
+	}
+
+	base := client.NewClient(
+		"",
+		os.Getenv("PIPEDREAM_PROJECT_ID"),
+		os.Getenv("PIPEDREAM_ENVIRONMENT"),
+		os.Getenv("PIPEDREAM_CLIENT_ID"),
+		os.Getenv("PIPEDREAM_CLIENT_SECRET"),
+		nil,
+		os.Getenv("PIPEDREAM_CONNECT_URL"),
+		os.Getenv("PIPEDREAM_REST_URL"))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s := newServer(&connect.Client{Client: base}, *externalUserID, splitApps(*apps))
+	err := serve(ctx, os.Stdin, os.Stdout, s.handlers())
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
+
+func splitApps(apps string) []string {
+	var out []string
+	for _, app := range strings.Split(apps, ",") {
+		if app = strings.TrimSpace(app); app != "" {
+			out = append(out, app)
+		}
+	}
+	return out
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+	"github.com/cloudsquid/pipedream-go-sdk/connect/tools"
+)
+
+const latestProtocolVersion = "2025-06-18"
+
+var supportedProtocolVersions = []string{latestProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// maxCompletionValues is the limit of the MCP completion result
+const maxCompletionValues = 100
+
+// server exposes the actions of the allowed apps to one external user. Every
+// action is a tool, and a prompt whose arguments complete with the prop options
+type server struct {
+	client         *connect.Client
+	externalUserID string
+	apps           []string
+	version        string
+
+	mu         sync.Mutex
+	loaded     bool
+	dispatcher *tools.Dispatcher
+	components map[string]*connect.ComponentDetails // by tool name
+}
+
+func newServer(client *connect.Client, externalUserID string, apps []string) *server {
+	return &server{
+		client:         client,
+		externalUserID: externalUserID,
+		apps:           apps,
+		version:        "dev",
+	}
+}
+
+func (s *server) handlers() map[string]handlerFunc {
+	return map[string]handlerFunc{
+		"initialize":                s.initialize,
+		"notifications/initialized": noop,
+		"ping":                      noop,
+		"tools/list":                s.listTools,
+		"tools/call":                s.callTool,
+		"prompts/list":              s.listPrompts,
+		"prompts/get":               s.getPrompt,
+		"completion/complete":       s.complete,
+	}
+}
+
+func noop(context.Context, json.RawMessage) (any, error) {
+	return struct{}{}, nil
+}
+
+func (s *server) initialize(_ context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	version := latestProtocolVersion
+	if slices.Contains(supportedProtocolVersions, p.ProtocolVersion) {
+		version = p.ProtocolVersion
+	}
+
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities": map[string]any{
+			"tools":       map[string]any{"listChanged": false},
+			"prompts":     map[string]any{"listChanged": false},
+			"completions": map[string]any{},
+		},
+		"serverInfo": map[string]any{
+			"name":    "pd-mcp",
+			"version": s.version,
+		},
+		"instructions": fmt.Sprintf(
+			"Tools run Pipedream actions of the apps %s with the accounts connected by the user.",
+			strings.Join(s.apps, ", ")),
+	}, nil
+}
+
+// load lists the actions of the allowed apps once, failures are retried on the
+// next call. The actions are fetched without holding the lock, so concurrent
+// first calls may fetch them more than once
+func (s *server) load(ctx context.Context) (*tools.Dispatcher, error) {
+	s.mu.Lock()
+	if s.loaded {
+		defer s.mu.Unlock()
+		return s.dispatcher, nil
+	}
+	s.mu.Unlock()
+
+	var loaded []*tools.Tool
+	components := map[string]*connect.ComponentDetails{}
+	for _, app := range s.apps {
+		listed, err := s.client.ListAllComponents(ctx, connect.Actions, app, "")
+		if err != nil {
+			return nil, fmt.Errorf("listing actions of %s: %w", app, err)
+		}
+
+		for _, component := range listed {
+			// the allowlist holds even if the API returns actions of other apps
+			if appSlug, _, _ := strings.Cut(component.Key, "-"); appSlug != app {
+				continue
+			}
+
+			details, err := s.client.GetComponent(ctx, component.Key, connect.Actions)
+			if err != nil {
+				return nil, fmt.Errorf("fetching action %s: %w", component.Key, err)
+			}
+			if details.Data == nil {
+				continue
+			}
+
+			tool, err := tools.FromComponent(details.Data)
+			if err != nil {
+				return nil, err
+			}
+			components[tool.Name] = details.Data
+			loaded = append(loaded, tool)
+		}
+	}
+
+	dispatcher, err := tools.NewDispatcher(s.client, loaded...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded {
+		return s.dispatcher, nil
+	}
+	s.dispatcher = dispatcher
+	s.components = components
+	s.loaded = true
+	return s.dispatcher, nil
+}
+
+type mcpTool struct {
+	Name        string              `json:"name"`
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	InputSchema *connect.JSONSchema `json:"inputSchema"`
+}
+
+func (s *server) listTools(ctx context.Context, _ json.RawMessage) (any, error) {
+	dispatcher, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []mcpTool{}
+	for _, tool := range dispatcher.Tools() {
+		list = append(list, mcpTool{
+			Name:        tool.Name,
+			Title:       s.components[tool.Name].Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
+	return map[string]any{"tools": list}, nil
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (s *server) callTool(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	dispatcher, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := dispatcher.Tool(p.Name); !ok {
+		return nil, invalidParams("unknown tool: %s", p.Name)
+	}
+
+	// failures of the action are tool results the model can react to
+	result, err := dispatcher.Dispatch(ctx, s.externalUserID, p.Name, p.Arguments)
+	if err != nil {
+		return map[string]any{
+			"content": []textContent{{Type: "text", Text: err.Error()}},
+			"isError": true,
+		}, nil
+	}
+
+	text, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("encoding result of %s: %w", p.Name, err)
+	}
+	return map[string]any{
+		"content":           []textContent{{Type: "text", Text: string(text)}},
+		"structuredContent": result,
+		"isError":           false,
+	}, nil
+}
+
+type promptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type prompt struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []promptArgument `json:"arguments,omitempty"`
+}
+
+func (s *server) listPrompts(ctx context.Context, _ json.RawMessage) (any, error) {
+	dispatcher, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []prompt{}
+	for _, tool := range dispatcher.Tools() {
+		p := prompt{
+			Name:        tool.Name,
+			Title:       s.components[tool.Name].Name,
+			Description: tool.Description,
+		}
+		for _, name := range slices.Sorted(maps.Keys(tool.InputSchema.Properties)) {
+			property := tool.InputSchema.Properties[name]
+			p.Arguments = append(p.Arguments, promptArgument{
+				Name:        name,
+				Description: cmp.Or(property.Description, property.Title),
+				Required:    slices.Contains(tool.InputSchema.Required, name),
+			})
+		}
+		list = append(list, p)
+	}
+	return map[string]any{"prompts": list}, nil
+}
+
+func (s *server) getPrompt(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	dispatcher, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tool, ok := dispatcher.Tool(p.Name)
+	if !ok {
+		return nil, invalidParams("unknown prompt: %s", p.Name)
+	}
+
+	text := fmt.Sprintf("Use the %s tool.", tool.Name)
+	if len(p.Arguments) > 0 {
+		args, err := json.Marshal(p.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		text = fmt.Sprintf("Use the %s tool with the arguments %s.", tool.Name, args)
+	}
+
+	return map[string]any{
+		"description": tool.Description,
+		"messages": []map[string]any{
+			{"role": "user", "content": textContent{Type: "text", Text: text}},
+		},
+	}, nil
+}
+
+func (s *server) complete(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		Ref struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		} `json:"ref"`
+		Argument struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"argument"`
+		Context struct {
+			Arguments map[string]string `json:"arguments"`
+		} `json:"context"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Ref.Type != "ref/prompt" {
+		return completion(nil), nil
+	}
+
+	dispatcher, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tool, ok := dispatcher.Tool(p.Ref.Name)
+	if !ok {
+		return nil, invalidParams("unknown prompt: %s", p.Ref.Name)
+	}
+	if _, ok := tool.InputSchema.Properties[p.Argument.Name]; !ok {
+		return completion(nil), nil
+	}
+
+	var prop *connect.ConfigurableProp
+	for _, candidate := range s.components[tool.Name].ConfigurableProps {
+		if candidate != nil && candidate.Name == p.Argument.Name {
+			prop = candidate
+		}
+	}
+	if prop == nil {
+		return completion(nil), nil
+	}
+
+	options, err := s.propOptions(ctx, dispatcher, tool, prop, p.Argument.Value, p.Context.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	if prop.UseQuery {
+		return completion(options), nil
+	}
+	return completion(filterOptions(options, p.Argument.Value)), nil
+}
+
+// propOptions returns the static options of prop or fetches its remote options
+// with the end user's accounts and the arguments filled in so far
+func (s *server) propOptions(
+	ctx context.Context,
+	dispatcher *tools.Dispatcher,
+	tool *tools.Tool,
+	prop *connect.ConfigurableProp,
+	value string,
+	arguments map[string]string,
+) ([]connect.Value, error) {
+	if prop.RemoteOptions == nil || !*prop.RemoteOptions {
+		var options []connect.Value
+		for _, option := range prop.Options {
+			switch o := option.(type) {
+			case map[string]any:
+				label, _ := o["label"].(string)
+				options = append(options, connect.Value{Label: label, Value: o["value"]})
+			default:
+				options = append(options, connect.Value{Label: fmt.Sprint(o), Value: o})
+			}
+		}
+		return options, nil
+	}
+
+	configured, err := dispatcher.AuthProps(ctx, s.externalUserID, tool)
+	if errors.Is(err, tools.NoHealthyAccountErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for name, argument := range arguments {
+		if name != prop.Name {
+			configured[name] = argument
+		}
+	}
+
+	request := connect.ConfigurePropRequest{
+		ExternalUserID:  s.externalUserID,
+		ComponentKey:    tool.ComponentKey,
+		PropName:        prop.Name,
+		ConfiguredProps: configured,
+	}
+	if prop.UseQuery {
+		request.Query = value
+	}
+
+	options, err := s.client.ConfigureProp(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching options of %s: %w", prop.Name, err)
+	}
+	return options.Options, nil
+}
+
+func filterOptions(options []connect.Value, value string) []connect.Value {
+	value = strings.ToLower(value)
+	var filtered []connect.Value
+	for _, option := range options {
+		if strings.Contains(strings.ToLower(fmt.Sprint(option.Value)), value) ||
+			strings.Contains(strings.ToLower(option.Label), value) {
+			filtered = append(filtered, option)
+		}
+	}
+	return filtered
+}
+
+// completion converts options into the completion result, values are the
+// option values as strings
+func completion(options []connect.Value) map[string]any {
+	values := []string{}
+	for _, option := range options {
+		values = append(values, fmt.Sprint(option.Value))
+	}
+
+	total := len(values)
+	if len(values) > maxCompletionValues {
+		values = values[:maxCompletionValues]
+	}
+	return map[string]any{
+		"completion": map[string]any{
+			"values":  values,
+			"total":   total,
+			"hasMore": total > len(values),
+		},
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+	"github.com/stretchr/testify/suite"
+)
+
+const oathPath = "/oauth/token"
+
+type serverTestSuite struct {
+	suite.Suite
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stdin    *io.PipeWriter
+	stdout   *bufio.Reader
+	done     chan error
+	nextID   int
+	invoked  []connect.InvokeActionRequest
+	configs  []connect.ConfigurePropRequest
+	getCalls int
+}
+
+func (suite *serverTestSuite) SetupTest() {
+	suite.ctx, suite.cancel = context.WithCancel(context.Background())
+	suite.invoked = nil
+	suite.configs = nil
+	suite.getCalls = 0
+	suite.nextID = 0
+
+	remote := true
+	components := map[string]*connect.ComponentDetails{
+		"slack-send-message": {
+			Component: connect.Component{Key: "slack-send-message", Name: "Send Message", Description: "Send a message"},
+			ConfigurableProps: []*connect.ConfigurableProp{
+				{Name: "slack", Type: connect.PropTypeApp, App: "slack"},
+				{Name: "channel", Type: connect.PropTypeString, Label: "Channel", RemoteOptions: &remote},
+				{Name: "text", Type: connect.PropTypeString, Label: "Text"},
+				{Name: "parse", Type: connect.PropTypeString, Optional: true, Options: []any{"full", "none"}},
+			},
+		},
+		"slack-list-users": {
+			Component: connect.Component{Key: "slack-list-users", Name: "List Users"},
+			ConfigurableProps: []*connect.ConfigurableProp{
+				{Name: "slack", Type: connect.PropTypeApp, App: "slack"},
+			},
+		},
+	}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require := suite.Require()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+		case r.URL.Path == "/project-abc/actions":
+			require.Equal("slack", r.URL.Query().Get("app"))
+			// an action outside the allowlist must not become a tool
+			_, _ = fmt.Fprint(w, `{"data": [
+				{"key": "slack-send-message"},
+				{"key": "slack-list-users"},
+				{"key": "jira-create-issue"}
+			]}`)
+		case r.URL.Path == "/project-abc/accounts":
+			require.Equal("jverce", r.URL.Query().Get("external_user_id"))
+			_, _ = fmt.Fprint(w, `{"data": [{"id": "apn_1", "healthy": true}]}`)
+		case r.URL.Path == "/project-abc/components/configure":
+			var request connect.ConfigurePropRequest
+			require.NoError(json.NewDecoder(r.Body).Decode(&request))
+			suite.configs = append(suite.configs, request)
+			_, _ = fmt.Fprint(w, `{"options": [
+				{"label": "#general", "value": "C1"},
+				{"label": "#random", "value": "C2"}
+			]}`)
+		case r.URL.Path == "/project-abc/actions/run":
+			var request connect.InvokeActionRequest
+			require.NoError(json.NewDecoder(r.Body).Decode(&request))
+			suite.invoked = append(suite.invoked, request)
+			_, _ = fmt.Fprint(w, `{"exports": {"$summary": "Sent"}, "ret": {"ts": "1.2"}}`)
+		default:
+			suite.getCalls++
+			for key, component := range components {
+				if r.URL.Path == "/project-abc/actions/"+key {
+					_ = json.NewEncoder(w).Encode(connect.GetComponentResponse{Data: component})
+					return
+				}
+			}
+			http.NotFound(w, r)
+		}
+	}))
+	suite.T().Cleanup(api.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, api.URL, api.URL)
+	s := newServer(&connect.Client{Client: base}, "jverce", []string{"slack"})
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	suite.stdin = stdinWriter
+	suite.stdout = bufio.NewReader(stdoutReader)
+	suite.done = make(chan error, 1)
+	go func() {
+		err := serve(suite.ctx, stdinReader, stdoutWriter, s.handlers())
+		_ = stdoutWriter.Close()
+		suite.done <- err
+	}()
+}
+
+func (suite *serverTestSuite) TearDownTest() {
+	_ = suite.stdin.Close()
+	suite.Require().NoError(<-suite.done)
+	suite.cancel()
+}
+
+// send writes a raw line and returns the decoded response line
+func (suite *serverTestSuite) send(line string) map[string]any {
+	_, err := io.WriteString(suite.stdin, line+"\n")
+	suite.Require().NoError(err)
+
+	out, err := suite.stdout.ReadBytes('\n')
+	suite.Require().NoError(err)
+
+	var resp map[string]any
+	suite.Require().NoError(json.Unmarshal(out, &resp))
+	suite.Require().Equal("2.0", resp["jsonrpc"])
+	return resp
+}
+
+func (suite *serverTestSuite) call(method string, params any) map[string]any {
+	suite.nextID++
+	bs, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      suite.nextID,
+		"method":  method,
+		"params":  params,
+	})
+	suite.Require().NoError(err)
+
+	resp := suite.send(string(bs))
+	suite.Require().EqualValues(suite.nextID, resp["id"])
+	return resp
+}
+
+func (suite *serverTestSuite) result(method string, params any) map[string]any {
+	resp := suite.call(method, params)
+	suite.Require().Nil(resp["error"], "%v", resp["error"])
+	return resp["result"].(map[string]any)
+}
+
+func (suite *serverTestSuite) notify(method string) {
+	_, err := fmt.Fprintf(suite.stdin, `{"jsonrpc": "2.0", "method": %q}`+"\n", method)
+	suite.Require().NoError(err)
+}
+
+func (suite *serverTestSuite) TestSession() {
+	require := suite.Require()
+
+	initialized := suite.result("initialize", map[string]any{
+		"protocolVersion": "2025-03-26",
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "test", "version": "1"},
+	})
+	require.Equal("2025-03-26", initialized["protocolVersion"])
+	require.Contains(initialized["capabilities"], "tools")
+	require.Contains(initialized["capabilities"], "completions")
+	suite.notify("notifications/initialized")
+
+	require.Empty(suite.result("ping", nil))
+
+	listed := suite.result("tools/list", map[string]any{})
+	tools := listed["tools"].([]any)
+	require.Len(tools, 2)
+	sendMessage := tools[1].(map[string]any)
+	require.Equal("slack-send-message", sendMessage["name"])
+	require.Equal("Send Message", sendMessage["title"])
+	schema := sendMessage["inputSchema"].(map[string]any)
+	require.Equal([]any{"channel", "text"}, schema["required"])
+	require.NotContains(schema["properties"], "slack")
+
+	// the component details are fetched once
+	suite.result("tools/list", nil)
+	require.Equal(2, suite.getCalls)
+
+	called := suite.result("tools/call", map[string]any{
+		"name":      "slack-send-message",
+		"arguments": map[string]any{"channel": "C1", "text": "hi"},
+	})
+	require.Equal(false, called["isError"])
+	require.Equal(map[string]any{"ts": "1.2"}, called["structuredContent"].(map[string]any)["ret"])
+	require.Len(suite.invoked, 1)
+	require.Equal(map[string]any{"authProvisionId": "apn_1"}, suite.invoked[0].ConfiguredProps["slack"])
+
+	failed := suite.result("tools/call", map[string]any{
+		"name":      "slack-send-message",
+		"arguments": map[string]any{"channel": "C1"},
+	})
+	require.Equal(true, failed["isError"])
+	require.Contains(failed["content"].([]any)[0].(map[string]any)["text"], "text: is required")
+
+	unknown := suite.call("tools/call", map[string]any{"name": "jira-create-issue"})
+	require.EqualValues(codeInvalidParams, unknown["error"].(map[string]any)["code"])
+}
+
+func (suite *serverTestSuite) TestCompletion() {
+	require := suite.Require()
+
+	prompts := suite.result("prompts/list", nil)["prompts"].([]any)
+	require.Len(prompts, 2)
+	require.Equal([]any{
+		map[string]any{"name": "channel", "description": "Channel", "required": true},
+		map[string]any{"name": "parse"},
+		map[string]any{"name": "text", "description": "Text", "required": true},
+	}, prompts[1].(map[string]any)["arguments"])
+
+	remote := suite.result("completion/complete", map[string]any{
+		"ref":      map[string]any{"type": "ref/prompt", "name": "slack-send-message"},
+		"argument": map[string]any{"name": "channel", "value": "rand"},
+		"context":  map[string]any{"arguments": map[string]any{"text": "hi"}},
+	})
+	require.Equal(map[string]any{
+		"values":  []any{"C2"},
+		"total":   float64(1),
+		"hasMore": false,
+	}, remote["completion"])
+	require.Len(suite.configs, 1)
+	require.Equal("channel", suite.configs[0].PropName)
+	require.Equal("slack-send-message", suite.configs[0].ComponentKey)
+	require.Equal(connect.ConfiguredProps{
+		"slack": map[string]any{"authProvisionId": "apn_1"},
+		"text":  "hi",
+	}, suite.configs[0].ConfiguredProps)
+
+	static := suite.result("completion/complete", map[string]any{
+		"ref":      map[string]any{"type": "ref/prompt", "name": "slack-send-message"},
+		"argument": map[string]any{"name": "parse", "value": "f"},
+	})
+	require.Equal([]any{"full"}, static["completion"].(map[string]any)["values"])
+
+	prompt := suite.result("prompts/get", map[string]any{
+		"name":      "slack-send-message",
+		"arguments": map[string]any{"channel": "C1"},
+	})
+	message := prompt["messages"].([]any)[0].(map[string]any)
+	require.Equal("user", message["role"])
+	require.Contains(message["content"].(map[string]any)["text"], `{"channel":"C1"}`)
+}
+
+func (suite *serverTestSuite) TestProtocolErrors() {
+	require := suite.Require()
+
+	parse := suite.send(`{not json`)
+	require.Nil(parse["id"])
+	require.EqualValues(codeParseError, parse["error"].(map[string]any)["code"])
+
+	missing := suite.call("resources/list", nil)
+	require.EqualValues(codeMethodNotFound, missing["error"].(map[string]any)["code"])
+
+	// unsupported versions are answered with the latest one
+	initialized := suite.result("initialize", map[string]any{"protocolVersion": "1999-01-01"})
+	require.Equal(latestProtocolVersion, initialized["protocolVersion"])
+}
+
+func (suite *serverTestSuite) TestServeCancellation() {
+	require := suite.Require()
+
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	handlers := map[string]handlerFunc{
+		"ping": noop,
+		"slow": func(ctx context.Context, _ json.RawMessage) (any, error) {
+			close(started)
+			<-ctx.Done()
+			stopped <- ctx.Err()
+			return nil, ctx.Err()
+		},
+	}
+
+	stdinReader, stdin := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	stdout := bufio.NewReader(stdoutReader)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(suite.ctx, stdinReader, stdoutWriter, handlers)
+		_ = stdoutWriter.Close()
+	}()
+
+	_, err := io.WriteString(stdin, `{"jsonrpc": "2.0", "id": "call-1", "method": "slow"}`+"\n")
+	require.NoError(err)
+	<-started
+
+	// a long request does not hold up the others
+	_, err = io.WriteString(stdin, `{"jsonrpc": "2.0", "id": 2, "method": "ping"}`+"\n")
+	require.NoError(err)
+	line, err := stdout.ReadBytes('\n')
+	require.NoError(err)
+	require.JSONEq(`{"jsonrpc": "2.0", "id": 2, "result": {}}`, string(line))
+
+	_, err = io.WriteString(stdin,
+		`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": "call-1"}}`+"\n")
+	require.NoError(err)
+	require.ErrorIs(<-stopped, context.Canceled)
+
+	// the cancelled request is not answered
+	require.NoError(stdin.Close())
+	require.NoError(<-done)
+	_, err = stdout.ReadBytes('\n')
+	require.ErrorIs(err, io.EOF)
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(serverTestSuite))
+}
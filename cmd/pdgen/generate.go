@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/format"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+)
+
+const sdkImport = "github.com/cloudsquid/pipedream-go-sdk/connect"
+
+// component is a ComponentDetails with the type it was fetched as
+type component struct {
+	*connect.ComponentDetails
+	Type connect.ComponentType `json:"component_type"`
+}
+
+// field is a struct field generated for a configurable prop
+type field struct {
+	prop     *connect.ConfigurableProp
+	name     string
+	goType   string
+	optional bool
+}
+
+// generate renders the Go source for components. The output only depends on the
+// components, which are sorted by key, so it can be checked in
+func generate(pkg string, components []component) ([]byte, error) {
+	components = slices.Clone(components)
+	slices.SortFunc(components, func(a, b component) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by pdgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import (\n\t\"context\"\n\n\t%q\n)\n", sdkImport)
+
+	names := map[string]string{}
+	for _, c := range components {
+		if c.ComponentDetails == nil || c.Key == "" {
+			return nil, fmt.Errorf("component without key")
+		}
+		name := goName(c.Key)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("components %s and %s both map to %s", other, c.Key, name)
+		}
+		names[name] = c.Key
+
+		writeComponent(&buf, name, c)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+func writeComponent(buf *bytes.Buffer, name string, c component) {
+	kind := "action"
+	if c.Type == connect.Triggers {
+		kind = "trigger"
+	}
+	fields := componentFields(c.ConfigurableProps)
+
+	fmt.Fprintf(buf, "\n// %sKey is the key of the %s %s", name, cmp.Or(c.Name, c.Key), kind)
+	if c.Version != "" {
+		fmt.Fprintf(buf, ", generated from version %s", c.Version)
+	}
+	fmt.Fprintf(buf, "\nconst %sKey = %q\n", name, c.Key)
+
+	fmt.Fprintf(buf, "\n// %s holds the props of %s\n", name, c.Key)
+	if c.Description != "" {
+		fmt.Fprintf(buf, "//\n")
+		writeComment(buf, "", c.Description)
+	}
+	fmt.Fprintf(buf, "type %s struct {\n", name)
+	for _, f := range fields {
+		writeFieldComment(buf, f)
+		fmt.Fprintf(buf, "\t%s %s\n", f.name, f.goType)
+	}
+	fmt.Fprintf(buf, "}\n")
+
+	fmt.Fprintf(buf, "\n// ToConfiguredProps converts the props, unset optional props are left out\n")
+	fmt.Fprintf(buf, "func (p %s) ToConfiguredProps() connect.ConfiguredProps {\n", name)
+	fmt.Fprintf(buf, "\tprops := connect.ConfiguredProps{}\n")
+	for _, f := range fields {
+		value := "p." + f.name
+		if f.optional && strings.HasPrefix(f.goType, "*") {
+			value = "*p." + f.name
+		}
+		if f.prop.Type == connect.PropTypeApp {
+			value = fmt.Sprintf("map[string]string{\"authProvisionId\": %s}", value)
+		}
+
+		if f.optional {
+			fmt.Fprintf(buf, "\tif p.%s != nil {\n\t\tprops[%q] = %s\n\t}\n", f.name, f.prop.Name, value)
+		} else {
+			fmt.Fprintf(buf, "\tprops[%q] = %s\n", f.prop.Name, value)
+		}
+	}
+	fmt.Fprintf(buf, "\treturn props\n}\n")
+
+	if c.Type == connect.Triggers {
+		fmt.Fprintf(buf, "\n// Deploy%s deploys %s for externalUserID, webhookURL is optional\n", name, c.Key)
+		fmt.Fprintf(buf, "func Deploy%s(ctx context.Context, client *connect.Client, externalUserID string, props %s, webhookURL string) (*connect.Trigger, error) {\n", name, name)
+		fmt.Fprintf(buf, "\treturn client.DeployTrigger(ctx, %sKey, externalUserID, props.ToConfiguredProps(), webhookURL, \"\", \"\")\n}\n", name)
+		return
+	}
+
+	fmt.Fprintf(buf, "\n// Invoke%s runs %s for externalUserID\n", name, c.Key)
+	fmt.Fprintf(buf, "func Invoke%s(ctx context.Context, client *connect.Client, externalUserID string, props %s) (*connect.ActionRunResult, error) {\n", name, name)
+	fmt.Fprintf(buf, "\treturn client.InvokeAction(ctx, %sKey, externalUserID, props.ToConfiguredProps(), \"\")\n}\n", name)
+}
+
+func componentFields(props []*connect.ConfigurableProp) []field {
+	var fields []field
+	used := map[string]int{}
+	for _, prop := range props {
+		if prop == nil || prop.Disabled || !prop.Type.UserConfigured() {
+			continue
+		}
+
+		name := goName(prop.Name)
+		used[name]++
+		if n := used[name]; n > 1 {
+			name += strconv.Itoa(n)
+		}
+
+		f := field{prop: prop, name: name, optional: !prop.Required()}
+		f.goType = goType(prop.Type)
+		nilable := f.goType == "any" || strings.HasPrefix(f.goType, "[]") || strings.HasPrefix(f.goType, "map[")
+		if f.optional && !nilable {
+			f.goType = "*" + f.goType
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func goType(t connect.PropType) string {
+	switch t {
+	case connect.PropTypeString, connect.PropTypeSQL:
+		return "string"
+	case connect.PropTypeStringArray:
+		return "[]string"
+	case connect.PropTypeInteger:
+		return "int"
+	case connect.PropTypeIntegerArray:
+		return "[]int"
+	case connect.PropTypeBoolean:
+		return "bool"
+	case connect.PropTypeApp:
+		// the ID of the connected account
+		return "string"
+	case connect.PropTypeObject, connect.PropTypeTimer:
+		return "map[string]any"
+	}
+	return "any"
+}
+
+func writeFieldComment(buf *bytes.Buffer, f field) {
+	var lines []string
+	if f.prop.Label != "" {
+		lines = append(lines, f.prop.Label)
+	}
+	if f.prop.Description != "" {
+		lines = append(lines, f.prop.Description)
+	}
+	if f.prop.Type == connect.PropTypeApp {
+		lines = append(lines, fmt.Sprintf("Account ID (apn_...) of a connected %s account", cmp.Or(f.prop.App, f.prop.Name)))
+	}
+
+	tags := []string{fmt.Sprintf("prop %q", f.prop.Name)}
+	if f.optional {
+		tags = append(tags, "optional")
+	}
+	if f.prop.Default != nil {
+		tags = append(tags, fmt.Sprintf("default %v", f.prop.Default))
+	}
+	lines = append(lines, strings.Join(tags, ", "))
+
+	for i, line := range lines {
+		if i > 0 {
+			fmt.Fprintf(buf, "\t//\n")
+		}
+		writeComment(buf, "\t", line)
+	}
+}
+
+func writeComment(buf *bytes.Buffer, indent string, text string) {
+	for line := range strings.Lines(strings.TrimSpace(text)) {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			fmt.Fprintf(buf, "%s//\n", indent)
+			continue
+		}
+		fmt.Fprintf(buf, "%s// %s\n", indent, line)
+	}
+}
+
+var initialisms = map[string]string{
+	"api": "API", "csv": "CSV", "html": "HTML", "http": "HTTP", "https": "HTTPS",
+	"id": "ID", "ids": "IDs", "ip": "IP", "json": "JSON", "sql": "SQL",
+	"ts": "TS", "uri": "URI", "url": "URL", "urls": "URLs", "uuid": "UUID", "xml": "XML",
+}
+
+// goName converts keys like google_sheets-add-single-row and prop names like
+// sheetId into exported identifiers, GoogleSheetsAddSingleRow and SheetID
+func goName(s string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) ||
+			i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1])):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+
+	var out strings.Builder
+	for _, w := range words {
+		lower := strings.ToLower(w)
+		if initialism, ok := initialisms[lower]; ok {
+			out.WriteString(initialism)
+			continue
+		}
+		r := []rune(lower)
+		r[0] = unicode.ToUpper(r[0])
+		out.WriteString(string(r))
+	}
+
+	name := out.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "P" + name
+	}
+	return name
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+	"github.com/stretchr/testify/suite"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+type generateTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func (suite *generateTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+}
+
+func (suite *generateTestSuite) TestGenerate_Golden() {
+	require := suite.Require()
+
+	components, err := readComponents("testdata/components.json", connect.Actions)
+	require.NoError(err)
+
+	src, err := generate("components", components)
+	require.NoError(err)
+
+	if *update {
+		require.NoError(os.WriteFile("testdata/components.golden", src, 0o644))
+	}
+	golden, err := os.ReadFile("testdata/components.golden")
+	require.NoError(err)
+	require.Equal(string(golden), string(src))
+
+	// the order of the input does not matter
+	slices.Reverse(components)
+	reversed, err := generate("components", components)
+	require.NoError(err)
+	require.Equal(src, reversed)
+}
+
+func (suite *generateTestSuite) TestGenerate_NameCollision() {
+	require := suite.Require()
+
+	_, err := generate("components", []component{
+		{ComponentDetails: &connect.ComponentDetails{Component: connect.Component{Key: "slack-send-message"}}},
+		{ComponentDetails: &connect.ComponentDetails{Component: connect.Component{Key: "slack_send_message"}}},
+	})
+	require.ErrorContains(err, "both map to SlackSendMessage")
+}
+
+func (suite *generateTestSuite) TestGoName() {
+	require := suite.Require()
+
+	for in, want := range map[string]string{
+		"google_sheets-add-single-row": "GoogleSheetsAddSingleRow",
+		"sheetId":                      "SheetID",
+		"threadTs":                     "ThreadTS",
+		"HTTPRequest":                  "HTTPRequest",
+		"webhookUrls":                  "WebhookURLs",
+		"2fa":                          "P2fa",
+		"$":                            "P",
+	} {
+		require.Equal(want, goName(in), in)
+	}
+}
+
+func (suite *generateTestSuite) TestRun_Live() {
+	require := suite.Require()
+
+	bs, err := os.ReadFile("testdata/components.json")
+	require.NoError(err)
+	var details []*connect.ComponentDetails
+	require.NoError(json.Unmarshal(bs, &details))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/oauth/token" {
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+			return
+		}
+		for _, d := range details {
+			if r.URL.Path == "/project-abc/actions/"+d.Key {
+				_ = json.NewEncoder(w).Encode(connect.GetComponentResponse{Data: d})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	components, err := fetchComponents(suite.ctx, &connect.Client{Client: base},
+		[]string{"slack-send-message", " google_sheets-add-single-row", ""}, connect.Actions)
+	require.NoError(err)
+	require.Len(components, 2)
+
+	dir := suite.T().TempDir()
+	saved := filepath.Join(dir, "components.json")
+	require.NoError(writeComponents(saved, components))
+
+	out := filepath.Join(dir, "components_gen.go")
+	require.NoError(run(suite.ctx, []string{"-in", saved, "-pkg", "actions", "-o", out}))
+
+	src, err := os.ReadFile(out)
+	require.NoError(err)
+	require.Contains(string(src), "package actions")
+	require.Contains(string(src), "func InvokeSlackSendMessage(")
+	require.Contains(string(src), "func InvokeGoogleSheetsAddSingleRow(")
+
+	_, err = fetchComponents(suite.ctx, &connect.Client{Client: base}, []string{"jira-create-issue"}, connect.Actions)
+	require.Error(err)
+}
+
+func TestGenerate(t *testing.T) {
+	suite.Run(t, new(generateTestSuite))
+}
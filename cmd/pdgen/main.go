@@ -0,0 +1,154 @@
+// Command pdgen generates typed prop structs and Invoke/Deploy wrappers for
+// Connect components.
+//
+// Components are read from a JSON file written by -save, or fetched live with
+// the credentials in PIPEDREAM_CLIENT_ID, PIPEDREAM_CLIENT_SECRET,
+// PIPEDREAM_PROJECT_ID and PIPEDREAM_ENVIRONMENT:
+//
+//	pdgen -keys slack-send-message,google_sheets-add-single-row -save components.json -o actions_gen.go
+//	pdgen -in components.json -pkg actions -o actions_gen.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("pdgen: ")
+
+	if err := run(context.Background(), os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("pdgen", flag.ContinueOnError)
+	in := flags.String("in", "", "JSON file with the component details")
+	keys := flags.String("keys", "", "comma separated component keys to fetch from the API")
+	componentType := flags.String("type", string(connect.Actions), "component type of -keys, actions or triggers")
+	save := flags.String("save", "", "write the fetched component details to this JSON file")
+	pkg := flags.String("pkg", "components", "package name of the generated file")
+	out := flags.String("o", "", "output file, defaults to stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var components []component
+	switch {
+	case *in != "" && *keys != "":
+		return errors.New("-in and -keys are mutually exclusive")
+	case *in != "":
+		loaded, err := readComponents(*in, connect.ComponentType(*componentType))
+		if err != nil {
+			return err
+		}
+		components = loaded
+	case *keys != "":
+		fetched, err := fetchComponents(ctx, newClient(), strings.Split(*keys, ","), connect.ComponentType(*componentType))
+		if err != nil {
+			return err
+		}
+		components = fetched
+		if *save != "" {
+			if err := writeComponents(*save, components); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("either -in or -keys is required")
+	}
+
+	src, err := generate(*pkg, components)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err := os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+func newClient() *connect.Client {
+	base := client.NewClient(
+		"",
+		os.Getenv("PIPEDREAM_PROJECT_ID"),
+		os.Getenv("PIPEDREAM_ENVIRONMENT"),
+		os.Getenv("PIPEDREAM_CLIENT_ID"),
+		os.Getenv("PIPEDREAM_CLIENT_SECRET"),
+		nil,
+		os.Getenv("PIPEDREAM_CONNECT_URL"),
+		os.Getenv("PIPEDREAM_REST_URL"))
+
+	return &connect.Client{Client: base}
+}
+
+func fetchComponents(
+	ctx context.Context,
+	c *connect.Client,
+	keys []string,
+	componentType connect.ComponentType,
+) ([]component, error) {
+	var components []component
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		response, err := c.GetComponent(ctx, key, componentType)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s: %w", key, err)
+		}
+		if response.Data == nil {
+			return nil, fmt.Errorf("component %s: %w", key, connect.NotFoundErr)
+		}
+		components = append(components, component{ComponentDetails: response.Data, Type: componentType})
+	}
+	return components, nil
+}
+
+// readComponents accepts a JSON array of component details or a single one,
+// components without component_type get defaultType
+func readComponents(path string, defaultType connect.ComponentType) ([]component, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading components: %w", err)
+	}
+
+	var components []component
+	if err := json.Unmarshal(bs, &components); err != nil {
+		var single component
+		if err := json.Unmarshal(bs, &single); err != nil {
+			return nil, fmt.Errorf("decoding components from %s: %w", path, err)
+		}
+		components = []component{single}
+	}
+
+	for i := range components {
+		if components[i].Type == "" {
+			components[i].Type = defaultType
+		}
+	}
+	return components, nil
+}
+
+func writeComponents(path string, components []component) error {
+	bs, err := json.MarshalIndent(components, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding components: %w", err)
+	}
+	return os.WriteFile(path, bs, 0o644)
+}
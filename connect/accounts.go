@@ -28,6 +28,29 @@ type Account struct {
 	Error           any         `json:"error,omitempty"`
 	LastRefreshedAt time.Time   `json:"last_refreshed_at,omitzero"`
 	NextRefreshAt   time.Time   `json:"next_refresh_at,omitzero"`
+
+	// RawCredentials is the credentials object as returned by the API. Unlike
+	// Credentials it holds the fields of every auth type, e.g. API keys
+	RawCredentials json.RawMessage `json:"-"`
+}
+
+func (a *Account) UnmarshalJSON(data []byte) error {
+	type account Account
+	var raw struct {
+		account
+		Credentials json.RawMessage `json:"credentials"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*a = Account(raw.account)
+	if len(raw.Credentials) > 0 && string(raw.Credentials) != "null" {
+		if err := json.Unmarshal(raw.Credentials, &a.Credentials); err != nil {
+			return fmt.Errorf("decoding credentials: %w", err)
+		}
+		a.RawCredentials = raw.Credentials
+	}
+	return nil
 }
 
 type App struct {
@@ -63,10 +86,62 @@ func (c *Client) ListAccounts(
 	oauthAppId string,
 	includeCredentials bool,
 ) (*ListAccountsResponse, error) {
-	baseURL := c.ConnectURL().ResolveReference(&url.URL{
-		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "accounts"),
-	})
+	return c.listAccounts(ctx,
+		accountsQuery(externalUserID, app, oauthAppId, includeCredentials))
+}
 
+// ListAllAccounts follows the page cursors of ListAccounts and returns every matching account
+func (c *Client) ListAllAccounts(
+	ctx context.Context,
+	externalUserID string,
+	app string,
+	oauthAppId string,
+	includeCredentials bool,
+) ([]*Account, error) {
+	var accounts []*Account
+	err := c.walkAccounts(ctx,
+		accountsQuery(externalUserID, app, oauthAppId, includeCredentials),
+		func(page []*Account) error {
+			accounts = append(accounts, page...)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// walkAccounts calls fn for every page of accounts matching queryParams
+func (c *Client) walkAccounts(
+	ctx context.Context,
+	queryParams url.Values,
+	fn func(page []*Account) error,
+) error {
+	total := 0
+	for {
+		page, err := c.listAccounts(ctx, queryParams)
+		if err != nil {
+			return err
+		}
+		if err := fn(page.Data); err != nil {
+			return err
+		}
+		total += len(page.Data)
+
+		if !hasNextPage(page.PageInfo, len(page.Data), total, queryParams.Get("after")) {
+			return nil
+		}
+		queryParams.Set("after", page.PageInfo.EndCursor)
+	}
+}
+
+func accountsQuery(
+	externalUserID string,
+	app string,
+	oauthAppId string,
+	includeCredentials bool,
+) url.Values {
 	queryParams := url.Values{}
 	internal.AddQueryParams(queryParams, "external_user_id", externalUserID)
 	internal.AddQueryParams(queryParams, "app", app)
@@ -75,6 +150,16 @@ func (c *Client) ListAccounts(
 		"include_credentials",
 		strconv.FormatBool(includeCredentials),
 	)
+	return queryParams
+}
+
+func (c *Client) listAccounts(
+	ctx context.Context,
+	queryParams url.Values,
+) (*ListAccountsResponse, error) {
+	baseURL := c.ConnectURL().ResolveReference(&url.URL{
+		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "accounts"),
+	})
 
 	baseURL.RawQuery = queryParams.Encode()
 	endpoint := baseURL.String()
@@ -88,13 +173,10 @@ func (c *Client) ListAccounts(
 	if err != nil {
 		return nil, fmt.Errorf("executing list account request: %w", err)
 	}
-	defer response.Body.Close()
 
 	var accountsList ListAccountsResponse
-
-	err = json.NewDecoder(response.Body).Decode(&accountsList)
-	if err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	if err := internal.UnmarshalResponse(response, &accountsList); err != nil {
+		return nil, fmt.Errorf("unmarshalling list accounts response: %w", err)
 	}
 
 	return &accountsList, nil
@@ -171,10 +253,12 @@ func (c *Client) DeleteAccount(
 	defer response.Body.Close()
 
 	if response.StatusCode == http.StatusNoContent {
+		if c.ActionCache != nil {
+			c.ActionCache.invalidate(c.ActionCache.InvalidateAccount(ctx, accountId))
+		}
 		return nil
 	}
-	return fmt.Errorf("expected status %d, got %d",
-		http.StatusNoContent, response.StatusCode)
+	return &StatusError{Expected: http.StatusNoContent, StatusCode: response.StatusCode}
 }
 
 // DeleteAccounts Delete all connected accounts for a specific app
@@ -182,6 +266,17 @@ func (c *Client) DeleteAccounts(
 	ctx context.Context,
 	appID string,
 ) error {
+	// the cached action results are keyed by account, so the accounts of the
+	// app are looked up before they are gone
+	var accounts []*Account
+	if c.ActionCache != nil {
+		var err error
+		accounts, err = c.ListAllAccounts(ctx, "", appID, "", false)
+		if err != nil {
+			return fmt.Errorf("listing accounts of app %s: %w", appID, err)
+		}
+	}
+
 	endpoint := c.ConnectURL().ResolveReference(&url.URL{
 		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "apps", appID, "accounts"),
 	}).String()
@@ -198,9 +293,12 @@ func (c *Client) DeleteAccounts(
 	defer response.Body.Close()
 
 	if response.StatusCode == http.StatusNoContent {
+		for _, account := range accounts {
+			c.ActionCache.invalidate(c.ActionCache.InvalidateAccount(ctx, account.ID))
+		}
 		return nil
 	}
-	return fmt.Errorf("expected status %d, got %d", http.StatusNoContent, response.StatusCode)
+	return &StatusError{Expected: http.StatusNoContent, StatusCode: response.StatusCode}
 }
 
 // DeleteEndUser Delete an end user, all their connected accounts, and any deployed triggers.
@@ -224,7 +322,10 @@ func (c *Client) DeleteEndUser(
 	defer response.Body.Close()
 
 	if response.StatusCode == http.StatusNoContent {
+		if c.ActionCache != nil {
+			c.ActionCache.invalidate(c.ActionCache.InvalidateUser(ctx, externalUserID))
+		}
 		return nil
 	}
-	return fmt.Errorf("expected status %d, got %d", http.StatusNoContent, response.StatusCode)
+	return &StatusError{Expected: http.StatusNoContent, StatusCode: response.StatusCode}
 }
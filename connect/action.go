@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 
 	"github.com/cloudsquid/pipedream-go-sdk/internal"
 )
@@ -19,17 +21,122 @@ type InvokeActionRequest struct {
 	ConfiguredProps ConfiguredProps `json:"configured_props,omitempty"`
 
 	DynamicPropsID string `json:"dynamic_props_id,omitempty"`
+	// StashID is an existing stash ID or "NEW", see WithStash and WithNewStash
+	StashID string `json:"stash_id,omitempty"`
 }
 
+// InvokeActionOption changes the request sent by InvokeAction
+type InvokeActionOption func(*InvokeActionRequest)
+
+// WithNewStash asks Pipedream to create a file stash for the run, the files
+// the action writes to /tmp are then returned by ActionRunResult.StashFiles
+func WithNewStash() InvokeActionOption {
+	return func(r *InvokeActionRequest) {
+		r.StashID = NewStashID
+	}
+}
+
+// WithStash runs the action with the files of an existing stash in /tmp
+func WithStash(stashID string) InvokeActionOption {
+	return func(r *InvokeActionRequest) {
+		r.StashID = stashID
+	}
+}
+
+// ActionRunResult is the response of the run action endpoint
+type ActionRunResult struct {
+	// Exports holds the values the action exported with $.export, including $summary
+	Exports map[string]any `json:"exports,omitempty"`
+	// Ret is the raw return value of the action, see DecodeRet
+	Ret          json.RawMessage `json:"ret,omitempty"`
+	Observations []Observation   `json:"os,omitempty"`
+	StashID      string          `json:"stash_id,omitempty"`
+	// Summary is exports.$summary
+	Summary string `json:"summary,omitempty"`
+	// Cache is set when the Client.ActionCache is enabled for the action
+	Cache *ActionCacheInfo `json:"-"`
+}
+
+// DecodeRet unmarshals the return value of the action into v
+func (r *ActionRunResult) DecodeRet(v any) error {
+	if len(r.Ret) == 0 {
+		return errors.New("action returned no value")
+	}
+	return json.Unmarshal(r.Ret, v)
+}
+
+// ActionError is returned when the action threw, the API still answers 200
+type ActionError struct {
+	ComponentKey string `json:"component_key"`
+	Name         string `json:"name,omitempty"`
+	Message      string `json:"message"`
+	Stack        string `json:"stack,omitempty"`
+	// Observations emitted by the action before and while it failed
+	Observations []Observation `json:"observations,omitempty"`
+}
+
+func (e *ActionError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("action %s failed: %s: %s", e.ComponentKey, e.Name, e.Message)
+	}
+	return fmt.Sprintf("action %s failed: %s", e.ComponentKey, e.Message)
+}
+
+// actionError finds the exception of a failed run in the observations or the
+// error field some responses carry
+func actionError(componentKey string, result *ActionRunResult, responseErr any) *ActionError {
+	for _, o := range result.Observations {
+		if o.Err != nil {
+			return &ActionError{
+				ComponentKey: componentKey,
+				Name:         o.Err.Name,
+				Message:      o.Err.Message,
+				Stack:        o.Err.Stack,
+				Observations: result.Observations,
+			}
+		}
+	}
+
+	switch e := responseErr.(type) {
+	case nil:
+		return nil
+	case string:
+		if strings.TrimSpace(e) == "" {
+			return nil
+		}
+		return &ActionError{ComponentKey: componentKey, Message: e, Observations: result.Observations}
+	case map[string]any:
+		name, _ := e["name"].(string)
+		message, _ := e["message"].(string)
+		stack, _ := e["stack"].(string)
+		if message == "" {
+			bs, _ := json.Marshal(e)
+			message = string(bs)
+		}
+		return &ActionError{
+			ComponentKey: componentKey,
+			Name:         name,
+			Message:      message,
+			Stack:        stack,
+			Observations: result.Observations,
+		}
+	}
+	return &ActionError{ComponentKey: componentKey, Message: fmt.Sprint(responseErr), Observations: result.Observations}
+}
+
+// InvokeAction runs the action and returns its result. When the action throws
+// the result is returned together with an *ActionError
 func (c *Client) InvokeAction(
 	ctx context.Context,
 	componentKey string,
 	externalUserID string,
 	props ConfiguredProps,
 	dynamicPropsId string,
-) (map[string]any, error) {
-	baseURL := c.ConnectURL().ResolveReference(&url.URL{
-		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "actions", "run")})
+	opts ...InvokeActionOption,
+) (*ActionRunResult, error) {
+	if err := c.checkPolicy(ctx, componentPolicyRequest(PolicyInvokeAction, componentKey, externalUserID)); err != nil {
+		return nil, err
+	}
 
 	invokeActionReq := InvokeActionRequest{
 		ID:              componentKey,
@@ -40,6 +147,29 @@ func (c *Client) InvokeAction(
 	if dynamicPropsId != "" {
 		invokeActionReq.DynamicPropsID = dynamicPropsId
 	}
+	for _, opt := range opts {
+		opt(&invokeActionReq)
+	}
+
+	if c.ActionCache != nil {
+		return c.ActionCache.invoke(ctx, invokeActionReq, c.runAction)
+	}
+	return c.runAction(ctx, invokeActionReq)
+}
+
+// runAction validates the props if enabled and runs the action
+func (c *Client) runAction(ctx context.Context, invokeActionReq InvokeActionRequest) (*ActionRunResult, error) {
+	componentKey := invokeActionReq.ID
+	if c.ValidateProps {
+		err := c.validateComponentProps(ctx, Actions, componentKey,
+			invokeActionReq.ConfiguredProps, invokeActionReq.DynamicPropsID != "")
+		if err != nil {
+			return nil, fmt.Errorf("validating props for action %s: %w", componentKey, err)
+		}
+	}
+
+	baseURL := c.ConnectURL().ResolveReference(&url.URL{
+		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "actions", "run")})
 
 	jsonBytes, err := json.MarshalIndent(invokeActionReq, "", "  ")
 	if err != nil {
@@ -59,10 +189,41 @@ func (c *Client) InvokeAction(
 		return nil, fmt.Errorf("executing invoke action request: %w", err)
 	}
 
-	var response map[string]any
+	var response struct {
+		ActionRunResult
+		Error any `json:"error,omitempty"`
+	}
 	if err := internal.UnmarshalResponse(resp, &response); err != nil {
 		return nil, fmt.Errorf("unmarshalling invoke action response: %w", err)
 	}
 
-	return response, nil
+	result := &response.ActionRunResult
+	result.Summary, _ = result.Exports["$summary"].(string)
+	c.observe(ctx, componentKey, result.Observations)
+
+	if err := actionError(componentKey, result, response.Error); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// InvokeActionAs runs the action like InvokeAction and decodes its return value into T
+func InvokeActionAs[T any](
+	ctx context.Context,
+	c *Client,
+	componentKey string,
+	externalUserID string,
+	props ConfiguredProps,
+	dynamicPropsID string,
+	opts ...InvokeActionOption,
+) (T, *ActionRunResult, error) {
+	var ret T
+	result, err := c.InvokeAction(ctx, componentKey, externalUserID, props, dynamicPropsID, opts...)
+	if err != nil {
+		return ret, result, err
+	}
+	if err := result.DecodeRet(&ret); err != nil {
+		return ret, result, fmt.Errorf("decoding return value of %s: %w", componentKey, err)
+	}
+	return ret, result, nil
 }
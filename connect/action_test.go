@@ -90,10 +90,100 @@ func (suite *actionTestSuite) TestInvokeAction_Success() {
 		componentKey,
 		externalUserID,
 		configuredProp,
+		"",
 	)
 
 	require.NoError(err)
-	require.EqualValues("Retrieved 1 commit", resp["exports"].(map[string]interface{})["$summary"].(string))
+	require.EqualValues("Retrieved 1 commit", resp.Summary)
+	require.EqualValues("Retrieved 1 commit", resp.Exports["$summary"])
+
+	var commits []struct {
+		ID      string `json:"id"`
+		ShortID string `json:"short_id"`
+	}
+	require.NoError(resp.DecodeRet(&commits))
+	require.Len(commits, 1)
+	require.Equal("387262ae", commits[0].ShortID)
+}
+
+func (suite *actionTestSuite) serveRun(body string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/project-abc/actions/run":
+			_, _ = fmt.Fprint(w, body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+}
+
+func (suite *actionTestSuite) TestInvokeActionAs() {
+	require := suite.Require()
+	suite.serveRun(`{
+		"exports": {"$summary": "Created issue #7"},
+		"os": [{"k": "console.log", "msg": "creating issue", "ts": 1700000000000}],
+		"ret": {"iid": 7, "web_url": "https://gitlab.com/a/b/-/issues/7"},
+		"stash_id": "stash_123"
+	}`)
+
+	var observed []Observation
+	suite.pipedreamClient.OnObservation = func(_ context.Context, componentKey string, o Observation) {
+		require.Equal("gitlab-create-issue", componentKey)
+		observed = append(observed, o)
+	}
+
+	type issue struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	ret, result, err := InvokeActionAs[issue](suite.ctx, suite.pipedreamClient,
+		"gitlab-create-issue", "jverce", ConfiguredProps{}, "")
+	require.NoError(err)
+	require.Equal(issue{IID: 7, WebURL: "https://gitlab.com/a/b/-/issues/7"}, ret)
+	require.Equal("stash_123", result.StashID)
+	require.Equal("Created issue #7", result.Summary)
+	require.Len(result.Observations, 1)
+	require.Len(observed, 1)
+
+	_, _, err = InvokeActionAs[[]issue](suite.ctx, suite.pipedreamClient,
+		"gitlab-create-issue", "jverce", ConfiguredProps{}, "")
+	require.ErrorContains(err, "decoding return value of gitlab-create-issue")
+}
+
+func (suite *actionTestSuite) TestInvokeAction_ActionError() {
+	require := suite.Require()
+	suite.serveRun(`{
+		"exports": {},
+		"os": [
+			{"k": "console.log", "msg": "calling gitlab"},
+			{"k": "error", "err": {"name": "Error", "message": "Request failed with status code 404", "stack": "at run"}}
+		]
+	}`)
+
+	result, err := suite.pipedreamClient.InvokeAction(suite.ctx, "gitlab-create-issue", "jverce", ConfiguredProps{}, "")
+	var actionErr *ActionError
+	require.ErrorAs(err, &actionErr)
+	require.Equal("action gitlab-create-issue failed: Error: Request failed with status code 404", err.Error())
+	require.Equal("at run", actionErr.Stack)
+	require.Len(actionErr.Observations, 2)
+	require.NotNil(result)
+	require.Empty(result.Ret)
+
+	suite.serveRun(`{"error": "Component timed out"}`)
+	_, err = suite.pipedreamClient.InvokeAction(suite.ctx, "gitlab-create-issue", "jverce", ConfiguredProps{}, "")
+	require.ErrorAs(err, &actionErr)
+	require.Equal("Component timed out", actionErr.Message)
+
+	suite.serveRun(`{"exports": {}, "os": [{"k": "console.error", "msg": "retrying"}], "ret": null}`)
+	result, err = suite.pipedreamClient.InvokeAction(suite.ctx, "gitlab-create-issue", "jverce", ConfiguredProps{}, "")
+	require.NoError(err)
+	require.Equal(ObservationError, result.Observations[0].Level)
 }
 
 func TestAction(t *testing.T) {
@@ -0,0 +1,440 @@
+package connect
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ActionCacheStatus string
+
+const (
+	ActionCacheHit  ActionCacheStatus = "hit"
+	ActionCacheMiss ActionCacheStatus = "miss"
+	// ActionCacheBypass results were not cacheable, e.g. runs with a file stash
+	ActionCacheBypass ActionCacheStatus = "bypass"
+)
+
+// ActionCacheInfo tells where a result of InvokeAction came from
+type ActionCacheInfo struct {
+	Status ActionCacheStatus
+	Key    string
+	// StoredAt and ExpiresAt are set for hits and stored misses
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// ActionCacheEntry is a stored action result. AccountIDs are the accounts
+// referenced by the app props of the invocation
+type ActionCacheEntry struct {
+	Key            string           `json:"key"`
+	ComponentKey   string           `json:"component_key"`
+	ExternalUserID string           `json:"external_user_id"`
+	AccountIDs     []string         `json:"account_ids,omitempty"`
+	Result         *ActionRunResult `json:"result"`
+	StoredAt       time.Time        `json:"stored_at"`
+	ExpiresAt      time.Time        `json:"expires_at"`
+}
+
+// ActionCacheStore persists cached action results, e.g. in Redis to share
+// them between replicas. Get returns nil without error for missing entries
+type ActionCacheStore interface {
+	Get(ctx context.Context, key string) (*ActionCacheEntry, error)
+	Set(ctx context.Context, entry *ActionCacheEntry) error
+	// DeleteUser and DeleteAccount return the number of removed entries
+	DeleteUser(ctx context.Context, externalUserID string) (int, error)
+	DeleteAccount(ctx context.Context, accountID string) (int, error)
+}
+
+// ActionCache caches the results of read-only actions. Only actions enabled
+// with Enable are cached, keyed by external user, component and the
+// canonicalized props. Failed runs and runs with a file stash are not cached.
+// Set it on Client.ActionCache to enable it
+type ActionCache struct {
+	store ActionCacheStore
+	now   func() time.Time
+
+	mu   sync.RWMutex
+	ttls map[string]time.Duration
+
+	callsMu  sync.Mutex
+	inflight map[string]*actionCacheCall
+
+	// generation is bumped by every invalidation, runs started before one do
+	// not store their result. Saves hold genMu for reading, so an invalidation
+	// cannot slip in between the check and the store
+	genMu      sync.RWMutex
+	generation uint64
+
+	// OnError is optional and receives store errors, which never fail the invocation
+	OnError func(error)
+}
+
+// NewActionCache returns a cache backed by store, a MemoryActionCacheStore of
+// 1000 entries if nil
+func NewActionCache(store ActionCacheStore) *ActionCache {
+	if store == nil {
+		store = NewMemoryActionCacheStore(1000)
+	}
+	return &ActionCache{
+		store:    store,
+		now:      time.Now,
+		ttls:     map[string]time.Duration{},
+		inflight: map[string]*actionCacheCall{},
+	}
+}
+
+type actionCacheCall struct {
+	done       chan struct{}
+	generation uint64
+	result     *ActionRunResult
+	err        error
+}
+
+// Enable caches the results of the actions for ttl, a non-positive ttl
+// disables caching them again
+func (c *ActionCache) Enable(ttl time.Duration, componentKeys ...string) *ActionCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range componentKeys {
+		if ttl <= 0 {
+			delete(c.ttls, key)
+			continue
+		}
+		c.ttls[key] = ttl
+	}
+	return c
+}
+
+// TTL returns how long results of the action are cached, false if they are not
+func (c *ActionCache) TTL(componentKey string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ttl, ok := c.ttls[componentKey]
+	return ttl, ok
+}
+
+// InvalidateUser drops every entry of an external user and returns how many were removed
+func (c *ActionCache) InvalidateUser(ctx context.Context, externalUserID string) (int, error) {
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+	c.generation++
+	return c.store.DeleteUser(ctx, externalUserID)
+}
+
+// InvalidateAccount drops every entry whose props reference the account, e.g.
+// after it was reconnected
+func (c *ActionCache) InvalidateAccount(ctx context.Context, accountID string) (int, error) {
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+	c.generation++
+	return c.store.DeleteAccount(ctx, accountID)
+}
+
+// invoke returns the cached result of req or calls run. Concurrent misses of
+// the same key share a single run
+func (c *ActionCache) invoke(
+	ctx context.Context,
+	req InvokeActionRequest,
+	run func(context.Context, InvokeActionRequest) (*ActionRunResult, error),
+) (*ActionRunResult, error) {
+	cached, info := c.lookup(ctx, req)
+	if cached != nil {
+		return cached, nil
+	}
+	if info == nil {
+		return run(ctx, req)
+	}
+	if info.Status != ActionCacheMiss {
+		result, err := run(ctx, req)
+		if err == nil {
+			result.Cache = info
+		}
+		return result, err
+	}
+
+	c.callsMu.Lock()
+	call, ok := c.inflight[info.Key]
+	if !ok {
+		c.genMu.RLock()
+		call = &actionCacheCall{done: make(chan struct{}), generation: c.generation}
+		c.genMu.RUnlock()
+		c.inflight[info.Key] = call
+		// the run is shared, so it must not fail because the caller that
+		// started it gave up
+		go c.run(context.WithoutCancel(ctx), req, info, call, run)
+	}
+	c.callsMu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.result == nil {
+			return nil, call.err
+		}
+		return cloneActionRunResult(call.result), call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *ActionCache) run(
+	ctx context.Context,
+	req InvokeActionRequest,
+	info *ActionCacheInfo,
+	call *actionCacheCall,
+	run func(context.Context, InvokeActionRequest) (*ActionRunResult, error),
+) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.result, call.err = nil, fmt.Errorf("invoking action %s panicked: %v", req.ID, r)
+		}
+		if call.err == nil {
+			c.save(ctx, req, call.result, info, call.generation)
+		}
+
+		c.callsMu.Lock()
+		delete(c.inflight, info.Key)
+		c.callsMu.Unlock()
+		close(call.done)
+	}()
+
+	call.result, call.err = run(ctx, req)
+}
+
+// lookup returns the cached result of req, or the cache info of the miss to
+// pass to save. Both are nil for actions that are not enabled
+func (c *ActionCache) lookup(ctx context.Context, req InvokeActionRequest) (*ActionRunResult, *ActionCacheInfo) {
+	if _, ok := c.TTL(req.ID); !ok {
+		return nil, nil
+	}
+	if req.StashID != "" {
+		return nil, &ActionCacheInfo{Status: ActionCacheBypass}
+	}
+
+	key, err := actionCacheKey(req)
+	if err != nil {
+		c.reportError(err)
+		return nil, &ActionCacheInfo{Status: ActionCacheBypass}
+	}
+
+	entry, err := c.store.Get(ctx, key)
+	if err != nil {
+		c.reportError(err)
+	}
+	if entry != nil && entry.Result != nil && c.now().Before(entry.ExpiresAt) {
+		result := cloneActionRunResult(entry.Result)
+		result.Cache = &ActionCacheInfo{
+			Status:    ActionCacheHit,
+			Key:       key,
+			StoredAt:  entry.StoredAt,
+			ExpiresAt: entry.ExpiresAt,
+		}
+		return result, nil
+	}
+	return nil, &ActionCacheInfo{Status: ActionCacheMiss, Key: key}
+}
+
+// save stores a successful result of a run started at generation and
+// attaches the cache info to it
+func (c *ActionCache) save(
+	ctx context.Context,
+	req InvokeActionRequest,
+	result *ActionRunResult,
+	info *ActionCacheInfo,
+	generation uint64,
+) {
+	result.Cache = info
+	ttl, ok := c.TTL(req.ID)
+	if !ok || info.Status != ActionCacheMiss {
+		return
+	}
+
+	c.genMu.RLock()
+	defer c.genMu.RUnlock()
+	// the result may predate an invalidation
+	if generation != c.generation {
+		return
+	}
+
+	now := c.now()
+	info.StoredAt = now
+	info.ExpiresAt = now.Add(ttl)
+
+	stored := cloneActionRunResult(result)
+	stored.Cache = nil
+	err := c.store.Set(ctx, &ActionCacheEntry{
+		Key:            info.Key,
+		ComponentKey:   req.ID,
+		ExternalUserID: req.ExternalUserID,
+		AccountIDs:     authProvisionIDs(req.ConfiguredProps),
+		Result:         stored,
+		StoredAt:       info.StoredAt,
+		ExpiresAt:      info.ExpiresAt,
+	})
+	if err != nil {
+		c.reportError(err)
+	}
+}
+
+// invalidate reports the error of an invalidation done by the Client
+func (c *ActionCache) invalidate(_ int, err error) {
+	if err != nil {
+		c.reportError(err)
+	}
+}
+
+func (c *ActionCache) reportError(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+func actionCacheKey(req InvokeActionRequest) (string, error) {
+	// normalizing makes numbers of any Go type encode alike, encoding/json sorts map keys
+	props, err := normalizeJSON(req.ConfiguredProps)
+	if err != nil {
+		return "", err
+	}
+	bs, err := json.Marshal(struct {
+		Props          any    `json:"p"`
+		DynamicPropsID string `json:"d"`
+	}{props, req.DynamicPropsID})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bs)
+
+	return strings.Join([]string{req.ID, req.ExternalUserID, hex.EncodeToString(sum[:])}, "\x00"), nil
+}
+
+// cloneActionRunResult deep copies r, so that callers mutating a cached result
+// do not change it for others
+func cloneActionRunResult(r *ActionRunResult) *ActionRunResult {
+	clone := *r
+	if r.Exports != nil {
+		clone.Exports = cloneJSONValue(r.Exports).(map[string]any)
+	}
+	clone.Ret = slices.Clone(r.Ret)
+	clone.Observations = slices.Clone(r.Observations)
+	for i, o := range clone.Observations {
+		if o.Err != nil {
+			err := *o.Err
+			clone.Observations[i].Err = &err
+		}
+	}
+	if r.Cache != nil {
+		info := *r.Cache
+		clone.Cache = &info
+	}
+	return &clone
+}
+
+// cloneJSONValue deep copies the objects and arrays of a decoded JSON value
+func cloneJSONValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = cloneJSONValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = cloneJSONValue(item)
+		}
+		return out
+	}
+	return value
+}
+
+// MemoryActionCacheStore keeps the most recently used entries in memory
+type MemoryActionCacheStore struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func NewMemoryActionCacheStore(maxEntries int) *MemoryActionCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryActionCacheStore{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+func (s *MemoryActionCacheStore) Get(_ context.Context, key string) (*ActionCacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	s.lru.MoveToFront(elem)
+	return elem.Value.(*ActionCacheEntry), nil
+}
+
+func (s *MemoryActionCacheStore) Set(_ context.Context, entry *ActionCacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[entry.Key]; ok {
+		s.remove(elem)
+	}
+	s.entries[entry.Key] = s.lru.PushFront(entry)
+
+	for s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+func (s *MemoryActionCacheStore) DeleteUser(_ context.Context, externalUserID string) (int, error) {
+	return s.removeWhere(func(e *ActionCacheEntry) bool {
+		return e.ExternalUserID == externalUserID
+	}), nil
+}
+
+func (s *MemoryActionCacheStore) DeleteAccount(_ context.Context, accountID string) (int, error) {
+	return s.removeWhere(func(e *ActionCacheEntry) bool {
+		return slices.Contains(e.AccountIDs, accountID)
+	}), nil
+}
+
+func (s *MemoryActionCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *MemoryActionCacheStore) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.entries, elem.Value.(*ActionCacheEntry).Key)
+}
+
+func (s *MemoryActionCacheStore) removeWhere(match func(*ActionCacheEntry) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if match(elem.Value.(*ActionCacheEntry)) {
+			s.remove(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
@@ -0,0 +1,268 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type actionCacheTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	now             time.Time
+	cache           *ActionCache
+	pipedreamClient *Client
+	runs            atomic.Int32
+	fail            atomic.Bool
+	// gate holds action runs until it is closed if set
+	gate chan struct{}
+}
+
+func (suite *actionCacheTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	suite.runs.Store(0)
+	suite.fail.Store(false)
+	suite.gate = nil
+
+	suite.cache = NewActionCache(nil).Enable(time.Minute, "google_sheets-get-values")
+	suite.cache.now = func() time.Time { return suite.now }
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/project-abc/actions/run":
+			var request InvokeActionRequest
+			suite.Require().NoError(json.NewDecoder(r.Body).Decode(&request))
+			run := suite.runs.Add(1)
+			if suite.gate != nil {
+				<-suite.gate
+			}
+			if suite.fail.Load() {
+				_, _ = fmt.Fprint(w, `{"os": [{"k": "error", "err": {"name": "Error", "message": "sheet not found"}}]}`)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"exports": {"$summary": "Run %d", "rows": [["a", "b"]]}, "ret": {"run": %d}}`, run, run)
+		case r.Method == http.MethodGet && r.URL.Path == "/project-abc/accounts":
+			suite.Require().Equal("google_sheets", r.URL.Query().Get("app"))
+			_, _ = fmt.Fprint(w, `{"page_info": {"total_count": 1, "count": 1}, "data": [{"id": "apn_def"}]}`)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base, ActionCache: suite.cache}
+}
+
+func (suite *actionCacheTestSuite) props(sheet string) ConfiguredProps {
+	return ConfiguredProps{
+		"google_sheets": map[string]string{"authProvisionId": "apn_" + sheet},
+		"sheetId":       sheet,
+		"range":         "A1:C10",
+	}
+}
+
+func (suite *actionCacheTestSuite) invoke(componentKey string, user string, props ConfiguredProps, opts ...InvokeActionOption) *ActionRunResult {
+	result, err := suite.pipedreamClient.InvokeAction(suite.ctx, componentKey, user, props, "", opts...)
+	suite.Require().NoError(err)
+	return result
+}
+
+func (suite *actionCacheTestSuite) TestHitAndExpiry() {
+	require := suite.Require()
+
+	first := suite.invoke("google_sheets-get-values", "jverce", suite.props("abc"))
+	require.Equal(ActionCacheMiss, first.Cache.Status)
+	require.Equal(suite.now.Add(time.Minute), first.Cache.ExpiresAt)
+
+	// the same props in another order and number type hit the cache
+	second := suite.invoke("google_sheets-get-values", "jverce", ConfiguredProps{
+		"range":         "A1:C10",
+		"sheetId":       "abc",
+		"google_sheets": map[string]any{"authProvisionId": "apn_abc"},
+	})
+	require.Equal(ActionCacheHit, second.Cache.Status)
+	require.Equal("Run 1", second.Summary)
+	require.JSONEq(`{"run": 1}`, string(second.Ret))
+	require.EqualValues(1, suite.runs.Load())
+
+	// hits are copies
+	second.Exports["$summary"] = "changed"
+	second.Exports["rows"].([]any)[0].([]any)[0] = "changed"
+	third := suite.invoke("google_sheets-get-values", "jverce", suite.props("abc"))
+	require.Equal("Run 1", third.Exports["$summary"])
+	require.Equal([]any{[]any{"a", "b"}}, third.Exports["rows"])
+
+	suite.invoke("google_sheets-get-values", "jverce", suite.props("def"))
+	suite.invoke("google_sheets-get-values", "other-user", suite.props("abc"))
+	require.EqualValues(3, suite.runs.Load())
+
+	suite.now = suite.now.Add(time.Minute)
+	expired := suite.invoke("google_sheets-get-values", "jverce", suite.props("abc"))
+	require.Equal(ActionCacheMiss, expired.Cache.Status)
+	require.Equal("Run 4", expired.Summary)
+}
+
+func (suite *actionCacheTestSuite) TestNotCached() {
+	require := suite.Require()
+
+	// actions not enabled are not cached
+	for range 2 {
+		require.Nil(suite.invoke("google_sheets-update-row", "jverce", suite.props("abc")).Cache)
+	}
+	require.EqualValues(2, suite.runs.Load())
+
+	// neither are runs with a file stash
+	for range 2 {
+		result := suite.invoke("google_sheets-get-values", "jverce", suite.props("abc"), WithNewStash())
+		require.Equal(ActionCacheBypass, result.Cache.Status)
+	}
+	require.EqualValues(4, suite.runs.Load())
+
+	// nor failed runs
+	suite.fail.Store(true)
+	_, err := suite.pipedreamClient.InvokeAction(suite.ctx, "google_sheets-get-values", "jverce", suite.props("abc"), "")
+	var actionErr *ActionError
+	require.ErrorAs(err, &actionErr)
+	suite.fail.Store(false)
+	require.Equal(ActionCacheMiss, suite.invoke("google_sheets-get-values", "jverce", suite.props("abc")).Cache.Status)
+
+	suite.cache.Enable(0, "google_sheets-get-values")
+	require.Nil(suite.invoke("google_sheets-get-values", "jverce", suite.props("abc")).Cache)
+}
+
+func (suite *actionCacheTestSuite) TestConcurrentMisses() {
+	require := suite.Require()
+	suite.gate = make(chan struct{})
+
+	results := make(chan *ActionRunResult, 5)
+	for range 5 {
+		go func() {
+			result, err := suite.pipedreamClient.InvokeAction(suite.ctx, "google_sheets-get-values", "jverce", suite.props("abc"), "")
+			require.NoError(err)
+			results <- result
+		}()
+	}
+	// a caller giving up does not fail the shared run
+	ctx, cancel := context.WithTimeout(suite.ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err := suite.pipedreamClient.InvokeAction(ctx, "google_sheets-get-values", "jverce", suite.props("abc"), "")
+	require.ErrorIs(err, context.DeadlineExceeded)
+	close(suite.gate)
+
+	for range 5 {
+		result := <-results
+		require.Equal("Run 1", result.Summary)
+		// every caller gets its own copy
+		result.Exports["$summary"] = "changed"
+	}
+	require.EqualValues(1, suite.runs.Load())
+	hit := suite.invoke("google_sheets-get-values", "jverce", suite.props("abc"))
+	require.Equal(ActionCacheHit, hit.Cache.Status)
+	require.Equal("Run 1", hit.Exports["$summary"])
+}
+
+func (suite *actionCacheTestSuite) TestInvalidation() {
+	require := suite.Require()
+
+	suite.invoke("google_sheets-get-values", "jverce", suite.props("abc"))
+	suite.invoke("google_sheets-get-values", "jverce", suite.props("def"))
+	suite.invoke("google_sheets-get-values", "other-user", suite.props("abc"))
+
+	require.NoError(suite.pipedreamClient.DeleteAccount(suite.ctx, "apn_abc"))
+	require.Equal(ActionCacheMiss, suite.invoke("google_sheets-get-values", "jverce", suite.props("abc")).Cache.Status)
+	require.Equal(ActionCacheHit, suite.invoke("google_sheets-get-values", "jverce", suite.props("def")).Cache.Status)
+
+	require.NoError(suite.pipedreamClient.DeleteEndUser(suite.ctx, "jverce"))
+	require.Equal(ActionCacheMiss, suite.invoke("google_sheets-get-values", "jverce", suite.props("def")).Cache.Status)
+
+	removed, err := suite.cache.InvalidateUser(suite.ctx, "other-user")
+	require.NoError(err)
+	require.Equal(0, removed)
+
+	// deleting the accounts of an app drops the results of each of them
+	suite.invoke("google_sheets-get-values", "jverce", suite.props("def"))
+	suite.invoke("google_sheets-get-values", "other-user", suite.props("ghi"))
+	require.NoError(suite.pipedreamClient.DeleteAccounts(suite.ctx, "google_sheets"))
+	require.Equal(ActionCacheMiss, suite.invoke("google_sheets-get-values", "jverce", suite.props("def")).Cache.Status)
+	require.Equal(ActionCacheHit, suite.invoke("google_sheets-get-values", "other-user", suite.props("ghi")).Cache.Status)
+}
+
+func (suite *actionCacheTestSuite) TestInvalidatedDuringRun() {
+	require := suite.Require()
+	suite.gate = make(chan struct{})
+
+	done := make(chan *ActionRunResult)
+	go func() {
+		done <- suite.invoke("google_sheets-get-values", "jverce", suite.props("abc"))
+	}()
+	require.Eventually(func() bool { return suite.runs.Load() == 1 }, time.Second, time.Millisecond)
+	require.NoError(suite.pipedreamClient.DeleteEndUser(suite.ctx, "jverce"))
+	close(suite.gate)
+	require.Equal(ActionCacheMiss, (<-done).Cache.Status)
+
+	// the result of the run started before the deletion is not stored
+	require.Equal(ActionCacheMiss, suite.invoke("google_sheets-get-values", "jverce", suite.props("abc")).Cache.Status)
+	require.EqualValues(2, suite.runs.Load())
+}
+
+type failingActionCacheStore struct {
+	*MemoryActionCacheStore
+}
+
+func (s failingActionCacheStore) Get(context.Context, string) (*ActionCacheEntry, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (suite *actionCacheTestSuite) TestStoreErrors() {
+	require := suite.Require()
+
+	var reported []error
+	suite.cache = NewActionCache(failingActionCacheStore{NewMemoryActionCacheStore(10)}).
+		Enable(time.Minute, "google_sheets-get-values")
+	suite.cache.OnError = func(err error) { reported = append(reported, err) }
+	suite.pipedreamClient.ActionCache = suite.cache
+
+	for range 2 {
+		require.Equal(ActionCacheMiss, suite.invoke("google_sheets-get-values", "jverce", suite.props("abc")).Cache.Status)
+	}
+	require.EqualValues(2, suite.runs.Load())
+	require.Len(reported, 2)
+}
+
+func (suite *actionCacheTestSuite) TestMemoryStoreEviction() {
+	require := suite.Require()
+	store := NewMemoryActionCacheStore(2)
+
+	for _, key := range []string{"a", "b"} {
+		require.NoError(store.Set(suite.ctx, &ActionCacheEntry{Key: key}))
+	}
+	_, _ = store.Get(suite.ctx, "a")
+	require.NoError(store.Set(suite.ctx, &ActionCacheEntry{Key: "c"}))
+
+	require.Equal(2, store.Len())
+	entry, err := store.Get(suite.ctx, "b")
+	require.NoError(err)
+	require.Nil(entry)
+	entry, err = store.Get(suite.ctx, "a")
+	require.NoError(err)
+	require.NotNil(entry)
+}
+
+func TestActionCache(t *testing.T) {
+	suite.Run(t, new(actionCacheTestSuite))
+}
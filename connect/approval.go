@@ -0,0 +1,522 @@
+package connect
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ApprovalNotFoundErr   error = errors.New("approval ticket does not exist")
+	ApprovalNotPendingErr error = errors.New("approval ticket is not pending")
+	ApprovalExpiredErr    error = errors.New("approval ticket expired")
+)
+
+type ApprovalStatus string
+
+const (
+	ApprovalPending ApprovalStatus = "pending"
+	// ApprovalApproved tickets are being executed
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalExecuted ApprovalStatus = "executed"
+	ApprovalFailed   ApprovalStatus = "failed"
+	ApprovalRejected ApprovalStatus = "rejected"
+	ApprovalExpired  ApprovalStatus = "expired"
+)
+
+// ApprovalEvent is an entry of the audit trail of a ticket
+type ApprovalEvent struct {
+	At     time.Time      `json:"at"`
+	Status ApprovalStatus `json:"status"`
+	// Actor is the requester or approver, empty for events of the queue itself
+	Actor string `json:"actor,omitempty"`
+	Note  string `json:"note,omitempty"`
+}
+
+// ApprovalTicket is an action invocation waiting for or past a human decision
+type ApprovalTicket struct {
+	ID             string           `json:"id"`
+	Status         ApprovalStatus   `json:"status"`
+	ComponentKey   string           `json:"component_key"`
+	ExternalUserID string           `json:"external_user_id"`
+	Props          ConfiguredProps  `json:"configured_props"`
+	DynamicPropsID string           `json:"dynamic_props_id,omitempty"`
+	Requester      string           `json:"requester"`
+	CreatedAt      time.Time        `json:"created_at"`
+	ExpiresAt      time.Time        `json:"expires_at"`
+	Result         *ActionRunResult `json:"result,omitempty"`
+	Error          string           `json:"error,omitempty"`
+	Audit          []ApprovalEvent  `json:"audit"`
+}
+
+// Expired reports whether a pending ticket can no longer be approved at now
+func (t *ApprovalTicket) Expired(now time.Time) bool {
+	return t.Status == ApprovalPending && !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+func (t *ApprovalTicket) record(now time.Time, status ApprovalStatus, actor string, note string) {
+	t.Status = status
+	t.Audit = append(t.Audit, ApprovalEvent{At: now, Status: status, Actor: actor, Note: note})
+}
+
+// clone deep copies the ticket through JSON, numbers in the props are kept as
+// json.Number so integers survive
+func (t *ApprovalTicket) clone() (*ApprovalTicket, error) {
+	bs, err := json.Marshal(t)
+	if err != nil {
+		return nil, fmt.Errorf("encoding approval ticket %s: %w", t.ID, err)
+	}
+	out, err := decodeApprovalTicket(bs)
+	if err != nil {
+		return nil, fmt.Errorf("decoding approval ticket %s: %w", t.ID, err)
+	}
+	if t.Result != nil && t.Result.Cache != nil {
+		info := *t.Result.Cache
+		out.Result.Cache = &info
+	}
+	return out, nil
+}
+
+func decodeApprovalTicket(bs []byte) (*ApprovalTicket, error) {
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var out ApprovalTicket
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ApprovalStore persists approval tickets. Update must apply fn atomically,
+// an error returned by fn leaves the ticket unchanged
+type ApprovalStore interface {
+	Create(ctx context.Context, ticket *ApprovalTicket) error
+	Get(ctx context.Context, id string) (*ApprovalTicket, error)
+	Update(ctx context.Context, id string, fn func(*ApprovalTicket) error) (*ApprovalTicket, error)
+	// List returns the tickets with status, all tickets for the empty status
+	List(ctx context.Context, status ApprovalStatus) ([]*ApprovalTicket, error)
+}
+
+// MemoryApprovalStore keeps tickets in memory
+type MemoryApprovalStore struct {
+	mu      sync.Mutex
+	tickets map[string]*ApprovalTicket
+}
+
+func NewMemoryApprovalStore() *MemoryApprovalStore {
+	return &MemoryApprovalStore{tickets: map[string]*ApprovalTicket{}}
+}
+
+func (s *MemoryApprovalStore) Create(_ context.Context, ticket *ApprovalTicket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tickets[ticket.ID]; ok {
+		return fmt.Errorf("approval ticket %s already exists", ticket.ID)
+	}
+	stored, err := ticket.clone()
+	if err != nil {
+		return err
+	}
+	s.tickets[ticket.ID] = stored
+	return nil
+}
+
+func (s *MemoryApprovalStore) Get(_ context.Context, id string) (*ApprovalTicket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[id]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", id, ApprovalNotFoundErr)
+	}
+	return ticket.clone()
+}
+
+func (s *MemoryApprovalStore) Update(
+	_ context.Context,
+	id string,
+	fn func(*ApprovalTicket) error,
+) (*ApprovalTicket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[id]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", id, ApprovalNotFoundErr)
+	}
+
+	updated, err := updateTicket(ticket, fn)
+	if err != nil {
+		return updated, err
+	}
+	stored, err := updated.clone()
+	if err != nil {
+		return nil, err
+	}
+	s.tickets[id] = stored
+	return updated, nil
+}
+
+func (s *MemoryApprovalStore) List(_ context.Context, status ApprovalStatus) ([]*ApprovalTicket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return listTickets(s.tickets, status)
+}
+
+// updateTicket applies fn to a copy of ticket. When fn fails the error is
+// returned along with an unchanged copy
+func updateTicket(ticket *ApprovalTicket, fn func(*ApprovalTicket) error) (*ApprovalTicket, error) {
+	updated, err := ticket.clone()
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(updated); err != nil {
+		unchanged, cloneErr := ticket.clone()
+		return unchanged, errors.Join(err, cloneErr)
+	}
+	return updated, nil
+}
+
+func listTickets(tickets map[string]*ApprovalTicket, status ApprovalStatus) ([]*ApprovalTicket, error) {
+	var out []*ApprovalTicket
+	for _, ticket := range tickets {
+		if status == "" || ticket.Status == status {
+			clone, err := ticket.clone()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, clone)
+		}
+	}
+	slices.SortFunc(out, func(a, b *ApprovalTicket) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return out, nil
+}
+
+// FileApprovalStore keeps tickets in a JSON file, rewritten on every change.
+// It is meant for a single process
+type FileApprovalStore struct {
+	path    string
+	mu      sync.Mutex
+	tickets map[string]*ApprovalTicket
+}
+
+// OpenFileApprovalStore loads the tickets stored at path, a missing file is an empty store
+func OpenFileApprovalStore(path string) (*FileApprovalStore, error) {
+	s := &FileApprovalStore{path: path, tickets: map[string]*ApprovalTicket{}}
+
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading approval store %s: %w", path, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var tickets []*ApprovalTicket
+	if err := dec.Decode(&tickets); err != nil {
+		return nil, fmt.Errorf("decoding approval store %s: %w", path, err)
+	}
+	for _, ticket := range tickets {
+		s.tickets[ticket.ID] = ticket
+	}
+	return s, nil
+}
+
+func (s *FileApprovalStore) Create(_ context.Context, ticket *ApprovalTicket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tickets[ticket.ID]; ok {
+		return fmt.Errorf("approval ticket %s already exists", ticket.ID)
+	}
+	stored, err := ticket.clone()
+	if err != nil {
+		return err
+	}
+	s.tickets[ticket.ID] = stored
+	if err := s.save(); err != nil {
+		delete(s.tickets, ticket.ID)
+		return err
+	}
+	return nil
+}
+
+func (s *FileApprovalStore) Get(_ context.Context, id string) (*ApprovalTicket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[id]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", id, ApprovalNotFoundErr)
+	}
+	return ticket.clone()
+}
+
+func (s *FileApprovalStore) Update(
+	_ context.Context,
+	id string,
+	fn func(*ApprovalTicket) error,
+) (*ApprovalTicket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[id]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", id, ApprovalNotFoundErr)
+	}
+
+	updated, err := updateTicket(ticket, fn)
+	if err != nil {
+		return updated, err
+	}
+	stored, err := updated.clone()
+	if err != nil {
+		return nil, err
+	}
+	s.tickets[id] = stored
+	if err := s.save(); err != nil {
+		s.tickets[id] = ticket
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *FileApprovalStore) List(_ context.Context, status ApprovalStatus) ([]*ApprovalTicket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return listTickets(s.tickets, status)
+}
+
+// save writes all tickets to a sibling file and renames it over the store,
+// the caller holds s.mu
+func (s *FileApprovalStore) save() error {
+	tickets, err := listTickets(s.tickets, "")
+	if err != nil {
+		return fmt.Errorf("encoding approval store: %w", err)
+	}
+	bs, err := json.MarshalIndent(tickets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding approval store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing approval store %s: %w", s.path, err)
+	}
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing approval store %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing approval store %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("writing approval store %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// InvokeApprovalRequest is an action invocation to hold for approval
+type InvokeApprovalRequest struct {
+	ComponentKey   string
+	ExternalUserID string
+	Props          ConfiguredProps
+	DynamicPropsID string
+	// Requester identifies who asked for the invocation, e.g. an agent or operator
+	Requester string
+	Note      string
+	// TTL overrides the TTL of the queue
+	TTL time.Duration
+}
+
+// ApprovalQueue holds action invocations until a human approves or rejects them
+type ApprovalQueue struct {
+	client *Client
+	store  ApprovalStore
+	// TTL is how long tickets stay approvable, defaults to 24 hours
+	TTL time.Duration
+	now func() time.Time
+}
+
+func (c *Client) NewApprovalQueue(store ApprovalStore) *ApprovalQueue {
+	return &ApprovalQueue{client: c, store: store, TTL: 24 * time.Hour, now: time.Now}
+}
+
+// RequestInvoke stores a pending ticket for the invocation, nothing runs until
+// it is approved. The props must be encodable as JSON, they are stored and
+// invoked in their JSON form
+func (q *ApprovalQueue) RequestInvoke(ctx context.Context, req InvokeApprovalRequest) (*ApprovalTicket, error) {
+	if req.ComponentKey == "" || req.ExternalUserID == "" {
+		return nil, errors.New("component key and external user ID are required")
+	}
+	props, err := approvalProps(req.Props)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newApprovalID()
+	if err != nil {
+		return nil, err
+	}
+	ttl := cmp.Or(req.TTL, q.TTL)
+	now := q.now().UTC()
+
+	ticket := &ApprovalTicket{
+		ID:             id,
+		ComponentKey:   req.ComponentKey,
+		ExternalUserID: req.ExternalUserID,
+		Props:          props,
+		DynamicPropsID: req.DynamicPropsID,
+		Requester:      req.Requester,
+		CreatedAt:      now,
+	}
+	if ttl > 0 {
+		ticket.ExpiresAt = now.Add(ttl)
+	}
+	ticket.record(now, ApprovalPending, req.Requester, req.Note)
+
+	if err := q.store.Create(ctx, ticket); err != nil {
+		return nil, fmt.Errorf("storing approval ticket: %w", err)
+	}
+	return ticket, nil
+}
+
+func (q *ApprovalQueue) Get(ctx context.Context, id string) (*ApprovalTicket, error) {
+	return q.store.Get(ctx, id)
+}
+
+// Pending lists the tickets waiting for a decision, expiring overdue ones
+func (q *ApprovalQueue) Pending(ctx context.Context) ([]*ApprovalTicket, error) {
+	if _, err := q.ExpireOverdue(ctx); err != nil {
+		return nil, err
+	}
+	return q.store.List(ctx, ApprovalPending)
+}
+
+// ExpireOverdue marks pending tickets past their expiry as expired and
+// returns how many were
+func (q *ApprovalQueue) ExpireOverdue(ctx context.Context) (int, error) {
+	pending, err := q.store.List(ctx, ApprovalPending)
+	if err != nil {
+		return 0, fmt.Errorf("listing approval tickets: %w", err)
+	}
+
+	expired := 0
+	for _, ticket := range pending {
+		now := q.now().UTC()
+		if !ticket.Expired(now) {
+			continue
+		}
+		_, err := q.store.Update(ctx, ticket.ID, func(t *ApprovalTicket) error {
+			if !t.Expired(now) {
+				return ApprovalNotPendingErr
+			}
+			t.record(now, ApprovalExpired, "", "")
+			return nil
+		})
+		if errors.Is(err, ApprovalNotPendingErr) {
+			continue
+		}
+		if err != nil {
+			return expired, fmt.Errorf("expiring approval ticket %s: %w", ticket.ID, err)
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// decide moves a pending ticket to status, expired tickets are marked as such
+// and return ApprovalExpiredErr
+func (q *ApprovalQueue) decide(
+	ctx context.Context,
+	id string,
+	status ApprovalStatus,
+	actor string,
+	note string,
+) (*ApprovalTicket, error) {
+	now := q.now().UTC()
+	expired := false
+	ticket, err := q.store.Update(ctx, id, func(t *ApprovalTicket) error {
+		if t.Status != ApprovalPending {
+			return fmt.Errorf("%s is %s: %w", t.ID, t.Status, ApprovalNotPendingErr)
+		}
+		if t.Expired(now) {
+			expired = true
+			t.record(now, ApprovalExpired, "", "")
+			return nil
+		}
+		t.record(now, status, actor, note)
+		return nil
+	})
+	if err != nil {
+		return ticket, err
+	}
+	if expired {
+		return ticket, fmt.Errorf("%s expired at %s: %w", id, ticket.ExpiresAt.Format(time.RFC3339), ApprovalExpiredErr)
+	}
+	return ticket, nil
+}
+
+// Approve runs the invocation of a pending ticket with InvokeAction and records
+// the outcome. The error of the invocation is returned along with the ticket
+func (q *ApprovalQueue) Approve(ctx context.Context, id string, approver string, note string) (*ApprovalTicket, error) {
+	ticket, err := q.decide(ctx, id, ApprovalApproved, approver, note)
+	if err != nil {
+		return ticket, err
+	}
+
+	result, invokeErr := q.client.InvokeAction(ctx,
+		ticket.ComponentKey, ticket.ExternalUserID, ticket.Props, ticket.DynamicPropsID)
+
+	now := q.now().UTC()
+	ticket, err = q.store.Update(ctx, id, func(t *ApprovalTicket) error {
+		t.Result = result
+		if invokeErr != nil {
+			t.Error = invokeErr.Error()
+			t.record(now, ApprovalFailed, "", invokeErr.Error())
+			return nil
+		}
+		t.record(now, ApprovalExecuted, "", result.Summary)
+		return nil
+	})
+	if err != nil {
+		return ticket, errors.Join(invokeErr, fmt.Errorf("recording outcome of approval ticket %s: %w", id, err))
+	}
+	return ticket, invokeErr
+}
+
+// Reject discards the invocation of a pending ticket
+func (q *ApprovalQueue) Reject(ctx context.Context, id string, approver string, note string) (*ApprovalTicket, error) {
+	return q.decide(ctx, id, ApprovalRejected, approver, note)
+}
+
+// approvalProps returns props in their JSON form, numbers as json.Number
+func approvalProps(props ConfiguredProps) (ConfiguredProps, error) {
+	if props == nil {
+		return nil, nil
+	}
+	normalized, err := normalizeJSON(props)
+	if err != nil {
+		return nil, fmt.Errorf("encoding props: %w", err)
+	}
+	return ConfiguredProps(normalized.(map[string]any)), nil
+}
+
+func newApprovalID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating approval ticket ID: %w", err)
+	}
+	return "apr_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,232 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type approvalTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+	invoked         []InvokeActionRequest
+	now             time.Time
+}
+
+func (suite *approvalTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.invoked = nil
+	suite.now = time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/project-abc/actions/run":
+			var request InvokeActionRequest
+			suite.Require().NoError(json.NewDecoder(r.Body).Decode(&request))
+			suite.invoked = append(suite.invoked, request)
+			if request.ConfiguredProps["to"] == "bounce@example.com" {
+				_, _ = fmt.Fprint(w, `{"os": [{"k": "error", "err": {"name": "Error", "message": "mailbox unavailable"}}]}`)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"exports": {"$summary": "Sent email"}, "ret": {"id": "msg_1"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+}
+
+func (suite *approvalTestSuite) queue(store ApprovalStore) *ApprovalQueue {
+	q := suite.pipedreamClient.NewApprovalQueue(store)
+	q.TTL = time.Hour
+	q.now = func() time.Time { return suite.now }
+	return q
+}
+
+func sendEmail(to string) InvokeApprovalRequest {
+	return InvokeApprovalRequest{
+		ComponentKey:   "gmail-send-email",
+		ExternalUserID: "jverce",
+		Props:          ConfiguredProps{"to": to, "subject": "Invoice"},
+		Requester:      "agent:billing",
+		Note:           "monthly invoice",
+	}
+}
+
+func (suite *approvalTestSuite) TestApprove() {
+	require := suite.Require()
+	q := suite.queue(NewMemoryApprovalStore())
+
+	ticket, err := q.RequestInvoke(suite.ctx, sendEmail("jane@example.com"))
+	require.NoError(err)
+	require.Equal(ApprovalPending, ticket.Status)
+	require.Equal(suite.now.Add(time.Hour), ticket.ExpiresAt)
+	require.Empty(suite.invoked)
+
+	pending, err := q.Pending(suite.ctx)
+	require.NoError(err)
+	require.Len(pending, 1)
+	require.Equal(ticket.ID, pending[0].ID)
+
+	suite.now = suite.now.Add(10 * time.Minute)
+	approved, err := q.Approve(suite.ctx, ticket.ID, "ops:jane", "looks right")
+	require.NoError(err)
+	require.Equal(ApprovalExecuted, approved.Status)
+	require.Equal("Sent email", approved.Result.Summary)
+	require.Len(suite.invoked, 1)
+	require.Equal("gmail-send-email", suite.invoked[0].ID)
+	require.Equal("jane@example.com", suite.invoked[0].ConfiguredProps["to"])
+
+	require.Equal([]ApprovalEvent{
+		{At: suite.now.Add(-10 * time.Minute), Status: ApprovalPending, Actor: "agent:billing", Note: "monthly invoice"},
+		{At: suite.now, Status: ApprovalApproved, Actor: "ops:jane", Note: "looks right"},
+		{At: suite.now, Status: ApprovalExecuted, Note: "Sent email"},
+	}, approved.Audit)
+
+	// a ticket runs at most once
+	_, err = q.Approve(suite.ctx, ticket.ID, "ops:jane", "")
+	require.ErrorIs(err, ApprovalNotPendingErr)
+	_, err = q.Reject(suite.ctx, ticket.ID, "ops:jane", "")
+	require.ErrorIs(err, ApprovalNotPendingErr)
+	require.Len(suite.invoked, 1)
+
+	_, err = q.Approve(suite.ctx, "apr_missing", "ops:jane", "")
+	require.ErrorIs(err, ApprovalNotFoundErr)
+}
+
+func (suite *approvalTestSuite) TestRejectFailAndExpire() {
+	require := suite.Require()
+	q := suite.queue(NewMemoryApprovalStore())
+
+	rejected, err := q.RequestInvoke(suite.ctx, sendEmail("ceo@example.com"))
+	require.NoError(err)
+	ticket, err := q.Reject(suite.ctx, rejected.ID, "ops:jane", "wrong recipient")
+	require.NoError(err)
+	require.Equal(ApprovalRejected, ticket.Status)
+	require.Len(ticket.Audit, 2)
+
+	failing, err := q.RequestInvoke(suite.ctx, sendEmail("bounce@example.com"))
+	require.NoError(err)
+	ticket, err = q.Approve(suite.ctx, failing.ID, "ops:jane", "")
+	var actionErr *ActionError
+	require.ErrorAs(err, &actionErr)
+	require.Equal(ApprovalFailed, ticket.Status)
+	require.Equal(err.Error(), ticket.Error)
+
+	short := sendEmail("jane@example.com")
+	short.TTL = time.Minute
+	expiring, err := q.RequestInvoke(suite.ctx, short)
+	require.NoError(err)
+	lasting, err := q.RequestInvoke(suite.ctx, sendEmail("joe@example.com"))
+	require.NoError(err)
+
+	suite.now = suite.now.Add(2 * time.Minute)
+	ticket, err = q.Approve(suite.ctx, expiring.ID, "ops:jane", "")
+	require.ErrorIs(err, ApprovalExpiredErr)
+	require.Equal(ApprovalExpired, ticket.Status)
+	require.Len(suite.invoked, 1)
+
+	pending, err := q.Pending(suite.ctx)
+	require.NoError(err)
+	require.Len(pending, 1)
+	require.Equal(lasting.ID, pending[0].ID)
+
+	suite.now = suite.now.Add(time.Hour)
+	expired, err := q.ExpireOverdue(suite.ctx)
+	require.NoError(err)
+	require.Equal(1, expired)
+	ticket, err = q.Get(suite.ctx, lasting.ID)
+	require.NoError(err)
+	require.Equal(ApprovalExpired, ticket.Status)
+}
+
+func (suite *approvalTestSuite) TestFileStore() {
+	require := suite.Require()
+	path := filepath.Join(suite.T().TempDir(), "approvals.json")
+
+	store, err := OpenFileApprovalStore(path)
+	require.NoError(err)
+	q := suite.queue(store)
+
+	first, err := q.RequestInvoke(suite.ctx, sendEmail("jane@example.com"))
+	require.NoError(err)
+	second, err := q.RequestInvoke(suite.ctx, sendEmail("joe@example.com"))
+	require.NoError(err)
+	_, err = q.Reject(suite.ctx, second.ID, "ops:jane", "")
+	require.NoError(err)
+
+	// a restarted process approves the ticket stored by the first one
+	reopened, err := OpenFileApprovalStore(path)
+	require.NoError(err)
+	all, err := reopened.List(suite.ctx, "")
+	require.NoError(err)
+	require.Len(all, 2)
+
+	ticket, err := suite.queue(reopened).Approve(suite.ctx, first.ID, "ops:joe", "")
+	require.NoError(err)
+	require.Equal(ApprovalExecuted, ticket.Status)
+	require.JSONEq(`{"id": "msg_1"}`, string(ticket.Result.Ret))
+
+	rejected, err := reopened.List(suite.ctx, ApprovalRejected)
+	require.NoError(err)
+	require.Len(rejected, 1)
+	require.Equal(second.ID, rejected[0].ID)
+
+	_, err = q.RequestInvoke(suite.ctx, InvokeApprovalRequest{ComponentKey: "gmail-send-email"})
+	require.Error(err)
+}
+
+func (suite *approvalTestSuite) TestProps() {
+	require := suite.Require()
+	store := NewMemoryApprovalStore()
+	q := suite.queue(store)
+
+	req := sendEmail("jane@example.com")
+	req.Props["retries"] = int64(9007199254740993)
+	ticket, err := q.RequestInvoke(suite.ctx, req)
+	require.NoError(err)
+	stored, err := q.Get(suite.ctx, ticket.ID)
+	require.NoError(err)
+	require.Equal(json.Number("9007199254740993"), stored.Props["retries"])
+
+	// props JSON cannot encode are rejected up front
+	for _, value := range []any{math.NaN(), make(chan int)} {
+		req := sendEmail("jane@example.com")
+		req.Props["bad"] = value
+		_, err := q.RequestInvoke(suite.ctx, req)
+		require.ErrorContains(err, "encoding props")
+	}
+	all, err := store.List(suite.ctx, "")
+	require.NoError(err)
+	require.Len(all, 1)
+
+	// the cache info of the result is not part of the JSON but kept by the store
+	updated, err := store.Update(suite.ctx, ticket.ID, func(t *ApprovalTicket) error {
+		t.Result = &ActionRunResult{Summary: "cached", Cache: &ActionCacheInfo{Status: ActionCacheHit, Key: "k"}}
+		return nil
+	})
+	require.NoError(err)
+	require.Equal("k", updated.Result.Cache.Key)
+	stored, err = q.Get(suite.ctx, ticket.ID)
+	require.NoError(err)
+	require.Equal(ActionCacheHit, stored.Result.Cache.Status)
+}
+
+func TestApproval(t *testing.T) {
+	suite.Run(t, new(approvalTestSuite))
+}
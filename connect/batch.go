@@ -0,0 +1,461 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrorCategory groups failed invocations in a BatchSummary
+type ErrorCategory string
+
+const (
+	ErrorCategoryAction       ErrorCategory = "action"
+	ErrorCategoryInvalidProps ErrorCategory = "invalid_props"
+	ErrorCategoryRateLimited  ErrorCategory = "rate_limited"
+	ErrorCategoryServer       ErrorCategory = "server"
+	ErrorCategoryClient       ErrorCategory = "client"
+	ErrorCategoryNetwork      ErrorCategory = "network"
+	ErrorCategoryCanceled     ErrorCategory = "canceled"
+	ErrorCategoryUnknown      ErrorCategory = "unknown"
+)
+
+// ClassifyError returns the category of an error returned by InvokeAction,
+// the empty category for nil
+func ClassifyError(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+
+	var actionErr *ActionError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorCategoryCanceled
+	case errors.As(err, &actionErr):
+		return ErrorCategoryAction
+	case errors.Is(err, InvalidPropsErr):
+		return ErrorCategoryInvalidProps
+	}
+
+	if code, ok := HTTPStatusCode(err); ok {
+		switch {
+		case code == http.StatusTooManyRequests:
+			return ErrorCategoryRateLimited
+		case code >= 500:
+			return ErrorCategoryServer
+		case code >= 400:
+			return ErrorCategoryClient
+		}
+		return ErrorCategoryUnknown
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorCategoryNetwork
+	}
+	return ErrorCategoryUnknown
+}
+
+// IsTransient reports whether retrying the failed request may succeed: network
+// errors, rate limiting and server errors
+func IsTransient(err error) bool {
+	if code, ok := HTTPStatusCode(err); ok && code == http.StatusRequestTimeout {
+		return true
+	}
+	switch ClassifyError(err) {
+	case ErrorCategoryRateLimited, ErrorCategoryServer, ErrorCategoryNetwork:
+		return true
+	}
+	return false
+}
+
+// BatchJob is one action invocation of a batch
+type BatchJob struct {
+	// ID is optional and copied to the result
+	ID             string
+	ExternalUserID string
+	ComponentKey   string
+	Props          ConfiguredProps
+	DynamicPropsID string
+	Options        []InvokeActionOption
+}
+
+type BatchResult struct {
+	Job BatchJob
+	// Index is the position of the job in the input stream
+	Index    int
+	Result   *ActionRunResult
+	Err      error
+	Category ErrorCategory
+	Attempts int
+	Duration time.Duration
+}
+
+// BatchProgress counts the jobs of a running batch
+type BatchProgress struct {
+	Submitted int `json:"submitted"`
+	Queued    int `json:"queued"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Retries   int `json:"retries"`
+}
+
+// Done is the number of finished jobs
+func (p BatchProgress) Done() int {
+	return p.Succeeded + p.Failed
+}
+
+type BatchSummary struct {
+	Total      int                   `json:"total"`
+	Succeeded  int                   `json:"succeeded"`
+	Failed     int                   `json:"failed"`
+	Retries    int                   `json:"retries"`
+	ByCategory map[ErrorCategory]int `json:"by_category"`
+	Duration   time.Duration         `json:"duration"`
+}
+
+type BatchOptions struct {
+	// Concurrency is the number of parallel invocations, defaults to 8
+	Concurrency int
+	// PerUserConcurrency is the number of parallel invocations of one external
+	// user, defaults to 1. Users with queued jobs take turns for free slots
+	PerUserConcurrency int
+	// MaxAttempts includes the first attempt, defaults to 3. Only transient
+	// failures are retried, see IsTransient
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled on every further
+	// retry and jittered, defaults to 500ms
+	Backoff time.Duration
+	// QueueSize bounds the jobs read ahead of the running ones, defaults to 1024.
+	// Fairness only applies between users within the queue
+	QueueSize int
+	// Ordered emits results in input order instead of completion order
+	Ordered bool
+	// OnProgress is called after every finished job, calls are not concurrent
+	OnProgress func(BatchProgress)
+}
+
+// BatchInvoker runs streams of action invocations, see Start
+type BatchInvoker struct {
+	client *Client
+	opts   BatchOptions
+}
+
+func (c *Client) NewBatchInvoker(opts BatchOptions) *BatchInvoker {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 8
+	}
+	if opts.PerUserConcurrency <= 0 {
+		opts.PerUserConcurrency = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 500 * time.Millisecond
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	return &BatchInvoker{client: c, opts: opts}
+}
+
+type batchItem struct {
+	index int
+	job   BatchJob
+}
+
+// BatchRun is a batch started by BatchInvoker.Start
+type BatchRun struct {
+	invoker *BatchInvoker
+	started time.Time
+	results chan BatchResult
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queues    map[string][]batchItem
+	users     []string // users with queued jobs, in turn order
+	next      int
+	running   map[string]int
+	inputDone bool
+	progress  BatchProgress
+	summary   BatchSummary
+}
+
+// Start reads jobs until the channel is closed or ctx is done and runs them.
+// Every job read produces exactly one result on Results, jobs still queued
+// when ctx is done fail with the context error
+func (b *BatchInvoker) Start(ctx context.Context, jobs <-chan BatchJob) *BatchRun {
+	run := &BatchRun{
+		invoker: b,
+		started: time.Now(),
+		results: make(chan BatchResult),
+		queues:  map[string][]batchItem{},
+		running: map[string]int{},
+		summary: BatchSummary{ByCategory: map[ErrorCategory]int{}},
+	}
+	run.cond = sync.NewCond(&run.mu)
+
+	stop := context.AfterFunc(ctx, func() {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		run.cond.Broadcast()
+	})
+
+	go run.read(ctx, jobs)
+
+	finished := make(chan BatchResult, b.opts.Concurrency)
+	var wg sync.WaitGroup
+	for range b.opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run.work(ctx, finished)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		stop()
+		close(finished)
+	}()
+
+	go run.collect(finished)
+	return run
+}
+
+// Results streams the results, it is closed after the last one. It must be
+// drained, otherwise the batch stalls
+func (r *BatchRun) Results() <-chan BatchResult {
+	return r.results
+}
+
+func (r *BatchRun) Progress() BatchProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.progress
+}
+
+// Summary is final once Results is closed
+func (r *BatchRun) Summary() BatchSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summary := r.summary
+	summary.ByCategory = make(map[ErrorCategory]int, len(r.summary.ByCategory))
+	for category, n := range r.summary.ByCategory {
+		summary.ByCategory[category] = n
+	}
+	return summary
+}
+
+// Wait discards the remaining results and returns the final summary
+func (r *BatchRun) Wait() BatchSummary {
+	for range r.results {
+	}
+	return r.Summary()
+}
+
+func (r *BatchRun) read(ctx context.Context, jobs <-chan BatchJob) {
+	defer func() {
+		r.mu.Lock()
+		r.inputDone = true
+		r.cond.Broadcast()
+		r.mu.Unlock()
+	}()
+
+	for index := 0; ; index++ {
+		r.mu.Lock()
+		for r.progress.Queued >= r.invoker.opts.QueueSize && ctx.Err() == nil {
+			r.cond.Wait()
+		}
+		r.mu.Unlock()
+
+		var job BatchJob
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case job, ok = <-jobs:
+			if !ok {
+				return
+			}
+		}
+
+		r.mu.Lock()
+		user := job.ExternalUserID
+		if len(r.queues[user]) == 0 {
+			r.users = append(r.users, user)
+		}
+		r.queues[user] = append(r.queues[user], batchItem{index: index, job: job})
+		r.progress.Submitted++
+		r.progress.Queued++
+		r.cond.Broadcast()
+		r.mu.Unlock()
+	}
+}
+
+// take removes the next job of the first user in turn order below the per
+// user limit, the caller holds r.mu
+func (r *BatchRun) take() (batchItem, bool) {
+	for i := range len(r.users) {
+		pos := (r.next + i) % len(r.users)
+		user := r.users[pos]
+		if r.running[user] >= r.invoker.opts.PerUserConcurrency {
+			continue
+		}
+
+		item := r.queues[user][0]
+		r.queues[user] = r.queues[user][1:]
+		r.next = pos + 1
+		if len(r.queues[user]) == 0 {
+			delete(r.queues, user)
+			r.users = slices.Delete(r.users, pos, pos+1)
+			r.next = pos
+		}
+		if len(r.users) > 0 {
+			r.next %= len(r.users)
+		} else {
+			r.next = 0
+		}
+
+		r.running[user]++
+		r.progress.Queued--
+		r.progress.Running++
+		r.cond.Broadcast()
+		return item, true
+	}
+	return batchItem{}, false
+}
+
+func (r *BatchRun) work(ctx context.Context, finished chan<- BatchResult) {
+	for {
+		r.mu.Lock()
+		item, ok := r.take()
+		for !ok {
+			if r.inputDone && r.progress.Queued == 0 {
+				r.mu.Unlock()
+				return
+			}
+			r.cond.Wait()
+			item, ok = r.take()
+		}
+		r.mu.Unlock()
+
+		result := r.invoke(ctx, item)
+
+		r.mu.Lock()
+		user := item.job.ExternalUserID
+		if r.running[user]--; r.running[user] == 0 {
+			delete(r.running, user)
+		}
+		r.progress.Running--
+		if result.Err == nil {
+			r.progress.Succeeded++
+		} else {
+			r.progress.Failed++
+		}
+		r.cond.Broadcast()
+		r.mu.Unlock()
+
+		finished <- result
+	}
+}
+
+func (r *BatchRun) invoke(ctx context.Context, item batchItem) BatchResult {
+	opts := r.invoker.opts
+	job := item.job
+	result := BatchResult{Job: job, Index: item.index}
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			result.Err = err
+			break
+		}
+
+		result.Attempts = attempt
+		result.Result, result.Err = r.invoker.client.InvokeAction(ctx,
+			job.ComponentKey, job.ExternalUserID, job.Props, job.DynamicPropsID, job.Options...)
+		if result.Err == nil || attempt >= opts.MaxAttempts || !IsTransient(result.Err) {
+			break
+		}
+
+		r.mu.Lock()
+		r.progress.Retries++
+		r.mu.Unlock()
+
+		timer := time.NewTimer(backoff(opts.Backoff, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+
+	result.Category = ClassifyError(result.Err)
+	result.Duration = time.Since(start)
+	return result
+}
+
+// backoff doubles base for every attempt up to a minute, jittered between
+// half and the full delay
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base << (attempt - 1)
+	if delay <= 0 || delay > time.Minute {
+		delay = time.Minute
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func (r *BatchRun) collect(finished <-chan BatchResult) {
+	defer close(r.results)
+
+	pending := map[int]BatchResult{}
+	next := 0
+	for result := range finished {
+		r.mu.Lock()
+		r.summary.Total++
+		if result.Attempts > 1 {
+			// jobs canceled before their first attempt have no attempts
+			r.summary.Retries += result.Attempts - 1
+		}
+		if result.Err == nil {
+			r.summary.Succeeded++
+		} else {
+			r.summary.Failed++
+			r.summary.ByCategory[result.Category]++
+		}
+		r.summary.Duration = time.Since(r.started)
+		progress := r.progress
+		r.mu.Unlock()
+
+		if onProgress := r.invoker.opts.OnProgress; onProgress != nil {
+			onProgress(progress)
+		}
+
+		if !r.invoker.opts.Ordered {
+			r.results <- result
+			continue
+		}
+		pending[result.Index] = result
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			r.results <- ready
+		}
+	}
+
+	r.mu.Lock()
+	r.summary.Duration = time.Since(r.started)
+	r.mu.Unlock()
+}
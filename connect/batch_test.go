@@ -0,0 +1,273 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type batchTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+
+	mu         sync.Mutex
+	attempts   map[string]int
+	starts     []string
+	running    map[string]int
+	maxUser    int
+	maxRunning int
+	total      int
+}
+
+// the props of a job script the fake API: "statuses" are answered to the
+// first attempts, "sleep" delays the answer and "throw" fails the action
+func (suite *batchTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.attempts = map[string]int{}
+	suite.starts = nil
+	suite.running = map[string]int{}
+	suite.maxUser = 0
+	suite.maxRunning = 0
+	suite.total = 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == oathPath {
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+			return
+		}
+
+		var request struct {
+			ExternalUserID  string `json:"external_user_id"`
+			ConfiguredProps struct {
+				Job      string `json:"job"`
+				Statuses []int  `json:"statuses"`
+				Sleep    int    `json:"sleep"`
+				Throw    bool   `json:"throw"`
+			} `json:"configured_props"`
+		}
+		suite.Require().NoError(json.NewDecoder(r.Body).Decode(&request))
+		props := request.ConfiguredProps
+		user := request.ExternalUserID
+
+		suite.mu.Lock()
+		attempt := suite.attempts[props.Job]
+		suite.attempts[props.Job]++
+		suite.starts = append(suite.starts, user)
+		suite.running[user]++
+		suite.total++
+		suite.maxUser = max(suite.maxUser, suite.running[user])
+		suite.maxRunning = max(suite.maxRunning, suite.total)
+		suite.mu.Unlock()
+
+		time.Sleep(time.Duration(props.Sleep) * time.Millisecond)
+
+		suite.mu.Lock()
+		suite.running[user]--
+		suite.total--
+		suite.mu.Unlock()
+
+		switch {
+		case attempt < len(props.Statuses):
+			w.WriteHeader(props.Statuses[attempt])
+			_, _ = fmt.Fprint(w, `{"error": "scripted"}`)
+		case props.Throw:
+			_, _ = fmt.Fprint(w, `{"os": [{"k": "error", "err": {"name": "Error", "message": "boom"}}]}`)
+		default:
+			_, _ = fmt.Fprintf(w, `{"exports": {"$summary": "ok"}, "ret": %q}`, props.Job)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+}
+
+func jobsOf(jobs ...BatchJob) <-chan BatchJob {
+	ch := make(chan BatchJob, len(jobs))
+	for _, job := range jobs {
+		ch <- job
+	}
+	close(ch)
+	return ch
+}
+
+func batchJob(user string, job string, props ConfiguredProps) BatchJob {
+	if props == nil {
+		props = ConfiguredProps{}
+	}
+	props["job"] = job
+	return BatchJob{ID: job, ExternalUserID: user, ComponentKey: "hubspot-sync-contacts", Props: props}
+}
+
+func (suite *batchTestSuite) TestFairnessAndOrder() {
+	require := suite.Require()
+
+	var jobs []BatchJob
+	for i := range 6 {
+		jobs = append(jobs, batchJob("user-a", fmt.Sprintf("a%d", i), ConfiguredProps{"sleep": 10}))
+	}
+	jobs = append(jobs,
+		batchJob("user-b", "b0", ConfiguredProps{"sleep": 10}),
+		batchJob("user-b", "b1", ConfiguredProps{"sleep": 10}),
+		batchJob("user-c", "c0", ConfiguredProps{"sleep": 10}))
+
+	var progressCalls []BatchProgress
+	invoker := suite.pipedreamClient.NewBatchInvoker(BatchOptions{
+		Concurrency: 2,
+		Ordered:     true,
+		OnProgress:  func(p BatchProgress) { progressCalls = append(progressCalls, p) },
+	})
+	run := invoker.Start(suite.ctx, jobsOf(jobs...))
+
+	var indexes []int
+	for result := range run.Results() {
+		require.NoError(result.Err)
+		require.Equal(1, result.Attempts)
+		require.Equal(jobs[result.Index].ID, result.Job.ID)
+		var ret string
+		require.NoError(result.Result.DecodeRet(&ret))
+		require.Equal(result.Job.ID, ret)
+		indexes = append(indexes, result.Index)
+	}
+	require.Equal([]int{0, 1, 2, 3, 4, 5, 6, 7, 8}, indexes)
+
+	// user-a never runs twice at once, so the second slot goes to another user
+	require.Equal(1, suite.maxUser)
+	require.LessOrEqual(suite.maxRunning, 2)
+	require.NotEqual("user-a", suite.starts[1])
+
+	require.Len(progressCalls, 9)
+	require.Equal(9, progressCalls[8].Done())
+	require.Equal(BatchProgress{Submitted: 9, Succeeded: 9}, run.Progress())
+	summary := run.Summary()
+	require.Equal(9, summary.Total)
+	require.Equal(9, summary.Succeeded)
+	require.Empty(summary.ByCategory)
+}
+
+func (suite *batchTestSuite) TestUnordered() {
+	require := suite.Require()
+
+	run := suite.pipedreamClient.NewBatchInvoker(BatchOptions{Concurrency: 2}).Start(suite.ctx, jobsOf(
+		batchJob("user-a", "slow", ConfiguredProps{"sleep": 100}),
+		batchJob("user-b", "fast", nil)))
+
+	first := <-run.Results()
+	require.Equal("fast", first.Job.ID)
+	require.Equal(1, first.Index)
+	require.Equal(2, run.Wait().Succeeded)
+}
+
+func (suite *batchTestSuite) TestRetriesAndCategories() {
+	require := suite.Require()
+
+	run := suite.pipedreamClient.NewBatchInvoker(BatchOptions{
+		Concurrency: 4,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		Ordered:     true,
+	}).Start(suite.ctx, jobsOf(
+		batchJob("user-a", "flaky", ConfiguredProps{"statuses": []int{503, 502}}),
+		batchJob("user-b", "invalid", ConfiguredProps{"statuses": []int{400}}),
+		batchJob("user-c", "throws", ConfiguredProps{"throw": true}),
+		batchJob("user-d", "limited", ConfiguredProps{"statuses": []int{429, 429, 429}})))
+
+	var results []BatchResult
+	for result := range run.Results() {
+		results = append(results, result)
+	}
+	require.Len(results, 4)
+
+	require.NoError(results[0].Err)
+	require.Equal(3, results[0].Attempts)
+
+	require.Equal(ErrorCategoryClient, results[1].Category)
+	require.Equal(1, results[1].Attempts)
+
+	var actionErr *ActionError
+	require.ErrorAs(results[2].Err, &actionErr)
+	require.Equal(ErrorCategoryAction, results[2].Category)
+	require.Equal(1, results[2].Attempts)
+
+	require.Equal(ErrorCategoryRateLimited, results[3].Category)
+	require.Equal(3, results[3].Attempts)
+
+	summary := run.Summary()
+	require.Equal(4, summary.Total)
+	require.Equal(1, summary.Succeeded)
+	require.Equal(3, summary.Failed)
+	require.Equal(4, summary.Retries)
+	require.Equal(map[ErrorCategory]int{
+		ErrorCategoryClient:      1,
+		ErrorCategoryAction:      1,
+		ErrorCategoryRateLimited: 1,
+	}, summary.ByCategory)
+	require.Equal(4, run.Progress().Retries)
+}
+
+func (suite *batchTestSuite) TestCancel() {
+	require := suite.Require()
+	ctx, cancel := context.WithCancel(suite.ctx)
+	defer cancel()
+
+	// the input is never closed, cancelling stops reading it
+	jobs := make(chan BatchJob)
+	run := suite.pipedreamClient.NewBatchInvoker(BatchOptions{}).Start(ctx, jobs)
+	jobs <- batchJob("user-a", "a0", nil)
+	require.NoError((<-run.Results()).Err)
+
+	cancel()
+	summary := run.Wait()
+	require.Equal(1, summary.Total)
+}
+
+func (suite *batchTestSuite) TestCancel_QueuedJobs() {
+	require := suite.Require()
+	ctx, cancel := context.WithCancel(suite.ctx)
+	defer cancel()
+
+	run := suite.pipedreamClient.NewBatchInvoker(BatchOptions{Concurrency: 1}).Start(ctx, jobsOf(
+		batchJob("user-a", "a0", ConfiguredProps{"sleep": 100}),
+		batchJob("user-a", "a1", nil),
+		batchJob("user-a", "a2", nil),
+	))
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	summary := run.Wait()
+	require.Equal(3, summary.Total)
+	require.Equal(0, summary.Retries)
+	require.Equal(3, summary.ByCategory[ErrorCategoryCanceled])
+}
+
+func (suite *batchTestSuite) TestClassifyError() {
+	require := suite.Require()
+
+	require.Equal(ErrorCategory(""), ClassifyError(nil))
+	require.Equal(ErrorCategoryCanceled, ClassifyError(fmt.Errorf("invoking: %w", context.DeadlineExceeded)))
+	require.Equal(ErrorCategoryInvalidProps, ClassifyError(&PropValidationError{}))
+	require.Equal(ErrorCategoryServer, ClassifyError(&StatusError{Expected: 200, StatusCode: 500}))
+	require.True(IsTransient(&StatusError{Expected: 200, StatusCode: http.StatusRequestTimeout}))
+	require.False(IsTransient(&StatusError{Expected: 200, StatusCode: http.StatusNotFound}))
+
+	// nothing listens on the closed server
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	_, err := (&Client{Client: base}).InvokeAction(suite.ctx, "hubspot-sync-contacts", "user-a", ConfiguredProps{}, "")
+	require.Equal(ErrorCategoryNetwork, ClassifyError(err))
+	require.True(IsTransient(err))
+}
+
+func TestBatch(t *testing.T) {
+	suite.Run(t, new(batchTestSuite))
+}
@@ -0,0 +1,302 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type BulkItemStatus string
+
+const (
+	BulkDeleted  BulkItemStatus = "deleted"
+	BulkNotFound BulkItemStatus = "not_found"
+	BulkFailed   BulkItemStatus = "failed"
+	// BulkSkipped items were already completed according to the checkpoint file
+	BulkSkipped BulkItemStatus = "skipped"
+)
+
+type BulkDeleteOptions struct {
+	// Concurrency is the number of parallel delete requests, defaults to 4
+	Concurrency int
+	// RequestsPerSecond limits the request rate, zero means unlimited
+	RequestsPerSecond float64
+	// CheckpointFile is optional. Completed IDs are appended there and skipped
+	// when the same file is passed to a later run. Items that could not be
+	// recorded are reported as failed
+	CheckpointFile string
+}
+
+type BulkDeleteResult struct {
+	ID     string         `json:"id"`
+	Status BulkItemStatus `json:"status"`
+	Error  string         `json:"error,omitempty"`
+	Err    error          `json:"-"`
+}
+
+// BulkDeleteReport holds one result per requested ID, in input order
+type BulkDeleteReport struct {
+	Results  []BulkDeleteResult `json:"results"`
+	Deleted  int                `json:"deleted"`
+	NotFound int                `json:"not_found"`
+	Failed   int                `json:"failed"`
+	Skipped  int                `json:"skipped"`
+}
+
+// Err joins the errors of every failed item
+func (r *BulkDeleteReport) Err() error {
+	var errs []error
+	for _, res := range r.Results {
+		if res.Status == BulkFailed {
+			errs = append(errs, fmt.Errorf("%s: %w", res.ID, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *BulkDeleteReport) FailedIDs() []string {
+	var ids []string
+	for _, res := range r.Results {
+		if res.Status == BulkFailed {
+			ids = append(ids, res.ID)
+		}
+	}
+	return ids
+}
+
+// BulkDeleteEndUsers deletes every end user in externalUserIDs, see DeleteEndUser.
+// Users that no longer exist count as deleted. The returned error is only set when
+// the run could not start or was interrupted; per-user failures are in the report
+func (c *Client) BulkDeleteEndUsers(
+	ctx context.Context,
+	externalUserIDs []string,
+	opts BulkDeleteOptions,
+) (*BulkDeleteReport, error) {
+	return bulkDelete(ctx, "end_users", externalUserIDs, opts, c.DeleteEndUser)
+}
+
+// BulkDeleteAccounts deletes every account in accountIDs, see DeleteAccount.
+// Accounts that no longer exist count as deleted
+func (c *Client) BulkDeleteAccounts(
+	ctx context.Context,
+	accountIDs []string,
+	opts BulkDeleteOptions,
+) (*BulkDeleteReport, error) {
+	return bulkDelete(ctx, "accounts", accountIDs, opts, c.DeleteAccount)
+}
+
+func bulkDelete(
+	ctx context.Context,
+	kind string,
+	ids []string,
+	opts BulkDeleteOptions,
+	deleteFn func(context.Context, string) error,
+) (*BulkDeleteReport, error) {
+	checkpoint, err := loadBulkCheckpoint(opts.CheckpointFile, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer checkpoint.close()
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	var limiter <-chan time.Time
+	if opts.RequestsPerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.RequestsPerSecond))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	results := make([]BulkDeleteResult, len(ids))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = deleteOne(ctx, ids[i], limiter, deleteFn)
+				if results[i].Status != BulkFailed {
+					if err := checkpoint.done(ids[i]); err != nil {
+						// the next run deletes the item again, which counts as not found
+						err = fmt.Errorf("%s but writing checkpoint failed: %w", results[i].Status, err)
+						results[i] = BulkDeleteResult{ID: ids[i], Status: BulkFailed, Err: err, Error: err.Error()}
+					}
+				}
+			}
+		}()
+	}
+
+	for i, id := range ids {
+		if checkpoint.contains(id) {
+			results[i] = BulkDeleteResult{ID: id, Status: BulkSkipped}
+			continue
+		}
+		if ctx.Err() != nil {
+			results[i] = BulkDeleteResult{ID: id, Status: BulkFailed, Err: ctx.Err(), Error: ctx.Err().Error()}
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	report := &BulkDeleteReport{Results: results}
+	for _, res := range results {
+		switch res.Status {
+		case BulkDeleted:
+			report.Deleted++
+		case BulkNotFound:
+			report.NotFound++
+		case BulkFailed:
+			report.Failed++
+		case BulkSkipped:
+			report.Skipped++
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return report, fmt.Errorf("bulk delete of %s interrupted: %w", kind, err)
+	}
+
+	return report, nil
+}
+
+func deleteOne(
+	ctx context.Context,
+	id string,
+	limiter <-chan time.Time,
+	deleteFn func(context.Context, string) error,
+) BulkDeleteResult {
+	if limiter != nil {
+		select {
+		case <-ctx.Done():
+			return BulkDeleteResult{ID: id, Status: BulkFailed, Err: ctx.Err(), Error: ctx.Err().Error()}
+		case <-limiter:
+		}
+	}
+
+	err := deleteFn(ctx, id)
+	switch {
+	case err == nil:
+		return BulkDeleteResult{ID: id, Status: BulkDeleted}
+	case errors.Is(err, NotFoundErr):
+		return BulkDeleteResult{ID: id, Status: BulkNotFound}
+	default:
+		return BulkDeleteResult{ID: id, Status: BulkFailed, Err: err, Error: err.Error()}
+	}
+}
+
+// bulkCheckpoint is a JSON Lines file, a header line with the kind followed by
+// one line per completed ID. Lines are appended so every item costs one write
+type bulkCheckpoint struct {
+	mu   sync.Mutex
+	file *os.File
+	ids  map[string]struct{}
+}
+
+type bulkCheckpointLine struct {
+	Kind        string    `json:"kind,omitempty"`
+	ID          string    `json:"id,omitempty"`
+	CompletedAt time.Time `json:"completed_at,omitzero"`
+}
+
+func loadBulkCheckpoint(path string, kind string) (*bulkCheckpoint, error) {
+	cp := &bulkCheckpoint{ids: map[string]struct{}{}}
+	if path == "" {
+		return cp, nil
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading checkpoint file %s: %w", path, err)
+	}
+
+	// drop the partial last line of an interrupted write
+	if complete := bytes.LastIndexByte(bs, '\n') + 1; complete < len(bs) {
+		bs = bs[:complete]
+		if err := os.Truncate(path, int64(complete)); err != nil {
+			return nil, fmt.Errorf("truncating checkpoint file %s: %w", path, err)
+		}
+	}
+
+	for i, line := range bytes.Split(bytes.TrimSuffix(bs, []byte("\n")), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var decoded bulkCheckpointLine
+		if err := json.Unmarshal(line, &decoded); err != nil {
+			return nil, fmt.Errorf("decoding checkpoint file %s line %d: %w", path, i+1, err)
+		}
+		if i == 0 {
+			if decoded.Kind != kind {
+				return nil, fmt.Errorf("checkpoint file %s belongs to a bulk delete of %s, not %s",
+					path, decoded.Kind, kind)
+			}
+			continue
+		}
+		cp.ids[decoded.ID] = struct{}{}
+	}
+
+	cp.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening checkpoint file %s: %w", path, err)
+	}
+	if len(bs) == 0 {
+		if err := cp.append(bulkCheckpointLine{Kind: kind}); err != nil {
+			cp.file.Close()
+			return nil, fmt.Errorf("writing checkpoint file %s: %w", path, err)
+		}
+	}
+
+	return cp, nil
+}
+
+func (cp *bulkCheckpoint) contains(id string) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	_, ok := cp.ids[id]
+	return ok
+}
+
+func (cp *bulkCheckpoint) done(id string) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if _, ok := cp.ids[id]; ok {
+		return nil
+	}
+	if cp.file != nil {
+		if err := cp.append(bulkCheckpointLine{ID: id, CompletedAt: time.Now().UTC()}); err != nil {
+			return err
+		}
+	}
+	cp.ids[id] = struct{}{}
+	return nil
+}
+
+func (cp *bulkCheckpoint) append(line bulkCheckpointLine) error {
+	bs, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	// a single write per line, a crash leaves at most a partial last line
+	_, err = cp.file.Write(append(bs, '\n'))
+	return err
+}
+
+func (cp *bulkCheckpoint) close() error {
+	if cp.file == nil {
+		return nil
+	}
+	return cp.file.Close()
+}
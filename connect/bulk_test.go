@@ -0,0 +1,150 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type bulkTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+}
+
+func (suite *bulkTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+}
+
+func (suite *bulkTestSuite) newServer(statuses map[string]int, calls map[string]int) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+			return
+		case r.Method == http.MethodDelete:
+			id := path.Base(r.URL.Path)
+			mu.Lock()
+			calls[id]++
+			status := statuses[id]
+			mu.Unlock()
+			w.WriteHeader(status)
+		}
+	}))
+}
+
+func (suite *bulkTestSuite) TestBulkDeleteEndUsers_Report() {
+	require := suite.Require()
+	calls := map[string]int{}
+	server := suite.newServer(map[string]int{
+		"user-1": http.StatusNoContent,
+		"user-2": http.StatusNotFound,
+		"user-3": http.StatusInternalServerError,
+	}, calls)
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+
+	report, err := suite.pipedreamClient.BulkDeleteEndUsers(
+		suite.ctx,
+		[]string{"user-1", "user-2", "user-3"},
+		BulkDeleteOptions{Concurrency: 2, RequestsPerSecond: 100},
+	)
+
+	require.NoError(err)
+	require.Equal(1, report.Deleted)
+	require.Equal(1, report.NotFound)
+	require.Equal(1, report.Failed)
+	require.Equal(BulkDeleted, report.Results[0].Status)
+	require.Equal(BulkNotFound, report.Results[1].Status)
+	require.Equal(BulkFailed, report.Results[2].Status)
+	require.Equal([]string{"user-3"}, report.FailedIDs())
+	require.EqualError(report.Err(), "user-3: expected status 204, got 500")
+}
+
+func (suite *bulkTestSuite) TestBulkDeleteAccounts_ResumesFromCheckpoint() {
+	require := suite.Require()
+	statuses := map[string]int{
+		"apn_1": http.StatusNoContent,
+		"apn_2": http.StatusBadGateway,
+	}
+	calls := map[string]int{}
+	server := suite.newServer(statuses, calls)
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+
+	opts := BulkDeleteOptions{CheckpointFile: filepath.Join(suite.T().TempDir(), "checkpoint.json")}
+	ids := []string{"apn_1", "apn_2"}
+
+	report, err := suite.pipedreamClient.BulkDeleteAccounts(suite.ctx, ids, opts)
+	require.NoError(err)
+	require.Equal(1, report.Failed)
+
+	statuses["apn_2"] = http.StatusNoContent
+	report, err = suite.pipedreamClient.BulkDeleteAccounts(suite.ctx, ids, opts)
+	require.NoError(err)
+	require.Equal(1, report.Skipped)
+	require.Equal(1, report.Deleted)
+	require.Equal(1, calls["apn_1"])
+	require.Equal(2, calls["apn_2"])
+
+	_, err = suite.pipedreamClient.BulkDeleteEndUsers(suite.ctx, ids, opts)
+	require.Error(err)
+}
+
+func (suite *bulkTestSuite) TestBulkCheckpoint_AppendsLines() {
+	require := suite.Require()
+	path := filepath.Join(suite.T().TempDir(), "checkpoint.jsonl")
+
+	cp, err := loadBulkCheckpoint(path, "accounts")
+	require.NoError(err)
+	require.NoError(cp.done("apn_1"))
+	require.NoError(cp.done("apn_1"))
+	require.NoError(cp.done("apn_2"))
+	require.NoError(cp.close())
+
+	bs, err := os.ReadFile(path)
+	require.NoError(err)
+	require.Len(strings.Split(strings.TrimSpace(string(bs)), "\n"), 3)
+
+	// a crash in the middle of a write leaves a partial last line
+	require.NoError(os.WriteFile(path, append(bs, `{"id": "apn_`...), 0o644))
+	cp, err = loadBulkCheckpoint(path, "accounts")
+	require.NoError(err)
+	require.True(cp.contains("apn_2"))
+	require.NoError(cp.done("apn_3"))
+	require.NoError(cp.close())
+
+	cp, err = loadBulkCheckpoint(path, "accounts")
+	require.NoError(err)
+	require.True(cp.contains("apn_3"))
+
+	// failed writes are reported and the ID is not recorded
+	require.NoError(cp.close())
+	require.Error(cp.done("apn_4"))
+	require.False(cp.contains("apn_4"))
+}
+
+func TestBulk(t *testing.T) {
+	suite.Run(t, new(bulkTestSuite))
+}
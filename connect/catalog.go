@@ -0,0 +1,367 @@
+package connect
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// CatalogEntry is a component stored in the Catalog
+type CatalogEntry struct {
+	Component
+	App           string            `json:"app"`
+	ComponentType ComponentType     `json:"component_type"`
+	Details       *ComponentDetails `json:"details,omitempty"`
+	SyncedAt      time.Time         `json:"synced_at"`
+}
+
+// Catalog is a local, on-disk index of Connect components with full-text search
+type Catalog struct {
+	client *Client
+	path   string
+
+	mu       sync.RWMutex
+	entries  map[string]*CatalogEntry
+	index    map[string]map[string]float64 // token -> component key -> weight
+	syncedAt time.Time
+}
+
+type catalogFile struct {
+	SyncedAt time.Time       `json:"synced_at"`
+	Entries  []*CatalogEntry `json:"entries"`
+}
+
+// OpenCatalog loads the catalog stored at path, a missing file yields an empty catalog
+func (c *Client) OpenCatalog(path string) (*Catalog, error) {
+	catalog := &Catalog{
+		client:  c,
+		path:    path,
+		entries: map[string]*CatalogEntry{},
+	}
+
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		catalog.reindex()
+		return catalog, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog %s: %w", path, err)
+	}
+
+	var file catalogFile
+	if err := json.Unmarshal(bs, &file); err != nil {
+		return nil, fmt.Errorf("decoding catalog %s: %w", path, err)
+	}
+	for _, entry := range file.Entries {
+		catalog.entries[entry.Key] = entry
+	}
+	catalog.syncedAt = file.SyncedAt
+	catalog.reindex()
+
+	return catalog, nil
+}
+
+type CatalogSyncOptions struct {
+	// Apps to sync by name slug, empty syncs every component of the project
+	Apps []string
+	// Types defaults to actions and triggers
+	Types []ComponentType
+	// IncludeDetails fetches the configurable props of new and changed components
+	IncludeDetails bool
+}
+
+type CatalogSyncStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	// Details counts the GetComponent calls made
+	Details int `json:"details"`
+}
+
+// Sync lists the components in scope and refreshes the entries whose Version
+// changed, then writes the catalog to disk. Entries in scope that are no longer
+// listed are removed
+func (cat *Catalog) Sync(ctx context.Context, opts CatalogSyncOptions) (*CatalogSyncStats, error) {
+	types := opts.Types
+	if len(types) == 0 {
+		types = []ComponentType{Actions, Triggers}
+	}
+	apps := opts.Apps
+	if len(apps) == 0 {
+		apps = []string{""}
+	}
+
+	stats := &CatalogSyncStats{}
+	now := time.Now().UTC()
+
+	cat.mu.RLock()
+	entries := maps.Clone(cat.entries)
+	cat.mu.RUnlock()
+
+	seen := map[string]struct{}{}
+	for _, app := range apps {
+		for _, componentType := range types {
+			components, err := cat.client.ListAllComponents(ctx, componentType, app, "")
+			if err != nil {
+				return nil, fmt.Errorf("listing %s of app %q: %w", componentType, app, err)
+			}
+
+			for _, component := range components {
+				seen[component.Key] = struct{}{}
+
+				previous, exists := entries[component.Key]
+				upToDate := exists &&
+					previous.Version == component.Version &&
+					(!opts.IncludeDetails || previous.Details != nil)
+				if upToDate {
+					stats.Unchanged++
+					continue
+				}
+
+				entry := &CatalogEntry{
+					Component:     *component,
+					App:           cmp.Or(app, appFromComponentKey(component.Key)),
+					ComponentType: componentType,
+					SyncedAt:      now,
+				}
+				if opts.IncludeDetails {
+					details, err := cat.client.GetComponent(ctx, component.Key, componentType)
+					if err != nil {
+						return nil, fmt.Errorf("fetching details of %s: %w", component.Key, err)
+					}
+					entry.Details = details.Data
+					stats.Details++
+				}
+
+				entries[component.Key] = entry
+				if exists {
+					stats.Updated++
+				} else {
+					stats.Added++
+				}
+			}
+		}
+	}
+
+	for key, entry := range entries {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		inScope := slices.Contains(types, entry.ComponentType) &&
+			(len(opts.Apps) == 0 || slices.Contains(opts.Apps, entry.App))
+		if inScope {
+			delete(entries, key)
+			stats.Removed++
+		}
+	}
+
+	cat.mu.Lock()
+	cat.entries = entries
+	cat.syncedAt = now
+	cat.reindex()
+	cat.mu.Unlock()
+
+	if err := cat.Save(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// Save writes the catalog to its path
+func (cat *Catalog) Save() error {
+	cat.mu.RLock()
+	file := catalogFile{SyncedAt: cat.syncedAt}
+	for _, key := range slices.Sorted(maps.Keys(cat.entries)) {
+		file.Entries = append(file.Entries, cat.entries[key])
+	}
+	cat.mu.RUnlock()
+
+	bs, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding catalog: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cat.path), 0o755); err != nil {
+		return fmt.Errorf("creating catalog directory: %w", err)
+	}
+	tmp := cat.path + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0o644); err != nil {
+		return fmt.Errorf("writing catalog %s: %w", cat.path, err)
+	}
+
+	return os.Rename(tmp, cat.path)
+}
+
+func (cat *Catalog) Get(key string) (*CatalogEntry, bool) {
+	cat.mu.RLock()
+	defer cat.mu.RUnlock()
+	entry, ok := cat.entries[key]
+	return entry, ok
+}
+
+func (cat *Catalog) Len() int {
+	cat.mu.RLock()
+	defer cat.mu.RUnlock()
+	return len(cat.entries)
+}
+
+func (cat *Catalog) SyncedAt() time.Time {
+	cat.mu.RLock()
+	defer cat.mu.RUnlock()
+	return cat.syncedAt
+}
+
+type CatalogQuery struct {
+	// Text is matched against names, keys, descriptions and prop labels. Every
+	// word has to match the prefix of an indexed word
+	Text  string
+	Apps  []string
+	Types []ComponentType
+	// PropTypes keeps components declaring a prop of every listed type, only
+	// entries synced with details can match
+	PropTypes []PropType
+	// Limit caps the number of hits, zero returns all
+	Limit int
+}
+
+type CatalogHit struct {
+	Entry *CatalogEntry `json:"entry"`
+	Score float64       `json:"score"`
+}
+
+// Search returns the entries matching q, best matches first
+func (cat *Catalog) Search(q CatalogQuery) []CatalogHit {
+	cat.mu.RLock()
+	defer cat.mu.RUnlock()
+
+	var scores map[string]float64
+	if terms := tokenize(q.Text); len(terms) > 0 {
+		for _, term := range terms {
+			termScores := map[string]float64{}
+			for token, postings := range cat.index {
+				if !strings.HasPrefix(token, term) {
+					continue
+				}
+				// exact matches rank above prefix matches
+				boost := 0.5
+				if token == term {
+					boost = 1
+				}
+				for key, weight := range postings {
+					termScores[key] += weight * boost
+				}
+			}
+
+			if scores == nil {
+				scores = termScores
+				continue
+			}
+			for key := range scores {
+				if extra, ok := termScores[key]; ok {
+					scores[key] += extra
+				} else {
+					delete(scores, key)
+				}
+			}
+		}
+	} else {
+		scores = map[string]float64{}
+		for key := range cat.entries {
+			scores[key] = 0
+		}
+	}
+
+	var hits []CatalogHit
+	for key, score := range scores {
+		entry := cat.entries[key]
+		if !q.matches(entry) {
+			continue
+		}
+		hits = append(hits, CatalogHit{Entry: entry, Score: score})
+	}
+
+	slices.SortFunc(hits, func(a, b CatalogHit) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Entry.Key, b.Entry.Key))
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+
+	return hits
+}
+
+func (q CatalogQuery) matches(entry *CatalogEntry) bool {
+	if len(q.Apps) > 0 && !slices.Contains(q.Apps, entry.App) {
+		return false
+	}
+	if len(q.Types) > 0 && !slices.Contains(q.Types, entry.ComponentType) {
+		return false
+	}
+	for _, propType := range q.PropTypes {
+		if entry.Details == nil {
+			return false
+		}
+		hasType := slices.ContainsFunc(entry.Details.ConfigurableProps, func(p *ConfigurableProp) bool {
+			return p != nil && p.Type == propType
+		})
+		if !hasType {
+			return false
+		}
+	}
+	return true
+}
+
+// reindex rebuilds the inverted index, callers hold the write lock
+func (cat *Catalog) reindex() {
+	cat.index = map[string]map[string]float64{}
+	add := func(key string, text string, weight float64) {
+		for _, token := range tokenize(text) {
+			if cat.index[token] == nil {
+				cat.index[token] = map[string]float64{}
+			}
+			cat.index[token][key] += weight
+		}
+	}
+
+	for key, entry := range cat.entries {
+		add(key, entry.Name, 3)
+		add(key, entry.Key, 2)
+		add(key, entry.App, 2)
+		add(key, entry.Description, 1)
+		if entry.Details == nil {
+			continue
+		}
+		for _, prop := range entry.Details.ConfigurableProps {
+			if prop == nil {
+				continue
+			}
+			add(key, prop.Label, 1)
+			add(key, prop.Name, 0.5)
+			add(key, prop.Description, 0.5)
+		}
+	}
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// appFromComponentKey returns the app slug prefix of keys like google_sheets-add-single-row
+func appFromComponentKey(key string) string {
+	app, _, _ := strings.Cut(key, "-")
+	return app
+}
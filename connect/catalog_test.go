@@ -0,0 +1,296 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type catalogTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+
+	mu        sync.Mutex
+	actions   []*ComponentDetails
+	triggers  []*ComponentDetails
+	getCalls  map[string]int
+	listCalls int
+}
+
+func (suite *catalogTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.getCalls = map[string]int{}
+	suite.listCalls = 0
+
+	suite.actions = []*ComponentDetails{
+		{
+			Component: Component{
+				Key:         "slack-send-message",
+				Name:        "Send Message",
+				Version:     "0.0.1",
+				Description: "Send a message to a Slack channel",
+			},
+			ConfigurableProps: []*ConfigurableProp{
+				{Name: "slack", Type: PropTypeApp, App: "slack"},
+				{Name: "channel", Label: "Channel", Type: PropTypeString},
+				{Name: "text", Label: "Message Text", Type: PropTypeString},
+			},
+		},
+		{
+			Component: Component{
+				Key:         "google_sheets-add-single-row",
+				Name:        "Add Single Row",
+				Version:     "0.2.0",
+				Description: "Add a single row of data to Google Sheets",
+			},
+			ConfigurableProps: []*ConfigurableProp{
+				{Name: "googleSheets", Type: PropTypeApp, App: "google_sheets"},
+				{Name: "sheetId", Label: "Spreadsheet", Type: PropTypeString},
+				{Name: "cells", Label: "Cells", Type: PropTypeStringArray},
+			},
+		},
+		{
+			Component: Component{
+				Key:         "slack-list-channels",
+				Name:        "List Channels",
+				Version:     "0.1.0",
+				Description: "Return a list of all channels",
+			},
+			ConfigurableProps: []*ConfigurableProp{
+				{Name: "slack", Type: PropTypeApp, App: "slack"},
+				{Name: "limit", Label: "Limit", Type: PropTypeInteger},
+			},
+		},
+	}
+	suite.triggers = []*ComponentDetails{
+		{
+			Component: Component{
+				Key:         "slack-new-message-in-channels",
+				Name:        "New Message In Channels",
+				Version:     "1.0.0",
+				Description: "Emit new event when a new message is posted to one or more channels",
+			},
+			ConfigurableProps: []*ConfigurableProp{
+				{Name: "slack", Type: PropTypeApp, App: "slack"},
+				{Name: "timer", Type: PropTypeTimer},
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(suite.serve))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+}
+
+// serve lists components one per page so Sync has to follow the cursors
+func (suite *catalogTestSuite) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && r.URL.Path == oathPath {
+		_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+		return
+	}
+
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "project-abc" {
+		http.NotFound(w, r)
+		return
+	}
+
+	components := suite.actions
+	if parts[1] == string(Triggers) {
+		components = suite.triggers
+	}
+
+	if len(parts) == 3 {
+		suite.getCalls[parts[2]]++
+		for _, component := range components {
+			if component.Key == parts[2] {
+				_ = json.NewEncoder(w).Encode(GetComponentResponse{Data: component})
+				return
+			}
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	suite.listCalls++
+	var matching []*ComponentDetails
+	for _, component := range components {
+		app := r.URL.Query().Get("app")
+		if app == "" || strings.HasPrefix(component.Key, app+"-") {
+			matching = append(matching, component)
+		}
+	}
+
+	start := 0
+	if after := r.URL.Query().Get("after"); after != "" {
+		fmt.Sscanf(after, "cursor-%d", &start)
+	}
+
+	response := ListComponentResponse{PageInfo: PageInfo{TotalCount: len(matching)}}
+	if start < len(matching) {
+		component := matching[start].Component
+		response.Data = []*Component{&component}
+		response.PageInfo.Count = 1
+		response.PageInfo.EndCursor = fmt.Sprintf("cursor-%d", start+1)
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (suite *catalogTestSuite) TestSync_Incremental() {
+	require := suite.Require()
+	path := filepath.Join(suite.T().TempDir(), "catalog.json")
+
+	catalog, err := suite.pipedreamClient.OpenCatalog(path)
+	require.NoError(err)
+	require.Equal(0, catalog.Len())
+
+	stats, err := catalog.Sync(suite.ctx, CatalogSyncOptions{IncludeDetails: true})
+	require.NoError(err)
+	require.Equal(CatalogSyncStats{Added: 4, Details: 4}, *stats)
+	require.Equal(4, catalog.Len())
+
+	entry, ok := catalog.Get("google_sheets-add-single-row")
+	require.True(ok)
+	require.Equal("google_sheets", entry.App)
+	require.Equal(Actions, entry.ComponentType)
+	require.Len(entry.Details.ConfigurableProps, 3)
+
+	trigger, ok := catalog.Get("slack-new-message-in-channels")
+	require.True(ok)
+	require.Equal(Triggers, trigger.ComponentType)
+
+	// bump one version, drop one component
+	suite.mu.Lock()
+	suite.actions[0].Version = "0.0.2"
+	suite.actions[0].Description = "Post a message to a Slack channel"
+	suite.actions = suite.actions[:2]
+	suite.getCalls = map[string]int{}
+	suite.mu.Unlock()
+
+	stats, err = catalog.Sync(suite.ctx, CatalogSyncOptions{IncludeDetails: true})
+	require.NoError(err)
+	require.Equal(CatalogSyncStats{Updated: 1, Unchanged: 2, Removed: 1, Details: 1}, *stats)
+	require.Equal(map[string]int{"slack-send-message": 1}, suite.getCalls)
+
+	_, ok = catalog.Get("slack-list-channels")
+	require.False(ok)
+
+	reopened, err := suite.pipedreamClient.OpenCatalog(path)
+	require.NoError(err)
+	require.Equal(3, reopened.Len())
+	require.Equal(catalog.SyncedAt().UnixNano(), reopened.SyncedAt().UnixNano())
+
+	entry, ok = reopened.Get("slack-send-message")
+	require.True(ok)
+	require.Equal("0.0.2", entry.Version)
+	require.Equal("Post a message to a Slack channel", entry.Description)
+	require.Len(entry.Details.ConfigurableProps, 3)
+
+	hits := reopened.Search(CatalogQuery{Text: "post"})
+	require.Len(hits, 2)
+}
+
+func (suite *catalogTestSuite) TestSync_AppScope() {
+	require := suite.Require()
+	path := filepath.Join(suite.T().TempDir(), "catalog.json")
+
+	catalog, err := suite.pipedreamClient.OpenCatalog(path)
+	require.NoError(err)
+
+	_, err = catalog.Sync(suite.ctx, CatalogSyncOptions{})
+	require.NoError(err)
+	require.Equal(4, catalog.Len())
+
+	entry, ok := catalog.Get("slack-send-message")
+	require.True(ok)
+	require.Nil(entry.Details)
+
+	// removing a slack action while syncing google_sheets only keeps it
+	suite.mu.Lock()
+	suite.actions = suite.actions[1:]
+	suite.mu.Unlock()
+
+	stats, err := catalog.Sync(suite.ctx, CatalogSyncOptions{
+		Apps:  []string{"google_sheets"},
+		Types: []ComponentType{Actions},
+	})
+	require.NoError(err)
+	require.Equal(CatalogSyncStats{Unchanged: 1}, *stats)
+	require.Equal(4, catalog.Len())
+
+	stats, err = catalog.Sync(suite.ctx, CatalogSyncOptions{
+		Apps:  []string{"slack"},
+		Types: []ComponentType{Actions},
+	})
+	require.NoError(err)
+	require.Equal(CatalogSyncStats{Unchanged: 1, Removed: 1}, *stats)
+	require.Equal(3, catalog.Len())
+}
+
+func (suite *catalogTestSuite) TestSearch() {
+	require := suite.Require()
+
+	catalog, err := suite.pipedreamClient.OpenCatalog(filepath.Join(suite.T().TempDir(), "catalog.json"))
+	require.NoError(err)
+	_, err = catalog.Sync(suite.ctx, CatalogSyncOptions{IncludeDetails: true})
+	require.NoError(err)
+
+	keys := func(hits []CatalogHit) []string {
+		var out []string
+		for _, hit := range hits {
+			out = append(out, hit.Entry.Key)
+		}
+		return out
+	}
+
+	// name matches rank above description matches
+	hits := catalog.Search(CatalogQuery{Text: "message"})
+	require.Equal([]string{
+		"slack-send-message",
+		"slack-new-message-in-channels",
+	}, keys(hits)[:2])
+
+	// every term has to match, prefixes included
+	hits = catalog.Search(CatalogQuery{Text: "slack chan"})
+	require.ElementsMatch([]string{
+		"slack-send-message",
+		"slack-list-channels",
+		"slack-new-message-in-channels",
+	}, keys(hits))
+
+	hits = catalog.Search(CatalogQuery{Text: "spreadsheet"})
+	require.Equal([]string{"google_sheets-add-single-row"}, keys(hits))
+
+	hits = catalog.Search(CatalogQuery{Text: "channel", Types: []ComponentType{Triggers}})
+	require.Equal([]string{"slack-new-message-in-channels"}, keys(hits))
+
+	hits = catalog.Search(CatalogQuery{Apps: []string{"google_sheets"}})
+	require.Equal([]string{"google_sheets-add-single-row"}, keys(hits))
+
+	hits = catalog.Search(CatalogQuery{PropTypes: []PropType{PropTypeInteger}})
+	require.Equal([]string{"slack-list-channels"}, keys(hits))
+
+	hits = catalog.Search(CatalogQuery{Text: "slack", Limit: 2})
+	require.Len(hits, 2)
+
+	require.Empty(catalog.Search(CatalogQuery{Text: "jira"}))
+}
+
+func TestCatalog(t *testing.T) {
+	suite.Run(t, new(catalogTestSuite))
+}
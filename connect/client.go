@@ -6,4 +6,23 @@ import (
 
 type Client struct {
 	*client.Client
+
+	// ValidateProps makes InvokeAction and DeployTrigger fetch the component and
+	// validate the configured props locally before calling the API
+	ValidateProps bool
+
+	// OnObservation is optional and receives the observations components emit
+	// while props are configured or reloaded, see SlogObservations
+	OnObservation ObservationFunc
+
+	// PropOptionsCache is optional and caches the results of GetPropOptions and ConfigureProp
+	PropOptionsCache *PropOptionsCache
+
+	// Policy is optional and is evaluated before InvokeAction, DeployTrigger and
+	// Proxy, denied calls return a *PolicyDeniedError
+	Policy *Policy
+
+	// ActionCache is optional and caches the results of InvokeAction for the
+	// actions enabled on it
+	ActionCache *ActionCache
 }
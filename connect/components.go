@@ -7,11 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
-	"strings"
 
 	"github.com/cloudsquid/pipedream-go-sdk/internal"
 )
@@ -25,6 +25,8 @@ const (
 )
 
 type Component struct {
+	// ID identifies the published version, deployed triggers reference it as ComponentID
+	ID          string        `json:"id,omitempty"`
 	Key         string        `json:"key,omitempty"`
 	Name        string        `json:"name,omitempty"`
 	Version     string        `json:"version,omitempty"`
@@ -37,22 +39,22 @@ func (c Component) String() string {
 }
 
 type ConfigurableProp struct {
-	Name           string `json:"name,omitempty"`
-	Type           string `json:"type"`
-	App            string `json:"app,omitempty"`
-	CustomResponse bool   `json:"custom_response,omitempty"`
-	Label          string `json:"label,omitempty"`
-	Description    string `json:"description,omitempty"`
-	RemoteOptions  *bool  `json:"remoteOptions,omitempty"`
-	Options        []any  `json:"options,omitempty"` // this can be a string array or an object array of Value
-	UseQuery       bool   `json:"use_query,omitempty"`
-	Default        any    `json:"default,omitempty"`
-	Min            int    `json:"min,omitempty"`
-	Max            int    `json:"max,omitempty"`
-	Disabled       bool   `json:"disabled,omitempty"`
-	Secret         bool   `json:"secret,omitempty"`
-	Optional       bool   `json:"optional,omitempty"`
-	ReloadProps    bool   `json:"reloadProps,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	Type           PropType `json:"type"`
+	App            string   `json:"app,omitempty"`
+	CustomResponse bool     `json:"custom_response,omitempty"`
+	Label          string   `json:"label,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	RemoteOptions  *bool    `json:"remoteOptions,omitempty"`
+	Options        []any    `json:"options,omitempty"` // this can be a string array or an object array of Value
+	UseQuery       bool     `json:"use_query,omitempty"`
+	Default        any      `json:"default,omitempty"`
+	Min            *int     `json:"min,omitempty"`
+	Max            *int     `json:"max,omitempty"`
+	Disabled       bool     `json:"disabled,omitempty"`
+	Secret         bool     `json:"secret,omitempty"`
+	Optional       bool     `json:"optional,omitempty"`
+	ReloadProps    bool     `json:"reloadProps,omitempty"`
 }
 
 func (c ConfigurableProp) String() string {
@@ -87,11 +89,47 @@ func (c ComponentDetails) String() string {
 }
 
 type PropOptions struct {
-	Observations  []any    `json:"observations,omitempty"`
-	Context       any      `json:"context,omitempty"` // TODO
+	Observations []Observation      `json:"observations,omitempty"`
+	Context      PropOptionsContext `json:"context,omitempty"`
+	// Options also holds StringOptions, converted to values with identical label and value
 	Options       []Value  `json:"options,omitempty"`
 	Errors        []string `json:"errors,omitempty"`
-	StringOptions any      `json:"string_options,omitempty"`
+	StringOptions []string `json:"string_options,omitempty"`
+}
+
+// PropOptionsContext is the opaque pagination state returned by a prop options
+// function, pass it back as PrevContext to fetch the next page
+type PropOptionsContext map[string]any
+
+func (p *PropOptions) UnmarshalJSON(data []byte) error {
+	type propOptions PropOptions
+	var raw struct {
+		propOptions
+		StringOptionsCamel []string `json:"stringOptions,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*p = PropOptions(raw.propOptions)
+	if len(p.StringOptions) == 0 {
+		p.StringOptions = raw.StringOptionsCamel
+	}
+	// skip options already present, e.g. when decoding marshalled PropOptions
+	present := map[string]bool{}
+	for _, option := range p.Options {
+		if value, ok := option.Value.(string); ok && value == option.Label {
+			present[value] = true
+		}
+	}
+	for _, option := range p.StringOptions {
+		if !present[option] {
+			present[option] = true
+			p.Options = append(p.Options, Value{Label: option, Value: option})
+		}
+	}
+
+	return nil
 }
 
 type DynamicProps struct {
@@ -100,9 +138,21 @@ type DynamicProps struct {
 }
 
 type ReloadComponentPropsResponse struct {
-	Observations []any        `json:"observations,omitempty"`
-	Errors       []string     `json:"errors,omitempty"`
-	DynamicProps DynamicProps `json:"dynamicProps"`
+	Observations []Observation `json:"observations,omitempty"`
+	Errors       []string      `json:"errors,omitempty"`
+	DynamicProps DynamicProps  `json:"dynamicProps"`
+}
+
+// Err returns a *PropConfigError when the component reported errors while reloading
+func (r *ReloadComponentPropsResponse) Err(componentKey string) error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return &PropConfigError{
+		ComponentKey: componentKey,
+		Messages:     r.Errors,
+		Observations: r.Observations,
+	}
 }
 
 func (p PropOptions) String() string {
@@ -115,6 +165,26 @@ type Value struct {
 	Value any    `json:"value,omitempty"`
 }
 
+// UnmarshalJSON accepts label/value objects as well as bare strings, numbers and booleans
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var object struct {
+		Label string `json:"label,omitempty"`
+		Value any    `json:"value,omitempty"`
+	}
+	if err := json.Unmarshal(data, &object); err == nil {
+		v.Label, v.Value = object.Label, object.Value
+		return nil
+	}
+
+	var scalar any
+	if err := json.Unmarshal(data, &scalar); err != nil {
+		return err
+	}
+	v.Label, v.Value = fmt.Sprint(scalar), scalar
+
+	return nil
+}
+
 func (v Value) String() string {
 	return fmt.Sprintf("\t%s\t%v", v.Label, v.Value)
 }
@@ -133,7 +203,21 @@ type GetComponentResponse struct {
 
 // ListComponentResponse is the response for the component list endpoint
 type ListComponentResponse struct {
-	Data []*Component `json:"data,omitempty"`
+	PageInfo PageInfo     `json:"page_info,omitzero"`
+	Data     []*Component `json:"data,omitempty"`
+}
+
+// ConfigurePropRequest is the body of the configure endpoint. Query, Page,
+// PrevContext and DynamicPropsID are optional
+type ConfigurePropRequest struct {
+	ExternalUserID  string             `json:"external_user_id,omitempty"`
+	ComponentKey    string             `json:"id,omitempty"`
+	PropName        string             `json:"prop_name,omitempty"`
+	ConfiguredProps ConfiguredProps    `json:"configured_props,omitempty"`
+	DynamicPropsID  string             `json:"dynamic_props_id,omitempty"`
+	Query           string             `json:"query,omitempty"`
+	Page            int                `json:"page,omitempty"`
+	PrevContext     PropOptionsContext `json:"prev_context,omitempty"`
 }
 
 // https://pipedream.com/docs/connect/api/#configure-a-component
@@ -148,33 +232,36 @@ func (c *Client) GetPropOptions(
 	externalUserID string,
 	configuredProps ConfiguredProps,
 ) (*PropOptions, error) {
-	baseURL := c.ConnectURL().ResolveReference(&url.URL{
-		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "components", "configure")})
-
-	endpoint := baseURL.String()
-
-	type ConfigureRequest struct {
-		ExternalUserID  string          `json:"external_user_id,omitempty"`
-		ComponentKey    string          `json:"id,omitempty"`
-		PropName        string          `json:"prop_name,omitempty"`
-		ConfiguredProps ConfiguredProps `json:"configured_props,omitempty"`
-	}
-
-	requestBody := &ConfigureRequest{
+	return c.ConfigureProp(ctx, ConfigurePropRequest{
 		ExternalUserID:  externalUserID,
 		ComponentKey:    componentKey,
 		PropName:        propName,
 		ConfiguredProps: configuredProps,
+	})
+}
+
+// ConfigureProp fetches a single page of remote options for a prop, through
+// Client.PropOptionsCache when one is set
+func (c *Client) ConfigureProp(
+	ctx context.Context,
+	request ConfigurePropRequest,
+) (*PropOptions, error) {
+	if c.PropOptionsCache != nil {
+		return c.PropOptionsCache.Get(ctx, request, c.configureProp)
 	}
-	if propName == "" {
-		requestBody = &ConfigureRequest{
-			ExternalUserID:  externalUserID,
-			ComponentKey:    componentKey,
-			ConfiguredProps: configuredProps,
-		}
-	}
+	return c.configureProp(ctx, request)
+}
+
+func (c *Client) configureProp(
+	ctx context.Context,
+	request ConfigurePropRequest,
+) (*PropOptions, error) {
+	baseURL := c.ConnectURL().ResolveReference(&url.URL{
+		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "components", "configure")})
+
+	endpoint := baseURL.String()
 
-	bs, err := json.MarshalIndent(requestBody, "", "  ")
+	bs, err := json.MarshalIndent(request, "", "  ")
 	if err != nil {
 		return nil, fmt.Errorf("couldn't marshal request body: %w", err)
 	}
@@ -188,7 +275,7 @@ func (c *Client) GetPropOptions(
 	if err != nil {
 		return nil,
 			fmt.Errorf("executing request to configure component %s for user %s: %w",
-				componentKey, externalUserID, err)
+				request.ComponentKey, request.ExternalUserID, err)
 	}
 	defer response.Body.Close()
 
@@ -203,13 +290,72 @@ func (c *Client) GetPropOptions(
 			errors.New(string(bodyBytes)), err)
 	}
 
-	if propOptions.Errors != nil || len(propOptions.Errors) > 0 {
-		return nil, errors.New(strings.Join(propOptions.Errors, "."))
+	c.observe(ctx, request.ComponentKey, propOptions.Observations)
+
+	if len(propOptions.Errors) > 0 {
+		return nil, &PropConfigError{
+			ComponentKey: request.ComponentKey,
+			Prop:         request.PropName,
+			Messages:     propOptions.Errors,
+			Observations: propOptions.Observations,
+		}
 	}
 
 	return &propOptions, nil
 }
 
+// PropOptionPages iterates over the option pages of a prop, starting at
+// request.Page and passing each page's context on to the next request.
+// Iteration ends at the first empty page, a page repeating the previous one, or an error
+func (c *Client) PropOptionPages(
+	ctx context.Context,
+	request ConfigurePropRequest,
+) iter.Seq2[*PropOptions, error] {
+	return func(yield func(*PropOptions, error) bool) {
+		var previous string
+		for {
+			page, err := c.ConfigureProp(ctx, request)
+			if err != nil {
+				yield(nil, fmt.Errorf("fetching page %d of prop %s: %w",
+					request.Page, request.PropName, err))
+				return
+			}
+			if len(page.Options) == 0 {
+				return
+			}
+
+			// servers that ignore page and context keep answering with the same options
+			fingerprint, _ := json.Marshal(page.Options)
+			if string(fingerprint) == previous {
+				return
+			}
+			previous = string(fingerprint)
+
+			if !yield(page, nil) {
+				return
+			}
+
+			request.Page++
+			request.PrevContext = page.Context
+		}
+	}
+}
+
+// AllPropOptions collects the options of every page, see PropOptionPages
+func (c *Client) AllPropOptions(
+	ctx context.Context,
+	request ConfigurePropRequest,
+) ([]Value, error) {
+	var options []Value
+	for page, err := range c.PropOptionPages(ctx, request) {
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, page.Options...)
+	}
+	return options, nil
+}
+
 // https://pipedream.com/docs/connect/api/#retrieve-a-component
 // GetComponent retrieves a pipedream component and its configurable props
 func (c *Client) GetComponent(
@@ -232,15 +378,6 @@ func (c *Client) GetComponent(
 	}
 	defer response.Body.Close()
 
-	if response.StatusCode < 200 || response.StatusCode >= 300 {
-		bodyBytes, err := io.ReadAll(response.Body)
-		if err != nil {
-			return nil, fmt.Errorf("reading response body: %w", err)
-		}
-
-		return nil, fmt.Errorf("unexpected status code %d:%s", response.StatusCode, string(bodyBytes))
-	}
-
 	var component GetComponentResponse
 	if err := internal.UnmarshalResponse(response, &component); err != nil {
 		return nil, fmt.Errorf(
@@ -260,9 +397,6 @@ func (c *Client) ListComponents(
 	searchTerm string,
 	limit int,
 ) (*ListComponentResponse, error) {
-	baseURL := c.ConnectURL().ResolveReference(&url.URL{
-		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), string(componentType))})
-
 	queryParams := url.Values{}
 	internal.AddQueryParams(queryParams, "app", appName)
 	internal.AddQueryParams(queryParams, "q", searchTerm)
@@ -271,6 +405,43 @@ func (c *Client) ListComponents(
 		internal.AddQueryParams(queryParams, "limit", strconv.Itoa(limit))
 	}
 
+	return c.listComponents(ctx, componentType, queryParams)
+}
+
+// ListAllComponents follows the page cursors of ListComponents and returns every matching component
+func (c *Client) ListAllComponents(
+	ctx context.Context,
+	componentType ComponentType,
+	appName string,
+	searchTerm string,
+) ([]*Component, error) {
+	queryParams := url.Values{}
+	internal.AddQueryParams(queryParams, "app", appName)
+	internal.AddQueryParams(queryParams, "q", searchTerm)
+
+	var components []*Component
+	for {
+		page, err := c.listComponents(ctx, componentType, queryParams)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, page.Data...)
+
+		if !hasNextPage(page.PageInfo, len(page.Data), len(components), queryParams.Get("after")) {
+			return components, nil
+		}
+		queryParams.Set("after", page.PageInfo.EndCursor)
+	}
+}
+
+func (c *Client) listComponents(
+	ctx context.Context,
+	componentType ComponentType,
+	queryParams url.Values,
+) (*ListComponentResponse, error) {
+	baseURL := c.ConnectURL().ResolveReference(&url.URL{
+		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), string(componentType))})
+
 	baseURL.RawQuery = queryParams.Encode()
 	endpoint := baseURL.String()
 
@@ -289,7 +460,7 @@ func (c *Client) ListComponents(
 	if err := internal.UnmarshalResponse(resp, &respJson); err != nil {
 		return nil, fmt.Errorf(
 			"parsing response for listing components for app %s: %w",
-			appName, err)
+			queryParams.Get("app"), err)
 	}
 
 	return &respJson, nil
@@ -340,5 +511,7 @@ func (c *Client) ReloadComponentProps(
 			"parsing response for reloading component props: %w", err)
 	}
 
+	c.observe(ctx, ComponentKey, respJson.Observations)
+
 	return &respJson, nil
 }
@@ -301,6 +301,101 @@ func (suite *componentTestSuite) TestReloadComponentProps_Success() {
 	require.Equal("googleSheets", resp.DynamicProps.ConfigurableProps[0].Name)
 }
 
+func (suite *componentTestSuite) TestPropOptionPages_Success() {
+	require := suite.Require()
+	expectedPath := "/project-abc/components/configure"
+
+	var requests []ConfigurePropRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+			return
+		case r.Method == http.MethodPost && r.URL.Path == expectedPath:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(err)
+
+			var reqBody ConfigurePropRequest
+			require.NoError(json.Unmarshal(body, &reqBody))
+			requests = append(requests, reqBody)
+
+			w.WriteHeader(http.StatusOK)
+			switch reqBody.Page {
+			case 0:
+				_, _ = fmt.Fprint(w, `{
+					"context": {"cursor": "abc"},
+					"options": [{"label": "#general", "value": "C01"}, "C02"]
+				}`)
+			case 1:
+				_, _ = fmt.Fprint(w, `{
+					"context": {"cursor": "def"},
+					"options": [],
+					"stringOptions": ["C03"]
+				}`)
+			default:
+				_, _ = fmt.Fprint(w, `{"context": null, "options": []}`)
+			}
+		}
+	}))
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+
+	options, err := suite.pipedreamClient.AllPropOptions(suite.ctx, ConfigurePropRequest{
+		ExternalUserID: "jverce",
+		ComponentKey:   "slack-send-message",
+		PropName:       "channel",
+		Query:          "C0",
+		DynamicPropsID: "dyp_1",
+	})
+
+	require.NoError(err)
+	require.Equal([]Value{
+		{Label: "#general", Value: "C01"},
+		{Label: "C02", Value: "C02"},
+		{Label: "C03", Value: "C03"},
+	}, options)
+
+	require.Len(requests, 3)
+	require.Nil(requests[0].PrevContext)
+	require.Equal(PropOptionsContext{"cursor": "abc"}, requests[1].PrevContext)
+	require.Equal(PropOptionsContext{"cursor": "def"}, requests[2].PrevContext)
+	for i, request := range requests {
+		require.Equal(i, request.Page)
+		require.Equal("C0", request.Query)
+		require.Equal("dyp_1", request.DynamicPropsID)
+	}
+}
+
+func (suite *componentTestSuite) TestPropOptions_RoundTrip() {
+	require := suite.Require()
+
+	var options PropOptions
+	require.NoError(json.Unmarshal([]byte(`{
+		"options": [{"label": "#general", "value": "C01"}],
+		"string_options": ["C02"]
+	}`), &options))
+	require.Len(options.Options, 2)
+
+	for range 2 {
+		bs, err := json.Marshal(options)
+		require.NoError(err)
+		options = PropOptions{}
+		require.NoError(json.Unmarshal(bs, &options))
+	}
+	require.Equal([]Value{
+		{Label: "#general", Value: "C01"},
+		{Label: "C02", Value: "C02"},
+	}, options.Options)
+	require.Equal([]string{"C02"}, options.StringOptions)
+}
+
 func TestComponent(t *testing.T) {
 	suite.Run(t, new(componentTestSuite))
 }
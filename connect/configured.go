@@ -0,0 +1,427 @@
+package connect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var PropNotSetErr error = errors.New("prop is not set")
+
+// TimerSchedule is the value of $.interface.timer props, set either
+// IntervalSeconds or Cron
+type TimerSchedule struct {
+	IntervalSeconds int    `json:"intervalSeconds,omitempty"`
+	Cron            string `json:"cron,omitempty"`
+	Timezone        string `json:"timezone,omitempty"`
+}
+
+// Every returns a schedule running every interval, rounded to whole seconds
+func Every(interval time.Duration) TimerSchedule {
+	return TimerSchedule{IntervalSeconds: int(interval.Round(time.Second) / time.Second)}
+}
+
+// Cron returns a schedule for a cron expression, timezone is optional
+func Cron(expression string, timezone string) TimerSchedule {
+	return TimerSchedule{Cron: expression, Timezone: timezone}
+}
+
+// PropsBuilder builds ConfiguredProps in the shapes Pipedream expects
+//
+//	props := connect.NewProps().
+//		App("gitlab", "apn_kVh9AoD").
+//		Int("projectId", 45672541).
+//		Timer("timer", connect.Every(15*time.Minute)).
+//		Build()
+type PropsBuilder struct {
+	props ConfiguredProps
+}
+
+func NewProps() *PropsBuilder {
+	return &PropsBuilder{props: ConfiguredProps{}}
+}
+
+// Set stores any value as is
+func (b *PropsBuilder) Set(name string, value any) *PropsBuilder {
+	b.props[name] = value
+	return b
+}
+
+// App sets an app prop to a connected account, {"authProvisionId": accountID}
+func (b *PropsBuilder) App(name string, accountID string) *PropsBuilder {
+	return b.Set(name, map[string]string{"authProvisionId": accountID})
+}
+
+func (b *PropsBuilder) String(name string, value string) *PropsBuilder {
+	return b.Set(name, value)
+}
+
+func (b *PropsBuilder) Strings(name string, values ...string) *PropsBuilder {
+	return b.Set(name, values)
+}
+
+func (b *PropsBuilder) Int(name string, value int) *PropsBuilder {
+	return b.Set(name, value)
+}
+
+func (b *PropsBuilder) Ints(name string, values ...int) *PropsBuilder {
+	return b.Set(name, values)
+}
+
+func (b *PropsBuilder) Bool(name string, value bool) *PropsBuilder {
+	return b.Set(name, value)
+}
+
+func (b *PropsBuilder) Object(name string, value map[string]any) *PropsBuilder {
+	return b.Set(name, value)
+}
+
+// Labeled sets a value picked from prop options together with its label
+func (b *PropsBuilder) Labeled(name string, label string, value any) *PropsBuilder {
+	return b.Set(name, map[string]any{"__lv": map[string]any{"label": label, "value": value}})
+}
+
+// Timer sets a $.interface.timer prop, see Every and Cron
+func (b *PropsBuilder) Timer(name string, schedule TimerSchedule) *PropsBuilder {
+	return b.Set(name, schedule)
+}
+
+// HTTP sets a $.interface.http prop, Pipedream creates the endpoint on deploy
+func (b *PropsBuilder) HTTP(name string) *PropsBuilder {
+	return b.Set(name, map[string]any{})
+}
+
+// Build returns a copy of the props built so far
+func (b *PropsBuilder) Build() ConfiguredProps {
+	return maps.Clone(b.props)
+}
+
+// Get returns the prop called name converted to T. Numbers, booleans and
+// strings are converted into each other where that is lossless, values picked
+// from options are unwrapped and app props read as string yield the account ID.
+// Missing and null props return PropNotSetErr
+func Get[T any](props ConfiguredProps, name string) (T, error) {
+	var out T
+	value, ok := props[name]
+	if !ok || value == nil {
+		return out, fmt.Errorf("prop %s: %w", name, PropNotSetErr)
+	}
+
+	if err := coerceProp(value, reflect.ValueOf(&out).Elem()); err != nil {
+		return out, fmt.Errorf("prop %s: %w", name, err)
+	}
+	return out, nil
+}
+
+type propTag struct {
+	name      string
+	omitempty bool
+	app       bool
+}
+
+// parsePropTag reads `pd:"name,omitempty,app"`, app wraps a string account ID
+// into {"authProvisionId": ...}
+func parsePropTag(field reflect.StructField) (propTag, bool) {
+	tag, ok := field.Tag.Lookup("pd")
+	if !ok || tag == "-" || !field.IsExported() {
+		return propTag{}, false
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+	parsed := propTag{name: name}
+	if parsed.name == "" {
+		parsed.name = field.Name
+	}
+	for _, option := range strings.Split(options, ",") {
+		switch option {
+		case "omitempty":
+			parsed.omitempty = true
+		case "app":
+			parsed.app = true
+		}
+	}
+	return parsed, true
+}
+
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return reflect.Value{}, errors.New("nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("expected a struct, got %s", rv.Kind())
+	}
+	return rv, nil
+}
+
+// MarshalProps converts the fields of a struct tagged with `pd:"propName"` into
+// ConfiguredProps. Nil pointers are left out, as are zero values of fields
+// tagged omitempty
+func MarshalProps(v any) (ConfiguredProps, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling props: %w", err)
+	}
+
+	props := ConfiguredProps{}
+	for i := range rv.NumField() {
+		tag, ok := parsePropTag(rv.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		field := rv.Field(i)
+		if tag.omitempty && field.IsZero() {
+			continue
+		}
+		for field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
+		}
+		// nil pointers are left out at any depth, e.g. a nil *T in a **T
+		if field.Kind() == reflect.Pointer {
+			continue
+		}
+
+		if tag.app {
+			if field.Kind() != reflect.String {
+				return nil, fmt.Errorf("marshalling prop %s: app props must be strings", tag.name)
+			}
+			props[tag.name] = map[string]string{"authProvisionId": field.String()}
+			continue
+		}
+		props[tag.name] = field.Interface()
+	}
+
+	return props, nil
+}
+
+// UnmarshalProps sets the fields of the struct v points to from props, using the
+// conversions of Get. Props without a field are ignored
+func UnmarshalProps(props ConfiguredProps, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("unmarshalling props: expected a pointer to a struct")
+	}
+	rv = rv.Elem()
+
+	for i := range rv.NumField() {
+		tag, ok := parsePropTag(rv.Type().Field(i))
+		if !ok {
+			continue
+		}
+		value, ok := props[tag.name]
+		if !ok || value == nil {
+			continue
+		}
+
+		if err := coerceProp(value, rv.Field(i)); err != nil {
+			return fmt.Errorf("unmarshalling prop %s: %w", tag.name, err)
+		}
+	}
+	return nil
+}
+
+// coerceProp converts value into target, a settable value
+func coerceProp(value any, target reflect.Value) error {
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return err
+	}
+	return coerceJSON(unwrapLabeled(normalized), target)
+}
+
+func unwrapLabeled(value any) any {
+	if m, ok := value.(map[string]any); ok {
+		if lv, ok := m["__lv"].(map[string]any); ok && len(m) == 1 {
+			return lv["value"]
+		}
+	}
+	return value
+}
+
+func coerceJSON(value any, target reflect.Value) error {
+	if value == nil {
+		target.SetZero()
+		return nil
+	}
+
+	if target.Kind() == reflect.Pointer {
+		elem := reflect.New(target.Type().Elem())
+		if err := coerceJSON(value, elem.Elem()); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	}
+
+	cannot := fmt.Errorf("cannot convert %s %v to %s", jsonKind(value), value, target.Type())
+
+	switch target.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			target.SetString(v)
+		case json.Number:
+			target.SetString(v.String())
+		case bool:
+			target.SetString(strconv.FormatBool(v))
+		case map[string]any:
+			id, ok := v["authProvisionId"].(string)
+			if !ok {
+				return cannot
+			}
+			target.SetString(id)
+		default:
+			return cannot
+		}
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if target.Type() == reflect.TypeFor[time.Duration]() {
+			if s, ok := value.(string); ok {
+				d, err := time.ParseDuration(s)
+				if err != nil {
+					return cannot
+				}
+				target.SetInt(int64(d))
+				return nil
+			}
+		}
+		n, ok := intOf(value)
+		if !ok || target.OverflowInt(n) {
+			return cannot
+		}
+		target.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := uintOf(value)
+		if !ok || target.OverflowUint(n) {
+			return cannot
+		}
+		target.SetUint(n)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := numberOf(value)
+		if !ok {
+			return cannot
+		}
+		target.SetFloat(f)
+		return nil
+
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			target.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return cannot
+			}
+			target.SetBool(b)
+		default:
+			return cannot
+		}
+		return nil
+
+	case reflect.Slice:
+		if target.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		items, ok := value.([]any)
+		if !ok {
+			// a single value becomes a one element slice
+			items = []any{value}
+		}
+		slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := coerceJSON(unwrapLabeled(item), slice.Index(i)); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		target.Set(slice)
+		return nil
+
+	case reflect.Interface:
+		if reflect.TypeOf(value).AssignableTo(target.Type()) {
+			target.Set(reflect.ValueOf(value))
+			return nil
+		}
+	}
+
+	// structs, maps and everything else go through encoding/json
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bs, target.Addr().Interface()); err != nil {
+		return cannot
+	}
+	return nil
+}
+
+// numberText returns the text of JSON numbers and numeric strings
+func numberText(value any) (string, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String(), true
+	case string:
+		return strings.TrimSpace(v), true
+	}
+	return "", false
+}
+
+// intOf reads integers exactly, falling back to floats only for notations like
+// 1e3 that still denote an integer
+func intOf(value any) (int64, bool) {
+	text, ok := numberText(value)
+	if !ok {
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, true
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+func uintOf(value any) (uint64, bool) {
+	text, ok := numberText(value)
+	if !ok {
+		return 0, false
+	}
+	if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return n, true
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || f < 0 || f != math.Trunc(f) || f >= math.MaxUint64 {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+// numberOf reads JSON numbers and numeric strings
+func numberOf(value any) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,230 @@
+package connect
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type configuredTestSuite struct {
+	suite.Suite
+}
+
+type gitlabIssueProps struct {
+	Gitlab    string         `pd:"gitlab,app"`
+	ProjectID int            `pd:"projectId"`
+	Labels    []string       `pd:"labels,omitempty"`
+	Confident *bool          `pd:"confidential"`
+	Timer     *TimerSchedule `pd:"timer"`
+	Internal  string
+	Ignored   string `pd:"-"`
+}
+
+func (suite *configuredTestSuite) TestBuilder() {
+	require := suite.Require()
+
+	props := NewProps().
+		App("gitlab", "apn_kVh9AoD").
+		Int("projectId", 45672541).
+		Strings("labels", "bug", "p1").
+		Bool("confidential", false).
+		Labeled("assignee", "Jane", 7).
+		Timer("timer", Every(15*time.Minute)).
+		HTTP("http").
+		Build()
+
+	bs, err := json.Marshal(props)
+	require.NoError(err)
+	require.JSONEq(`{
+		"gitlab": {"authProvisionId": "apn_kVh9AoD"},
+		"projectId": 45672541,
+		"labels": ["bug", "p1"],
+		"confidential": false,
+		"assignee": {"__lv": {"label": "Jane", "value": 7}},
+		"timer": {"intervalSeconds": 900},
+		"http": {}
+	}`, string(bs))
+
+	cron, err := json.Marshal(Cron("0 9 * * 1", "Europe/Berlin"))
+	require.NoError(err)
+	require.JSONEq(`{"cron": "0 9 * * 1", "timezone": "Europe/Berlin"}`, string(cron))
+
+	// the props pass validation for the matching component
+	component := ComponentDetails{ConfigurableProps: []*ConfigurableProp{
+		{Name: "gitlab", Type: PropTypeApp},
+		{Name: "projectId", Type: PropTypeInteger},
+		{Name: "labels", Type: PropTypeStringArray},
+		{Name: "confidential", Type: PropTypeBoolean},
+		{Name: "assignee", Type: PropTypeInteger},
+		{Name: "timer", Type: PropTypeTimer},
+		{Name: "http", Type: PropTypeHTTP},
+	}}
+	require.NoError(component.Validate(props))
+}
+
+func (suite *configuredTestSuite) TestMarshalProps() {
+	require := suite.Require()
+
+	confidential := true
+	props, err := MarshalProps(&gitlabIssueProps{
+		Gitlab:    "apn_kVh9AoD",
+		ProjectID: 42,
+		Confident: &confidential,
+		Internal:  "not a prop",
+	})
+	require.NoError(err)
+	require.Equal(ConfiguredProps{
+		"gitlab":       map[string]string{"authProvisionId": "apn_kVh9AoD"},
+		"projectId":    42,
+		"confidential": true,
+	}, props)
+
+	// nil pointers are left out at any depth
+	var nilInner *string
+	sheet := "s1"
+	sheetPtr := &sheet
+	props, err = MarshalProps(struct {
+		Worksheet **string `pd:"worksheet"`
+		Sheet     **string `pd:"sheet"`
+	}{Worksheet: &nilInner, Sheet: &sheetPtr})
+	require.NoError(err)
+	require.Equal(ConfiguredProps{"sheet": "s1"}, props)
+
+	_, err = MarshalProps(42)
+	require.Error(err)
+	_, err = MarshalProps(struct {
+		Account int `pd:"account,app"`
+	}{})
+	require.ErrorContains(err, "app props must be strings")
+}
+
+func (suite *configuredTestSuite) TestUnmarshalProps() {
+	require := suite.Require()
+
+	var raw ConfiguredProps
+	require.NoError(json.Unmarshal([]byte(`{
+		"gitlab": {"authProvisionId": "apn_kVh9AoD"},
+		"projectId": "42",
+		"labels": "bug",
+		"confidential": "true",
+		"timer": {"cron": "*/5 * * * *"},
+		"unknown": 1
+	}`), &raw))
+
+	var out gitlabIssueProps
+	require.NoError(UnmarshalProps(raw, &out))
+	confidential := true
+	require.Equal(gitlabIssueProps{
+		Gitlab:    "apn_kVh9AoD",
+		ProjectID: 42,
+		Labels:    []string{"bug"},
+		Confident: &confidential,
+		Timer:     &TimerSchedule{Cron: "*/5 * * * *"},
+	}, out)
+
+	// round trip
+	props, err := MarshalProps(out)
+	require.NoError(err)
+	var back gitlabIssueProps
+	require.NoError(UnmarshalProps(props, &back))
+	require.Equal(out, back)
+
+	err = UnmarshalProps(ConfiguredProps{"projectId": "forty two"}, &out)
+	require.ErrorContains(err, "unmarshalling prop projectId: cannot convert string forty two to int")
+	require.Error(UnmarshalProps(raw, out))
+}
+
+func (suite *configuredTestSuite) TestGet() {
+	require := suite.Require()
+
+	props := ConfiguredProps{
+		"projectId": json.Number("45672541"),
+		"limit":     "10",
+		"ratio":     0.5,
+		"draft":     "false",
+		"ids":       []any{1, "2", map[string]any{"__lv": map[string]any{"label": "Three", "value": 3}}},
+		"channel":   map[string]any{"__lv": map[string]any{"label": "#general", "value": "C1"}},
+		"gitlab":    map[string]string{"authProvisionId": "apn_kVh9AoD"},
+		"timeout":   "1m30s",
+		"timer":     TimerSchedule{IntervalSeconds: 60},
+		"empty":     nil,
+	}
+
+	projectID, err := Get[int64](props, "projectId")
+	require.NoError(err)
+	require.Equal(int64(45672541), projectID)
+
+	limit, err := Get[int](props, "limit")
+	require.NoError(err)
+	require.Equal(10, limit)
+
+	limitText, err := Get[string](props, "projectId")
+	require.NoError(err)
+	require.Equal("45672541", limitText)
+
+	ratio, err := Get[float64](props, "ratio")
+	require.NoError(err)
+	require.Equal(0.5, ratio)
+
+	draft, err := Get[bool](props, "draft")
+	require.NoError(err)
+	require.False(draft)
+
+	ids, err := Get[[]int](props, "ids")
+	require.NoError(err)
+	require.Equal([]int{1, 2, 3}, ids)
+
+	channel, err := Get[string](props, "channel")
+	require.NoError(err)
+	require.Equal("C1", channel)
+
+	account, err := Get[string](props, "gitlab")
+	require.NoError(err)
+	require.Equal("apn_kVh9AoD", account)
+
+	timeout, err := Get[time.Duration](props, "timeout")
+	require.NoError(err)
+	require.Equal(90*time.Second, timeout)
+
+	timer, err := Get[TimerSchedule](props, "timer")
+	require.NoError(err)
+	require.Equal(60, timer.IntervalSeconds)
+
+	// integers beyond 2^53 keep every digit
+	big := ConfiguredProps{
+		"id":       int64(9007199254740993),
+		"text":     "9223372036854775807",
+		"unsigned": uint64(18446744073709551615),
+		"exp":      json.Number("1e3"),
+	}
+	id, err := Get[int64](big, "id")
+	require.NoError(err)
+	require.Equal(int64(9007199254740993), id)
+	maxInt, err := Get[int64](big, "text")
+	require.NoError(err)
+	require.Equal(int64(math.MaxInt64), maxInt)
+	maxUint, err := Get[uint64](big, "unsigned")
+	require.NoError(err)
+	require.Equal(uint64(math.MaxUint64), maxUint)
+	thousand, err := Get[int](big, "exp")
+	require.NoError(err)
+	require.Equal(1000, thousand)
+	_, err = Get[int64](big, "unsigned")
+	require.Error(err)
+
+	_, err = Get[int](props, "ratio")
+	require.ErrorContains(err, "cannot convert number 0.5 to int")
+	_, err = Get[int8](props, "projectId")
+	require.Error(err)
+	_, err = Get[string](props, "missing")
+	require.ErrorIs(err, PropNotSetErr)
+	_, err = Get[string](props, "empty")
+	require.ErrorIs(err, PropNotSetErr)
+}
+
+func TestConfigured(t *testing.T) {
+	suite.Run(t, new(configuredTestSuite))
+}
@@ -0,0 +1,292 @@
+package connect
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+type PropChangeKind string
+
+const (
+	PropAdded   PropChangeKind = "added"
+	PropRemoved PropChangeKind = "removed"
+	PropRetyped PropChangeKind = "retyped"
+	// PropNowRequired is an existing optional prop that became required
+	PropNowRequired PropChangeKind = "now_required"
+)
+
+type PropChange struct {
+	Prop    string         `json:"prop"`
+	Kind    PropChangeKind `json:"kind"`
+	OldType PropType       `json:"old_type,omitempty"`
+	NewType PropType       `json:"new_type,omitempty"`
+	// Required is set for added props end users have to configure
+	Required bool `json:"required,omitempty"`
+}
+
+// Breaking reports whether existing configurations may stop working after the change
+func (p PropChange) Breaking() bool {
+	return p.Kind != PropAdded || p.Required
+}
+
+// DiffComponentProps compares two versions of a component's configurable props,
+// changes are ordered like the props are declared, removed props last
+func DiffComponentProps(old, latest []*ConfigurableProp) []PropChange {
+	oldProps := map[string]*ConfigurableProp{}
+	for _, prop := range old {
+		if prop != nil {
+			oldProps[prop.Name] = prop
+		}
+	}
+
+	var changes []PropChange
+	seen := map[string]struct{}{}
+	for _, prop := range latest {
+		if prop == nil {
+			continue
+		}
+		seen[prop.Name] = struct{}{}
+
+		previous, ok := oldProps[prop.Name]
+		switch {
+		case !ok:
+			changes = append(changes, PropChange{
+				Prop:     prop.Name,
+				Kind:     PropAdded,
+				NewType:  prop.Type,
+				Required: prop.Required(),
+			})
+		case previous.Type != prop.Type:
+			changes = append(changes, PropChange{
+				Prop:    prop.Name,
+				Kind:    PropRetyped,
+				OldType: previous.Type,
+				NewType: prop.Type,
+			})
+		case !previous.Required() && prop.Required():
+			changes = append(changes, PropChange{
+				Prop:    prop.Name,
+				Kind:    PropNowRequired,
+				OldType: previous.Type,
+				NewType: prop.Type,
+			})
+		}
+	}
+
+	for _, prop := range old {
+		if prop == nil {
+			continue
+		}
+		if _, ok := seen[prop.Name]; !ok {
+			changes = append(changes, PropChange{Prop: prop.Name, Kind: PropRemoved, OldType: prop.Type})
+		}
+	}
+
+	return changes
+}
+
+type DriftOptions struct {
+	// ExternalUserIDs whose deployed triggers are checked
+	ExternalUserIDs []string
+	// Pinned maps component keys to the version our code was written against
+	Pinned map[string]string
+	// Baselines maps component keys to a known snapshot of the component, e.g.
+	// from a Catalog. Actions are only checked through Pinned and Baselines
+	// since they are not deployed
+	Baselines map[string]*ComponentDetails
+	// BaselineType is the type of components only listed in Pinned and Baselines,
+	// defaults to actions
+	BaselineType ComponentType
+}
+
+// TriggerDrift compares a deployed trigger with the latest component
+type TriggerDrift struct {
+	ID             string `json:"id"`
+	ExternalUserID string `json:"external_user_id"`
+	Name           string `json:"name,omitempty"`
+	// ComponentID and ComponentVersion are what the trigger was deployed from
+	ComponentID      string `json:"component_id,omitempty"`
+	ComponentVersion string `json:"component_version,omitempty"`
+	// Outdated is set when the trigger runs another component ID or version
+	// than the latest published one
+	Outdated bool         `json:"outdated,omitempty"`
+	Props    []PropChange `json:"props,omitempty"`
+}
+
+// Drifted reports whether the trigger is outdated or its props changed
+func (t TriggerDrift) Drifted() bool {
+	return t.Outdated || len(t.Props) > 0
+}
+
+type ComponentDrift struct {
+	ComponentKey  string        `json:"component_key"`
+	ComponentType ComponentType `json:"component_type"`
+	PinnedVersion string        `json:"pinned_version,omitempty"`
+	LatestVersion string        `json:"latest_version,omitempty"`
+	LatestID      string        `json:"latest_id,omitempty"`
+	// Removed is set when the component no longer exists
+	Removed bool `json:"removed,omitempty"`
+	// Props compares the baseline with the latest version
+	Props []PropChange `json:"props,omitempty"`
+	// Triggers lists every deployed trigger of the component, whether it runs
+	// the latest version and the changes between the props it was deployed
+	// with and the latest version
+	Triggers []TriggerDrift `json:"triggers,omitempty"`
+}
+
+// VersionChanged reports whether the latest version differs from the pinned one
+func (d ComponentDrift) VersionChanged() bool {
+	return d.PinnedVersion != "" && d.PinnedVersion != d.LatestVersion
+}
+
+// Drifted reports whether the version or any prop changed, or any deployed
+// trigger is outdated
+func (d ComponentDrift) Drifted() bool {
+	if d.Removed || d.VersionChanged() || len(d.Props) > 0 {
+		return true
+	}
+	return slices.ContainsFunc(d.Triggers, TriggerDrift.Drifted)
+}
+
+// Breaking reports whether any prop change may break existing configurations
+func (d ComponentDrift) Breaking() bool {
+	if d.Removed || slices.ContainsFunc(d.Props, PropChange.Breaking) {
+		return true
+	}
+	return slices.ContainsFunc(d.Triggers, func(t TriggerDrift) bool {
+		return slices.ContainsFunc(t.Props, PropChange.Breaking)
+	})
+}
+
+type DriftReport struct {
+	CheckedAt  time.Time        `json:"checked_at"`
+	Components []ComponentDrift `json:"components"`
+	// Unresolved lists deployed triggers that don't expose their component key
+	Unresolved []TriggerDrift `json:"unresolved,omitempty"`
+}
+
+// Drifted returns the components with version or prop changes
+func (r *DriftReport) Drifted() []ComponentDrift {
+	var drifted []ComponentDrift
+	for _, component := range r.Components {
+		if component.Drifted() {
+			drifted = append(drifted, component)
+		}
+	}
+	return drifted
+}
+
+// CheckComponentDrift compares the deployed triggers of the given end users and
+// the pinned and baseline components against the latest published components.
+// Every component is fetched once
+func (c *Client) CheckComponentDrift(ctx context.Context, opts DriftOptions) (*DriftReport, error) {
+	report := &DriftReport{CheckedAt: time.Now().UTC()}
+	baselineType := cmp.Or(opts.BaselineType, Actions)
+
+	components := map[string]*ComponentDrift{}
+	latest := map[string]*ComponentDetails{}
+
+	lookup := func(key string, componentType ComponentType) (*ComponentDrift, error) {
+		if drift, ok := components[key]; ok {
+			return drift, nil
+		}
+
+		drift := &ComponentDrift{
+			ComponentKey:  key,
+			ComponentType: componentType,
+			PinnedVersion: opts.Pinned[key],
+		}
+		component, err := c.GetComponent(ctx, key, componentType)
+		switch {
+		case isNotFound(err):
+			drift.Removed = true
+		case err != nil:
+			return nil, fmt.Errorf("fetching latest version of %s: %w", key, err)
+		case component.Data == nil:
+			drift.Removed = true
+		default:
+			drift.LatestVersion = component.Data.Version
+			drift.LatestID = component.Data.ID
+			latest[key] = component.Data
+		}
+
+		if baseline, ok := opts.Baselines[key]; ok && baseline != nil {
+			if drift.PinnedVersion == "" {
+				drift.PinnedVersion = baseline.Version
+			}
+			if !drift.Removed {
+				drift.Props = DiffComponentProps(baseline.ConfigurableProps, latest[key].ConfigurableProps)
+			}
+		}
+
+		components[key] = drift
+		return drift, nil
+	}
+
+	for _, externalUserID := range opts.ExternalUserIDs {
+		triggers, err := c.ListAllDeployedTriggers(ctx, externalUserID)
+		if err != nil {
+			return nil, fmt.Errorf("listing deployed triggers of %s: %w", externalUserID, err)
+		}
+
+		for _, trigger := range triggers {
+			triggerDrift := TriggerDrift{
+				ID:               trigger.ID,
+				ExternalUserID:   externalUserID,
+				Name:             trigger.Name,
+				ComponentID:      trigger.ComponentID,
+				ComponentVersion: trigger.ComponentVersion,
+			}
+			if trigger.ComponentKey == "" {
+				report.Unresolved = append(report.Unresolved, triggerDrift)
+				continue
+			}
+
+			drift, err := lookup(trigger.ComponentKey, Triggers)
+			if err != nil {
+				return nil, err
+			}
+			triggerDrift.Outdated = triggerOutdated(trigger, drift)
+			if details, ok := latest[trigger.ComponentKey]; ok && len(trigger.ConfigurableProps) > 0 {
+				deployed := make([]*ConfigurableProp, 0, len(trigger.ConfigurableProps))
+				for i := range trigger.ConfigurableProps {
+					deployed = append(deployed, &trigger.ConfigurableProps[i])
+				}
+				triggerDrift.Props = DiffComponentProps(deployed, details.ConfigurableProps)
+			}
+			drift.Triggers = append(drift.Triggers, triggerDrift)
+		}
+	}
+
+	keys := slices.Concat(slices.Collect(maps.Keys(opts.Pinned)), slices.Collect(maps.Keys(opts.Baselines)))
+	slices.Sort(keys)
+	for _, key := range slices.Compact(keys) {
+		if _, err := lookup(key, baselineType); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(components)) {
+		report.Components = append(report.Components, *components[key])
+	}
+
+	return report, nil
+}
+
+// triggerOutdated compares what a trigger was deployed from with the latest
+// component, as far as both sides expose an ID or a version
+func triggerOutdated(trigger Trigger, latest *ComponentDrift) bool {
+	switch {
+	case latest.Removed:
+		return true
+	case trigger.ComponentID != "" && latest.LatestID != "" && trigger.ComponentID != latest.LatestID:
+		return true
+	case trigger.ComponentVersion != "" && latest.LatestVersion != "" && trigger.ComponentVersion != latest.LatestVersion:
+		return true
+	}
+	return false
+}
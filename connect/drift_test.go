@@ -0,0 +1,217 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type driftTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+	getCalls        map[string]int
+}
+
+func (suite *driftTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.getCalls = map[string]int{}
+
+	latest := map[string]*ComponentDetails{
+		"/project-abc/triggers/gitlab-new-issue": {
+			Component: Component{ID: "sc_2", Key: "gitlab-new-issue", Version: "0.2.0"},
+			ConfigurableProps: []*ConfigurableProp{
+				{Name: "gitlab", Type: PropTypeApp},
+				{Name: "projectId", Type: PropTypeString},
+				{Name: "labels", Type: PropTypeStringArray, Optional: true},
+			},
+		},
+		"/project-abc/actions/slack-send-message": {
+			Component: Component{Key: "slack-send-message", Version: "0.0.3"},
+			ConfigurableProps: []*ConfigurableProp{
+				{Name: "slack", Type: PropTypeApp},
+				{Name: "channel", Type: PropTypeString},
+				{Name: "threadTs", Type: PropTypeString},
+			},
+		},
+		"/project-abc/actions/github-create-issue": {
+			Component: Component{Key: "github-create-issue", Version: "1.0.0"},
+		},
+	}
+
+	deployed := `{"data": [
+		{
+			"id": "dc_1",
+			"name": "Issues of project 1",
+			"component_id": "sc_1",
+			"component_key": "gitlab-new-issue",
+			"configurable_props": [
+				{"name": "gitlab", "type": "app"},
+				{"name": "projectId", "type": "integer"},
+				{"name": "branch", "type": "string"}
+			]
+		},
+		{
+			"id": "dc_2",
+			"component_id": "sc_2",
+			"component_version": "0.2.0",
+			"component_key": "gitlab-new-issue",
+			"configurable_props": [
+				{"name": "gitlab", "type": "app"},
+				{"name": "projectId", "type": "string"},
+				{"name": "labels", "type": "string[]", "optional": true}
+			]
+		},
+		{"id": "dc_3", "component_id": "sc_9"}
+	]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+		case r.URL.Path == "/project-abc/deployed-triggers":
+			suite.Require().Equal("jverce", r.URL.Query().Get("external_user_id"))
+			_, _ = fmt.Fprint(w, deployed)
+		default:
+			suite.getCalls[r.URL.Path]++
+			component, ok := latest[r.URL.Path]
+			if !ok {
+				http.Error(w, `{"error": "not found"}`, http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(GetComponentResponse{Data: component})
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+}
+
+func (suite *driftTestSuite) TestCheckComponentDrift() {
+	require := suite.Require()
+
+	report, err := suite.pipedreamClient.CheckComponentDrift(suite.ctx, DriftOptions{
+		ExternalUserIDs: []string{"jverce"},
+		Pinned: map[string]string{
+			"gitlab-new-issue":    "0.1.0",
+			"github-create-issue": "1.0.0",
+			"jira-create-issue":   "0.0.1",
+		},
+		Baselines: map[string]*ComponentDetails{
+			"slack-send-message": {
+				Component: Component{Key: "slack-send-message", Version: "0.0.2"},
+				ConfigurableProps: []*ConfigurableProp{
+					{Name: "slack", Type: PropTypeApp},
+					{Name: "channel", Type: PropTypeString},
+					{Name: "threadTs", Type: PropTypeString, Optional: true},
+					{Name: "asUser", Type: PropTypeBoolean},
+				},
+			},
+		},
+	})
+	require.NoError(err)
+	require.Equal(map[string]int{
+		"/project-abc/triggers/gitlab-new-issue":   1,
+		"/project-abc/actions/slack-send-message":  1,
+		"/project-abc/actions/github-create-issue": 1,
+		"/project-abc/actions/jira-create-issue":   1,
+	}, suite.getCalls)
+
+	require.Equal([]TriggerDrift{{ID: "dc_3", ExternalUserID: "jverce", ComponentID: "sc_9"}}, report.Unresolved)
+	require.Len(report.Components, 4)
+
+	github := report.Components[0]
+	require.Equal("github-create-issue", github.ComponentKey)
+	require.False(github.Drifted())
+
+	gitlab := report.Components[1]
+	require.Equal("gitlab-new-issue", gitlab.ComponentKey)
+	require.Equal(Triggers, gitlab.ComponentType)
+	require.True(gitlab.VersionChanged())
+	require.Equal("0.2.0", gitlab.LatestVersion)
+	require.Equal("sc_2", gitlab.LatestID)
+	require.Equal([]TriggerDrift{
+		{
+			ID:             "dc_1",
+			ExternalUserID: "jverce",
+			Name:           "Issues of project 1",
+			ComponentID:    "sc_1",
+			Outdated:       true,
+			Props: []PropChange{
+				{Prop: "projectId", Kind: PropRetyped, OldType: PropTypeInteger, NewType: PropTypeString},
+				{Prop: "labels", Kind: PropAdded, NewType: PropTypeStringArray},
+				{Prop: "branch", Kind: PropRemoved, OldType: PropTypeString},
+			},
+		},
+		{ID: "dc_2", ExternalUserID: "jverce", ComponentID: "sc_2", ComponentVersion: "0.2.0"},
+	}, gitlab.Triggers)
+	require.True(gitlab.Breaking())
+
+	jira := report.Components[2]
+	require.True(jira.Removed)
+	require.True(jira.Breaking())
+
+	slack := report.Components[3]
+	require.Equal("0.0.2", slack.PinnedVersion)
+	require.Equal("0.0.3", slack.LatestVersion)
+	require.Equal([]PropChange{
+		{Prop: "threadTs", Kind: PropNowRequired, OldType: PropTypeString, NewType: PropTypeString},
+		{Prop: "asUser", Kind: PropRemoved, OldType: PropTypeBoolean},
+	}, slack.Props)
+
+	drifted := report.Drifted()
+	require.Len(drifted, 3)
+
+	bs, err := json.Marshal(report)
+	require.NoError(err)
+	var decoded DriftReport
+	require.NoError(json.Unmarshal(bs, &decoded))
+	require.Equal(report.Components, decoded.Components)
+}
+
+func (suite *driftTestSuite) TestCheckComponentDrift_Unpinned() {
+	require := suite.Require()
+
+	report, err := suite.pipedreamClient.CheckComponentDrift(suite.ctx, DriftOptions{
+		ExternalUserIDs: []string{"jverce"},
+	})
+	require.NoError(err)
+	require.Len(report.Components, 1)
+
+	gitlab := report.Components[0]
+	require.Empty(gitlab.PinnedVersion)
+	require.False(gitlab.VersionChanged())
+	require.True(gitlab.Drifted())
+	require.True(gitlab.Triggers[0].Outdated)
+	require.False(gitlab.Triggers[1].Drifted())
+}
+
+func (suite *driftTestSuite) TestDiffComponentProps_Breaking() {
+	require := suite.Require()
+
+	changes := DiffComponentProps(
+		[]*ConfigurableProp{{Name: "a", Type: PropTypeString}},
+		[]*ConfigurableProp{
+			{Name: "a", Type: PropTypeString},
+			{Name: "b", Type: PropTypeString, Optional: true},
+			{Name: "c", Type: PropTypeInteger},
+		},
+	)
+	require.Equal([]PropChange{
+		{Prop: "b", Kind: PropAdded, NewType: PropTypeString},
+		{Prop: "c", Kind: PropAdded, NewType: PropTypeInteger, Required: true},
+	}, changes)
+	require.False(changes[0].Breaking())
+	require.True(changes[1].Breaking())
+}
+
+func TestDrift(t *testing.T) {
+	suite.Run(t, new(driftTestSuite))
+}
@@ -0,0 +1,175 @@
+package connect
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErasureIncompleteErr error = errors.New("end user data still present after erasure")
+	InvalidReceiptErr    error = errors.New("erasure receipt signature is invalid")
+)
+
+type ErasedAccount struct {
+	ID  string `json:"id"`
+	App string `json:"app,omitempty"`
+}
+
+type ErasedTrigger struct {
+	ID          string `json:"id"`
+	ComponentID string `json:"component_id,omitempty"`
+}
+
+// ErasureReceipt documents what EraseEndUser removed and what it found when re-querying
+type ErasureReceipt struct {
+	ExternalUserID string          `json:"external_user_id"`
+	ProjectID      string          `json:"project_id"`
+	Environment    string          `json:"environment"`
+	StartedAt      time.Time       `json:"started_at"`
+	CompletedAt    time.Time       `json:"completed_at"`
+	Accounts       []ErasedAccount `json:"accounts"`
+	Triggers       []ErasedTrigger `json:"triggers"`
+
+	// Verified is true when neither accounts nor deployed triggers were found after deletion
+	Verified          bool     `json:"verified"`
+	RemainingAccounts []string `json:"remaining_accounts,omitempty"`
+	RemainingTriggers []string `json:"remaining_triggers,omitempty"`
+
+	// Signature is the hex encoded ed25519 signature of the receipt with an empty signature
+	Signature string `json:"signature,omitempty"`
+}
+
+// Sign sets the receipt signature using key
+func (r *ErasureReceipt) Sign(key ed25519.PrivateKey) error {
+	if len(key) != ed25519.PrivateKeySize {
+		return fmt.Errorf("invalid ed25519 private key of %d bytes", len(key))
+	}
+	bs, err := r.signedBytes()
+	if err != nil {
+		return err
+	}
+	r.Signature = hex.EncodeToString(ed25519.Sign(key, bs))
+	return nil
+}
+
+// Verify checks the receipt signature against the public key of the signer
+func (r *ErasureReceipt) Verify(key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid ed25519 public key of %d bytes", len(key))
+	}
+	signature, err := hex.DecodeString(r.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return InvalidReceiptErr
+	}
+
+	bs, err := r.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, bs, signature) {
+		return InvalidReceiptErr
+	}
+	return nil
+}
+
+func (r *ErasureReceipt) signedBytes() ([]byte, error) {
+	unsigned := *r
+	unsigned.Signature = ""
+
+	bs, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling erasure receipt: %w", err)
+	}
+	return bs, nil
+}
+
+// EraseEndUser deletes the deployed triggers of an end user, deletes the end user
+// with all their accounts and re-queries the API to verify nothing is left.
+// The receipt is signed with signingKey, auditors verify it with the public key
+// through receipt.Verify. When data is still found afterwards the signed
+// receipt is returned together with an error wrapping ErasureIncompleteErr
+func (c *Client) EraseEndUser(
+	ctx context.Context,
+	externalUserID string,
+	signingKey ed25519.PrivateKey,
+) (*ErasureReceipt, error) {
+	if externalUserID == "" {
+		return nil, errors.New("external user id is required")
+	}
+	if len(signingKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid ed25519 signing key of %d bytes", len(signingKey))
+	}
+
+	receipt := &ErasureReceipt{
+		ExternalUserID: externalUserID,
+		ProjectID:      c.ProjectID(),
+		Environment:    c.Environment(),
+		StartedAt:      time.Now().UTC(),
+		Accounts:       []ErasedAccount{},
+		Triggers:       []ErasedTrigger{},
+	}
+
+	accounts, err := c.ListAllAccounts(ctx, externalUserID, "", "", false)
+	if err != nil {
+		return nil, fmt.Errorf("listing accounts of end user %s: %w", externalUserID, err)
+	}
+	for _, account := range accounts {
+		receipt.Accounts = append(receipt.Accounts,
+			ErasedAccount{ID: account.ID, App: account.App.NameSlug})
+	}
+
+	triggers, err := c.ListAllDeployedTriggers(ctx, externalUserID)
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("listing deployed triggers of end user %s: %w", externalUserID, err)
+	}
+	for _, trigger := range triggers {
+		err := c.DeleteDeployedTrigger(ctx, trigger.ID, externalUserID)
+		if err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("erasing end user %s: %w", externalUserID, err)
+		}
+		receipt.Triggers = append(receipt.Triggers,
+			ErasedTrigger{ID: trigger.ID, ComponentID: trigger.ComponentID})
+	}
+
+	if err := c.DeleteEndUser(ctx, externalUserID); err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("erasing end user %s: %w", externalUserID, err)
+	}
+
+	remainingAccounts, err := c.ListAllAccounts(ctx, externalUserID, "", "", false)
+	if err != nil {
+		return nil, fmt.Errorf("verifying accounts of end user %s: %w", externalUserID, err)
+	}
+	for _, account := range remainingAccounts {
+		receipt.RemainingAccounts = append(receipt.RemainingAccounts, account.ID)
+	}
+
+	remainingTriggers, err := c.ListAllDeployedTriggers(ctx, externalUserID)
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("verifying deployed triggers of end user %s: %w", externalUserID, err)
+	}
+	for _, trigger := range remainingTriggers {
+		receipt.RemainingTriggers = append(receipt.RemainingTriggers, trigger.ID)
+	}
+
+	receipt.Verified = len(receipt.RemainingAccounts) == 0 && len(receipt.RemainingTriggers) == 0
+	receipt.CompletedAt = time.Now().UTC()
+
+	if err := receipt.Sign(signingKey); err != nil {
+		return nil, err
+	}
+
+	if !receipt.Verified {
+		return receipt, fmt.Errorf("end user %s: %d accounts and %d triggers remain: %w",
+			externalUserID,
+			len(receipt.RemainingAccounts),
+			len(receipt.RemainingTriggers),
+			ErasureIncompleteErr)
+	}
+
+	return receipt, nil
+}
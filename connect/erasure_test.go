@@ -0,0 +1,166 @@
+package connect
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type erasureTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+	publicKey       ed25519.PublicKey
+	privateKey      ed25519.PrivateKey
+	// verifyStatus fails the account listings after the deletion if set
+	verifyStatus int
+}
+
+func (suite *erasureTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.verifyStatus = 0
+	var err error
+	suite.publicKey, suite.privateKey, err = ed25519.GenerateKey(rand.Reader)
+	suite.Require().NoError(err)
+}
+
+func (suite *erasureTestSuite) newServer(keepAccounts bool) *httptest.Server {
+	require := suite.Require()
+	userDeleted := false
+	triggerDeleted := false
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/project-abc/accounts":
+			require.Equal("user-123", r.URL.Query().Get("external_user_id"))
+			if userDeleted && suite.verifyStatus != 0 {
+				w.WriteHeader(suite.verifyStatus)
+				_, _ = fmt.Fprint(w, `{"error": "internal error"}`)
+				return
+			}
+			if userDeleted && !keepAccounts {
+				_, _ = fmt.Fprint(w, `{"page_info": {"total_count": 0}, "data": []}`)
+				return
+			}
+			if r.URL.Query().Get("after") == "" {
+				_, _ = fmt.Fprint(w, `{
+					"page_info": {"total_count": 2, "count": 1, "end_cursor": "cursor-1"},
+					"data": [{"id": "apn_1", "app": {"name_slug": "slack"}}]
+				}`)
+				return
+			}
+			require.Equal("cursor-1", r.URL.Query().Get("after"))
+			_, _ = fmt.Fprint(w, `{
+				"page_info": {"total_count": 2, "count": 1, "end_cursor": "cursor-2"},
+				"data": [{"id": "apn_2", "app": {"name_slug": "github"}}]
+			}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/project-abc/deployed-triggers":
+			if triggerDeleted {
+				_, _ = fmt.Fprint(w, `{"data": []}`)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"data": [{"id": "dc_1", "component_id": "sc_1"}]}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/project-abc/deployed-triggers/dc_1":
+			triggerDeleted = true
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete && r.URL.Path == "/project-abc/users/user-123":
+			userDeleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			suite.Failf("unexpected request", "%s %s", r.Method, r.URL.Path)
+		}
+	}))
+}
+
+func (suite *erasureTestSuite) TestEraseEndUser_Verified() {
+	require := suite.Require()
+	server := suite.newServer(false)
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"client-secret", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+
+	receipt, err := suite.pipedreamClient.EraseEndUser(suite.ctx, "user-123", suite.privateKey)
+	require.NoError(err)
+	require.True(receipt.Verified)
+	require.Equal([]ErasedAccount{{ID: "apn_1", App: "slack"}, {ID: "apn_2", App: "github"}}, receipt.Accounts)
+	require.Equal([]ErasedTrigger{{ID: "dc_1", ComponentID: "sc_1"}}, receipt.Triggers)
+	require.NoError(receipt.Verify(suite.publicKey))
+
+	bs, err := json.Marshal(receipt)
+	require.NoError(err)
+
+	var decoded ErasureReceipt
+	require.NoError(json.Unmarshal(bs, &decoded))
+	require.NoError(decoded.Verify(suite.publicKey))
+
+	decoded.Accounts = decoded.Accounts[:1]
+	require.True(errors.Is(decoded.Verify(suite.publicKey), InvalidReceiptErr))
+
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	require.True(errors.Is(receipt.Verify(otherKey), InvalidReceiptErr))
+}
+
+func (suite *erasureTestSuite) TestEraseEndUser_RequiresSigningKey() {
+	suite.pipedreamClient = &Client{Client: client.NewClient("", "project-abc", "development", "",
+		"", nil, "http://127.0.0.1:0", "http://127.0.0.1:0")}
+
+	_, err := suite.pipedreamClient.EraseEndUser(suite.ctx, "user-123", nil)
+	suite.Require().ErrorContains(err, "invalid ed25519 signing key")
+}
+
+func (suite *erasureTestSuite) TestEraseEndUser_Incomplete() {
+	require := suite.Require()
+	server := suite.newServer(true)
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"client-secret", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+
+	receipt, err := suite.pipedreamClient.EraseEndUser(suite.ctx, "user-123", suite.privateKey)
+	require.True(errors.Is(err, ErasureIncompleteErr))
+	require.False(receipt.Verified)
+	require.Equal([]string{"apn_1", "apn_2"}, receipt.RemainingAccounts)
+	require.NoError(receipt.Verify(suite.publicKey))
+}
+
+func (suite *erasureTestSuite) TestEraseEndUser_VerificationFails() {
+	require := suite.Require()
+	suite.verifyStatus = http.StatusInternalServerError
+	server := suite.newServer(false)
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"client-secret", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+
+	// an error body is not an empty account list
+	receipt, err := suite.pipedreamClient.EraseEndUser(suite.ctx, "user-123", suite.privateKey)
+	require.ErrorContains(err, "verifying accounts of end user user-123")
+	status, ok := HTTPStatusCode(err)
+	require.True(ok)
+	require.Equal(http.StatusInternalServerError, status)
+	require.Nil(receipt)
+}
+
+func TestErasure(t *testing.T) {
+	suite.Run(t, new(erasureTestSuite))
+}
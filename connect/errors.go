@@ -1,7 +1,49 @@
 package connect
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudsquid/pipedream-go-sdk/internal"
+)
 
 var (
 	NotFoundErr error = errors.New("requested resource does not exist")
 )
+
+// StatusError is returned when an endpoint answers with a status code other than the expected one
+type StatusError struct {
+	Expected   int
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("expected status %d, got %d", e.Expected, e.StatusCode)
+}
+
+// Is lets errors.Is(err, NotFoundErr) match 404 responses
+func (e *StatusError) Is(target error) bool {
+	return target == NotFoundErr && e.StatusCode == http.StatusNotFound
+}
+
+// HTTPStatusCode extracts the status code of a failed API response from err
+func HTTPStatusCode(err error) (int, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+	var responseErr *internal.StatusError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode, true
+	}
+	return 0, false
+}
+
+func isNotFound(err error) bool {
+	if errors.Is(err, NotFoundErr) {
+		return true
+	}
+	code, ok := HTTPStatusCode(err)
+	return ok && code == http.StatusNotFound
+}
@@ -0,0 +1,292 @@
+package connect
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+type ExportOptions struct {
+	// ExternalUserID and App optionally narrow the export
+	ExternalUserID string
+	App            string
+
+	// IncludeCredentials exports the raw credentials object of every auth type
+	// encrypted with EncryptionKey, an AES key of 16, 24 or 32 bytes.
+	// Credentials are never written in plain text
+	IncludeCredentials bool
+	EncryptionKey      []byte
+}
+
+// AccountExportRecord is a single JSON Lines record written by ExportAccounts
+type AccountExportRecord struct {
+	Account
+	EncryptedCredentials string `json:"encrypted_credentials,omitempty"`
+}
+
+// UnmarshalJSON keeps the UnmarshalJSON of the embedded Account from hiding
+// EncryptedCredentials
+func (r *AccountExportRecord) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.Account); err != nil {
+		return err
+	}
+	var sealed struct {
+		EncryptedCredentials string `json:"encrypted_credentials"`
+	}
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return err
+	}
+	r.EncryptedCredentials = sealed.EncryptedCredentials
+	return nil
+}
+
+// ExportAccounts writes every account of the project to w as JSON Lines and
+// returns the number of records written
+func (c *Client) ExportAccounts(
+	ctx context.Context,
+	w io.Writer,
+	opts ExportOptions,
+) (int, error) {
+	var gcm cipher.AEAD
+	if opts.IncludeCredentials {
+		var err error
+		if gcm, err = newCredentialsCipher(opts.EncryptionKey); err != nil {
+			return 0, err
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	written := 0
+
+	err := c.walkAccounts(ctx,
+		accountsQuery(opts.ExternalUserID, opts.App, "", opts.IncludeCredentials),
+		func(page []*Account) error {
+			for _, account := range page {
+				record := AccountExportRecord{Account: *account}
+				record.Credentials = Credentials{}
+				record.RawCredentials = nil
+
+				if gcm != nil && hasCredentials(account.RawCredentials) {
+					sealed, err := sealCredentials(gcm, account.RawCredentials)
+					if err != nil {
+						return fmt.Errorf("encrypting credentials of account %s: %w", account.ID, err)
+					}
+					record.EncryptedCredentials = sealed
+				}
+
+				if err := enc.Encode(record); err != nil {
+					return fmt.Errorf("writing account %s: %w", account.ID, err)
+				}
+				written++
+			}
+			return nil
+		})
+	if err != nil {
+		return written, fmt.Errorf("exporting accounts: %w", err)
+	}
+
+	return written, nil
+}
+
+// ReadAccountExport parses the output of ExportAccounts. Encrypted credentials
+// are decrypted when key is set and left empty otherwise
+func ReadAccountExport(r io.Reader, key []byte) ([]Account, error) {
+	var gcm cipher.AEAD
+	if len(key) > 0 {
+		var err error
+		if gcm, err = newCredentialsCipher(key); err != nil {
+			return nil, err
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var accounts []Account
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+
+		var record AccountExportRecord
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			return nil, fmt.Errorf("decoding line %d: %w", line, err)
+		}
+
+		if record.EncryptedCredentials != "" && gcm != nil {
+			raw, err := openCredentials(gcm, record.EncryptedCredentials)
+			if err != nil {
+				return nil, fmt.Errorf("decrypting credentials on line %d: %w", line, err)
+			}
+			if err := json.Unmarshal(raw, &record.Credentials); err != nil {
+				return nil, fmt.Errorf("decoding credentials on line %d: %w", line, err)
+			}
+			record.RawCredentials = raw
+		}
+
+		accounts = append(accounts, record.Account)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading account export: %w", err)
+	}
+
+	return accounts, nil
+}
+
+type AccountChange struct {
+	Exported Account  `json:"exported"`
+	Live     Account  `json:"live"`
+	Fields   []string `json:"fields"`
+}
+
+// AccountDiff compares an export with the accounts of a project
+type AccountDiff struct {
+	// Missing accounts are in the export but not in the project
+	Missing []Account `json:"missing"`
+	// Added accounts are in the project but not in the export
+	Added     []Account       `json:"added"`
+	Changed   []AccountChange `json:"changed"`
+	Unchanged int             `json:"unchanged"`
+}
+
+func (d *AccountDiff) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Added) == 0 && len(d.Changed) == 0
+}
+
+// DiffAccountExport compares exported accounts with the live accounts matching opts
+func (c *Client) DiffAccountExport(
+	ctx context.Context,
+	exported []Account,
+	opts ExportOptions,
+) (*AccountDiff, error) {
+	live, err := c.ListAllAccounts(ctx, opts.ExternalUserID, opts.App, "", false)
+	if err != nil {
+		return nil, fmt.Errorf("listing live accounts: %w", err)
+	}
+
+	liveAccounts := make([]Account, 0, len(live))
+	for _, account := range live {
+		liveAccounts = append(liveAccounts, *account)
+	}
+
+	return DiffAccounts(exported, liveAccounts), nil
+}
+
+// DiffAccounts matches accounts by ID first, then by external user, app and
+// account name so exports from another project line up with the live accounts
+func DiffAccounts(exported []Account, live []Account) *AccountDiff {
+	diff := &AccountDiff{}
+
+	byID := map[string]int{}
+	byIdentity := map[string][]int{}
+	for i, account := range live {
+		byID[account.ID] = i
+		key := accountIdentity(account)
+		byIdentity[key] = append(byIdentity[key], i)
+	}
+
+	matched := make([]bool, len(live))
+	match := func(account Account) (int, bool) {
+		if i, ok := byID[account.ID]; ok && account.ID != "" && !matched[i] {
+			return i, true
+		}
+		for _, i := range byIdentity[accountIdentity(account)] {
+			if !matched[i] {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	for _, account := range exported {
+		i, ok := match(account)
+		if !ok {
+			diff.Missing = append(diff.Missing, account)
+			continue
+		}
+		matched[i] = true
+
+		var fields []string
+		if account.Healthy != live[i].Healthy {
+			fields = append(fields, "healthy")
+		}
+		if account.Dead != live[i].Dead {
+			fields = append(fields, "dead")
+		}
+		if account.Name != live[i].Name {
+			fields = append(fields, "name")
+		}
+
+		if len(fields) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changed = append(diff.Changed,
+			AccountChange{Exported: account, Live: live[i], Fields: fields})
+	}
+
+	for i, account := range live {
+		if !matched[i] {
+			diff.Added = append(diff.Added, account)
+		}
+	}
+
+	return diff
+}
+
+func accountIdentity(a Account) string {
+	return strings.Join([]string{a.ExternalID, a.App.NameSlug, a.Name}, "\x00")
+}
+
+func newCredentialsCipher(key []byte) (cipher.AEAD, error) {
+	if !slices.Contains([]int{16, 24, 32}, len(key)) {
+		return nil, errors.New("encryption key must be 16, 24 or 32 bytes long")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// hasCredentials reports whether raw is a non-empty credentials object
+func hasCredentials(raw json.RawMessage) bool {
+	var fields map[string]any
+	return json.Unmarshal(raw, &fields) == nil && len(fields) > 0
+}
+
+func sealCredentials(gcm cipher.AEAD, plain []byte) (string, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// openCredentials returns the raw credentials object sealed by sealCredentials
+func openCredentials(gcm cipher.AEAD, sealed string) (json.RawMessage, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	return gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+}
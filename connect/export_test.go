@@ -0,0 +1,167 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type exportTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+}
+
+func (suite *exportTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+}
+
+func (suite *exportTestSuite) TestExportAccounts_RoundTrip() {
+	require := suite.Require()
+	key := bytes.Repeat([]byte("k"), 32)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+		case r.URL.Path == "/project-abc/accounts":
+			require.Equal("true", r.URL.Query().Get("include_credentials"))
+			_, _ = fmt.Fprint(w, `{
+				"data": [
+					{
+						"id": "apn_1",
+						"name": "work",
+						"external_id": "user-1",
+						"healthy": true,
+						"app": {"name_slug": "slack"},
+						"credentials": {"oauth_access_token": "xoxb-secret"}
+					},
+					{
+						"id": "apn_2",
+						"name": "personal",
+						"external_id": "user-2",
+						"app": {"name_slug": "github"}
+					},
+					{
+						"id": "apn_3",
+						"name": "llm",
+						"external_id": "user-2",
+						"app": {"name_slug": "openai", "auth_type": "keys"},
+						"credentials": {"api_key": "sk-secret"}
+					}
+				]
+			}`)
+		}
+	}))
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+
+	var out bytes.Buffer
+	n, err := suite.pipedreamClient.ExportAccounts(suite.ctx, &out, ExportOptions{
+		IncludeCredentials: true,
+		EncryptionKey:      key,
+	})
+	require.NoError(err)
+	require.Equal(3, n)
+	require.Equal(3, strings.Count(out.String(), "\n"))
+	require.NotContains(out.String(), "xoxb-secret")
+	require.NotContains(out.String(), "sk-secret")
+
+	accounts, err := ReadAccountExport(bytes.NewReader(out.Bytes()), key)
+	require.NoError(err)
+	require.Len(accounts, 3)
+	require.Equal("xoxb-secret", accounts[0].Credentials.OauthAccessToken)
+	require.Equal("slack", accounts[0].App.NameSlug)
+	require.Empty(accounts[1].RawCredentials)
+	// credentials of other auth types than OAuth are exported too
+	require.JSONEq(`{"api_key": "sk-secret"}`, string(accounts[2].RawCredentials))
+
+	withoutKey, err := ReadAccountExport(bytes.NewReader(out.Bytes()), nil)
+	require.NoError(err)
+	require.Empty(withoutKey[0].Credentials.OauthAccessToken)
+
+	_, err = ReadAccountExport(bytes.NewReader(out.Bytes()), bytes.Repeat([]byte("x"), 32))
+	require.Error(err)
+}
+
+func (suite *exportTestSuite) TestExportAccounts_RequiresKeyForCredentials() {
+	require := suite.Require()
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, "http://localhost", "http://localhost")
+	suite.pipedreamClient = &Client{Client: base}
+
+	_, err := suite.pipedreamClient.ExportAccounts(suite.ctx, &bytes.Buffer{}, ExportOptions{
+		IncludeCredentials: true,
+	})
+	require.Error(err)
+}
+
+func (suite *exportTestSuite) TestExportAccounts_APIError() {
+	require := suite.Require()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == oathPath {
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = fmt.Fprint(w, `{"error": "forbidden"}`)
+	}))
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+
+	var out bytes.Buffer
+	n, err := suite.pipedreamClient.ExportAccounts(suite.ctx, &out, ExportOptions{})
+	require.ErrorContains(err, "exporting accounts")
+	require.Zero(n)
+	require.Empty(out.String())
+
+	// an error is not a project without accounts
+	diff, err := suite.pipedreamClient.DiffAccountExport(suite.ctx, []Account{{ID: "apn_1"}}, ExportOptions{})
+	require.ErrorContains(err, "listing live accounts")
+	require.Nil(diff)
+}
+
+func (suite *exportTestSuite) TestDiffAccounts() {
+	require := suite.Require()
+	exported := []Account{
+		{ID: "apn_old_1", ExternalID: "user-1", Name: "work", Healthy: true, App: App{NameSlug: "slack"}},
+		{ID: "apn_old_2", ExternalID: "user-2", Name: "personal", App: App{NameSlug: "github"}},
+		{ID: "apn_3", ExternalID: "user-3", Name: "crm", Healthy: true, App: App{NameSlug: "hubspot"}},
+	}
+	live := []Account{
+		{ID: "apn_new_1", ExternalID: "user-1", Name: "work", Healthy: true, App: App{NameSlug: "slack"}},
+		{ID: "apn_3", ExternalID: "user-3", Name: "crm", Healthy: false, App: App{NameSlug: "hubspot"}},
+		{ID: "apn_4", ExternalID: "user-4", Name: "sheets", App: App{NameSlug: "google_sheets"}},
+	}
+
+	diff := DiffAccounts(exported, live)
+	require.Equal(1, diff.Unchanged)
+	require.Len(diff.Missing, 1)
+	require.Equal("apn_old_2", diff.Missing[0].ID)
+	require.Len(diff.Added, 1)
+	require.Equal("apn_4", diff.Added[0].ID)
+	require.Len(diff.Changed, 1)
+	require.Equal([]string{"healthy"}, diff.Changed[0].Fields)
+	require.False(diff.Empty())
+}
+
+func TestExport(t *testing.T) {
+	suite.Run(t, new(exportTestSuite))
+}
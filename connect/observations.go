@@ -0,0 +1,137 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type ObservationLevel string
+
+const (
+	ObservationDebug ObservationLevel = "debug"
+	ObservationInfo  ObservationLevel = "info"
+	ObservationWarn  ObservationLevel = "warn"
+	ObservationError ObservationLevel = "error"
+)
+
+// ObservationErr is the error attached to observations of thrown exceptions
+type ObservationErr struct {
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message,omitempty"`
+	Stack   string `json:"stack,omitempty"`
+}
+
+// Observation is a log line or error emitted by component code while Pipedream
+// configured or ran it
+type Observation struct {
+	// Kind is the raw observation kind, e.g. console.log or error
+	Kind      string           `json:"k,omitempty"`
+	Level     ObservationLevel `json:"-"`
+	Message   string           `json:"msg,omitempty"`
+	Timestamp time.Time        `json:"-"`
+	Err       *ObservationErr  `json:"err,omitempty"`
+}
+
+func (o *Observation) UnmarshalJSON(data []byte) error {
+	type observation Observation
+	var raw struct {
+		observation
+		TS float64 `json:"ts,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*o = Observation(raw.observation)
+	if raw.TS > 0 {
+		o.Timestamp = time.UnixMilli(int64(raw.TS)).UTC()
+	}
+	o.Level = observationLevel(o.Kind, o.Err != nil)
+	if o.Message == "" && o.Err != nil {
+		o.Message = o.Err.Message
+	}
+
+	return nil
+}
+
+func (o Observation) MarshalJSON() ([]byte, error) {
+	type observation Observation
+	raw := struct {
+		observation
+		TS    int64            `json:"ts,omitempty"`
+		Level ObservationLevel `json:"level,omitempty"`
+	}{observation: observation(o), Level: o.Level}
+	if !o.Timestamp.IsZero() {
+		raw.TS = o.Timestamp.UnixMilli()
+	}
+	return json.Marshal(raw)
+}
+
+func observationLevel(kind string, hasErr bool) ObservationLevel {
+	if hasErr {
+		return ObservationError
+	}
+	switch strings.TrimPrefix(kind, "console.") {
+	case "error":
+		return ObservationError
+	case "warn":
+		return ObservationWarn
+	case "debug", "trace":
+		return ObservationDebug
+	}
+	return ObservationInfo
+}
+
+// ObservationFunc receives the observations of configure, reload and run calls,
+// see Client.OnObservation
+type ObservationFunc func(ctx context.Context, componentKey string, observation Observation)
+
+// SlogObservations returns an ObservationFunc writing observations to logger
+func SlogObservations(logger *slog.Logger) ObservationFunc {
+	return func(ctx context.Context, componentKey string, o Observation) {
+		level := slog.LevelInfo
+		switch o.Level {
+		case ObservationDebug:
+			level = slog.LevelDebug
+		case ObservationWarn:
+			level = slog.LevelWarn
+		case ObservationError:
+			level = slog.LevelError
+		}
+		logger.Log(ctx, level, o.Message,
+			slog.String("component", componentKey),
+			slog.String("kind", o.Kind),
+			slog.Time("ts", o.Timestamp))
+	}
+}
+
+func (c *Client) observe(ctx context.Context, componentKey string, observations []Observation) {
+	if c.OnObservation == nil {
+		return
+	}
+	for _, o := range observations {
+		c.OnObservation(ctx, componentKey, o)
+	}
+}
+
+// PropConfigError is returned when a component reports errors while
+// configuring a prop or reloading props
+type PropConfigError struct {
+	ComponentKey string   `json:"component_key"`
+	Prop         string   `json:"prop,omitempty"`
+	Messages     []string `json:"messages"`
+	// Observations emitted by the component before it failed
+	Observations []Observation `json:"observations,omitempty"`
+}
+
+func (e *PropConfigError) Error() string {
+	target := e.ComponentKey
+	if e.Prop != "" {
+		target = fmt.Sprintf("prop %s of %s", e.Prop, e.ComponentKey)
+	}
+	return fmt.Sprintf("configuring %s: %s", target, strings.Join(e.Messages, "; "))
+}
@@ -0,0 +1,99 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type observationsTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+}
+
+func (suite *observationsTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+}
+
+func (suite *observationsTestSuite) TestObservation_JSON() {
+	require := suite.Require()
+
+	var observations []Observation
+	require.NoError(json.Unmarshal([]byte(`[
+		{"ts": 1717243200000, "k": "console.warn", "msg": "rate limited"},
+		{"ts": 1717243201000, "k": "error", "err": {"name": "Error", "message": "invalid sheet"}}
+	]`), &observations))
+
+	require.Equal(ObservationWarn, observations[0].Level)
+	require.Equal(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), observations[0].Timestamp)
+	require.Equal(ObservationError, observations[1].Level)
+	require.Equal("invalid sheet", observations[1].Message)
+
+	bs, err := json.Marshal(observations[0])
+	require.NoError(err)
+
+	var decoded Observation
+	require.NoError(json.Unmarshal(bs, &decoded))
+	require.Equal(observations[0], decoded)
+}
+
+func (suite *observationsTestSuite) TestGetPropOptions_StructuredErrors() {
+	require := suite.Require()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+		case r.URL.Path == "/project-abc/components/configure":
+			_, _ = fmt.Fprint(w, `{
+				"observations": [
+					{"ts": 1717243200000, "k": "console.log", "msg": "listing sheets"},
+					{"ts": 1717243201000, "k": "console.error", "msg": "403 from upstream"}
+				],
+				"errors": ["Request failed with status code 403", "Check the account scopes"]
+			}`)
+		}
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{
+		Client:        base,
+		OnObservation: SlogObservations(slog.New(slog.NewTextHandler(&logs, nil))),
+	}
+
+	_, err := suite.pipedreamClient.GetPropOptions(suite.ctx,
+		"sheetId", "google_sheets-add-single-row", "user-123", nil)
+
+	var configErr *PropConfigError
+	require.True(errors.As(err, &configErr))
+	require.Equal("sheetId", configErr.Prop)
+	require.Equal([]string{"Request failed with status code 403", "Check the account scopes"}, configErr.Messages)
+	require.Len(configErr.Observations, 2)
+	require.EqualError(err, "configuring prop sheetId of google_sheets-add-single-row: "+
+		"Request failed with status code 403; Check the account scopes")
+
+	require.Contains(logs.String(), `level=INFO msg="listing sheets" component=google_sheets-add-single-row`)
+	require.Contains(logs.String(), `level=ERROR msg="403 from upstream"`)
+}
+
+func TestObservations(t *testing.T) {
+	suite.Run(t, new(observationsTestSuite))
+}
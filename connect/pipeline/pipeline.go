@@ -0,0 +1,421 @@
+// Package pipeline chains Connect actions for one end user, the props of a step
+// reference the results of earlier steps with templates:
+//
+//	p, err := pipeline.Sequence(
+//		pipeline.Step{
+//			Name:         "find_row",
+//			ComponentKey: "google_sheets-find-row",
+//			Props:        connect.ConfiguredProps{"value": "{{input.email}}"},
+//		},
+//		pipeline.Step{
+//			Name:         "update_row",
+//			ComponentKey: "google_sheets-update-row",
+//			Props:        connect.ConfiguredProps{"row": "{{steps.find_row.ret.0.row}}"},
+//		},
+//	)
+//	result, err := p.Run(ctx, client, "jverce", map[string]any{"email": "jane@example.com"})
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+)
+
+var (
+	// StepSkippedErr is the error of steps not run because the pipeline failed
+	StepSkippedErr error = errors.New("step skipped")
+)
+
+// Invoker runs actions, *connect.Client implements it
+type Invoker interface {
+	InvokeAction(
+		ctx context.Context,
+		componentKey string,
+		externalUserID string,
+		props connect.ConfiguredProps,
+		dynamicPropsID string,
+		opts ...connect.InvokeActionOption,
+	) (*connect.ActionRunResult, error)
+}
+
+// Compensation is an action undoing a step during rollback, its props may
+// reference the step itself, e.g. {{steps.create_issue.ret.id}}
+type Compensation struct {
+	ComponentKey string
+	Props        connect.ConfiguredProps
+}
+
+type Step struct {
+	// Name identifies the step in templates, it cannot contain dots
+	Name         string
+	ComponentKey string
+	// Props may hold templates in string values, see Pipeline
+	Props connect.ConfiguredProps
+	// DependsOn lists steps to run first besides those referenced by templates
+	DependsOn []string
+	// Compensate is optional and runs if a later step fails
+	Compensate *Compensation
+}
+
+type StepStatus string
+
+const (
+	StepSucceeded   StepStatus = "succeeded"
+	StepFailed      StepStatus = "failed"
+	StepSkipped     StepStatus = "skipped"
+	StepCompensated StepStatus = "compensated"
+	// StepCompensationFailed steps succeeded but could not be undone
+	StepCompensationFailed StepStatus = "compensation_failed"
+)
+
+type StepResult struct {
+	Name   string
+	Status StepStatus
+	// Props are the props the step ran with, templates resolved
+	Props           connect.ConfiguredProps
+	Result          *connect.ActionRunResult
+	Err             error
+	Compensation    *connect.ActionRunResult
+	CompensationErr error
+	StartedAt       time.Time
+	FinishedAt      time.Time
+}
+
+// StepError is returned by Run for the step that failed the pipeline
+type StepError struct {
+	Step         string
+	ComponentKey string
+	Err          error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("pipeline step %s (%s): %v", e.Step, e.ComponentKey, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// CompensationError is returned along with the StepError when a compensating
+// action failed during rollback
+type CompensationError struct {
+	Step         string
+	ComponentKey string
+	Err          error
+}
+
+func (e *CompensationError) Error() string {
+	return fmt.Sprintf("compensating pipeline step %s (%s): %v", e.Step, e.ComponentKey, e.Err)
+}
+
+func (e *CompensationError) Unwrap() error {
+	return e.Err
+}
+
+type Result struct {
+	Steps map[string]*StepResult
+	// Order lists the steps in the order they finished
+	Order []string
+}
+
+// Ret decodes the return value of a step into v
+func (r *Result) Ret(step string, v any) error {
+	sr, ok := r.Steps[step]
+	if !ok || sr.Result == nil {
+		return fmt.Errorf("step %s has no result", step)
+	}
+	return sr.Result.DecodeRet(v)
+}
+
+// Pipeline is a validated graph of steps. Templates are {{input.<path>}} for the
+// input of Run and {{steps.<name>.ret.<path>}}, {{steps.<name>.exports.<path>}}
+// or {{steps.<name>.summary}} for the results of earlier steps. Numeric path
+// segments index arrays, text in braces with another root is not a template
+type Pipeline struct {
+	steps []Step
+	deps  map[string][]string
+	// Concurrency is the number of steps run in parallel, defaults to 4
+	Concurrency int
+}
+
+// Sequence runs the steps one after the other in the given order
+func Sequence(steps ...Step) (*Pipeline, error) {
+	chained := slices.Clone(steps)
+	for i := 1; i < len(chained); i++ {
+		chained[i].DependsOn = append(slices.Clone(chained[i].DependsOn), chained[i-1].Name)
+	}
+	return DAG(chained...)
+}
+
+// DAG runs every step once the steps it depends on succeeded, independent
+// steps run in parallel
+func DAG(steps ...Step) (*Pipeline, error) {
+	p := &Pipeline{steps: steps, deps: map[string][]string{}, Concurrency: 4}
+
+	names := map[string]bool{}
+	for _, step := range steps {
+		if step.Name == "" || strings.ContainsAny(step.Name, ". {}") {
+			return nil, fmt.Errorf("invalid step name %q", step.Name)
+		}
+		if step.ComponentKey == "" {
+			return nil, fmt.Errorf("step %s: component key is required", step.Name)
+		}
+		if names[step.Name] {
+			return nil, fmt.Errorf("duplicate step %s", step.Name)
+		}
+		names[step.Name] = true
+	}
+
+	for _, step := range steps {
+		refs, err := references(step.Props)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", step.Name, err)
+		}
+		deps := slices.Concat(step.DependsOn, refs)
+		slices.Sort(deps)
+		deps = slices.Compact(deps)
+		for _, dep := range deps {
+			if !names[dep] {
+				return nil, fmt.Errorf("step %s: unknown step %s", step.Name, dep)
+			}
+			if dep == step.Name {
+				return nil, fmt.Errorf("step %s depends on itself", step.Name)
+			}
+		}
+		if step.Compensate != nil {
+			refs, err := references(step.Compensate.Props)
+			if err != nil {
+				return nil, fmt.Errorf("step %s: compensation: %w", step.Name, err)
+			}
+			for _, ref := range refs {
+				if ref != step.Name && !slices.Contains(deps, ref) {
+					return nil, fmt.Errorf("step %s: compensation references %s, which the step does not depend on",
+						step.Name, ref)
+				}
+			}
+		}
+		p.deps[step.Name] = deps
+	}
+
+	if cycle := p.cycle(); cycle != nil {
+		return nil, fmt.Errorf("steps depend on each other: %s", strings.Join(cycle, " -> "))
+	}
+	return p, nil
+}
+
+// cycle returns the steps of a dependency cycle, nil if there is none
+func (p *Pipeline) cycle() []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(stack, name)
+			return append(slices.Clone(stack[start:]), name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range p.deps[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return nil
+	}
+	for _, step := range p.steps {
+		if cycle := visit(step.Name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+type run struct {
+	p              *Pipeline
+	invoker        Invoker
+	externalUserID string
+
+	mu     sync.Mutex
+	scope  scope
+	result *Result
+	failed *StepError
+}
+
+// Run executes the pipeline for externalUserID. When a step fails no further
+// steps start, and the compensations of the succeeded steps run in reverse
+// order of completion, as they do when ctx is done before all steps ran. The
+// error is a *StepError joined with a *CompensationError per failed compensation
+func (p *Pipeline) Run(ctx context.Context, invoker Invoker, externalUserID string, input map[string]any) (*Result, error) {
+	// templates look up the input in its JSON form
+	var inputScope any = map[string]any{}
+	if input != nil {
+		var err error
+		if inputScope, err = jsonValue(input); err != nil {
+			return nil, fmt.Errorf("pipeline input: %w", err)
+		}
+	}
+	r := &run{
+		p:              p,
+		invoker:        invoker,
+		externalUserID: externalUserID,
+		scope:          scope{"input": inputScope, "steps": map[string]any{}},
+		result:         &Result{Steps: map[string]*StepResult{}},
+	}
+	for _, step := range p.steps {
+		r.result.Steps[step.Name] = &StepResult{Name: step.Name, Status: StepSkipped, Err: StepSkippedErr}
+	}
+
+	r.execute(ctx)
+
+	var failure error
+	switch {
+	case r.failed != nil:
+		failure = r.failed
+	case ctx.Err() != nil && len(r.result.Order) < len(p.steps):
+		failure = fmt.Errorf("pipeline interrupted: %w", ctx.Err())
+	default:
+		return r.result, nil
+	}
+	return r.result, errors.Join(append([]error{failure}, r.rollback(ctx)...)...)
+}
+
+func (r *run) execute(ctx context.Context) {
+	concurrency := r.p.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	started := map[string]bool{}
+	finished := make(chan string)
+	running := 0
+
+	for {
+		r.mu.Lock()
+		failed := r.failed != nil
+		r.mu.Unlock()
+
+		if !failed && ctx.Err() == nil {
+			for _, step := range r.p.steps {
+				if running >= concurrency {
+					break
+				}
+				if started[step.Name] || !r.ready(step.Name) {
+					continue
+				}
+				started[step.Name] = true
+				running++
+				go func() {
+					r.runStep(ctx, step)
+					finished <- step.Name
+				}()
+			}
+		}
+
+		if running == 0 {
+			return
+		}
+		<-finished
+		running--
+	}
+}
+
+func (r *run) ready(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, dep := range r.p.deps[name] {
+		if r.result.Steps[dep].Status != StepSucceeded {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *run) runStep(ctx context.Context, step Step) {
+	sr := &StepResult{Name: step.Name, StartedAt: time.Now()}
+
+	r.mu.Lock()
+	rendered, err := r.scope.render(step.Props)
+	r.mu.Unlock()
+
+	if err == nil {
+		props, _ := rendered.(connect.ConfiguredProps)
+		sr.Props = props
+		sr.Result, err = r.invoker.InvokeAction(ctx, step.ComponentKey, r.externalUserID, props, "")
+	}
+
+	var stepScopeValue map[string]any
+	if err == nil {
+		stepScopeValue, err = stepScope(sr.Result)
+	}
+	sr.FinishedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Steps[step.Name] = sr
+	r.result.Order = append(r.result.Order, step.Name)
+	if err != nil {
+		sr.Status = StepFailed
+		sr.Err = err
+		if r.failed == nil {
+			r.failed = &StepError{Step: step.Name, ComponentKey: step.ComponentKey, Err: err}
+		}
+		return
+	}
+	sr.Status = StepSucceeded
+	r.scope["steps"].(map[string]any)[step.Name] = stepScopeValue
+}
+
+// rollback runs the compensations of succeeded steps, latest first. It runs
+// even if ctx is done
+func (r *run) rollback(ctx context.Context) []error {
+	ctx = context.WithoutCancel(ctx)
+
+	var errs []error
+	for _, name := range slices.Backward(r.result.Order) {
+		sr := r.result.Steps[name]
+		step := r.step(name)
+		if sr.Status != StepSucceeded || step.Compensate == nil {
+			continue
+		}
+
+		rendered, err := r.scope.render(step.Compensate.Props)
+		if err == nil {
+			props, _ := rendered.(connect.ConfiguredProps)
+			sr.Compensation, err = r.invoker.InvokeAction(ctx,
+				step.Compensate.ComponentKey, r.externalUserID, props, "")
+		}
+		if err != nil {
+			sr.Status = StepCompensationFailed
+			sr.CompensationErr = err
+			errs = append(errs, &CompensationError{Step: name, ComponentKey: step.Compensate.ComponentKey, Err: err})
+			continue
+		}
+		sr.Status = StepCompensated
+	}
+	return errs
+}
+
+func (r *run) step(name string) Step {
+	for _, step := range r.p.steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	return Step{}
+}
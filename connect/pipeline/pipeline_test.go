@@ -0,0 +1,251 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+	"github.com/stretchr/testify/suite"
+)
+
+const oathPath = "/oauth/token"
+
+type pipelineTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *connect.Client
+
+	mu      sync.Mutex
+	invoked []connect.InvokeActionRequest
+	// responses by component key, a missing key answers 500
+	responses map[string]string
+	delays    map[string]time.Duration
+}
+
+func (suite *pipelineTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.invoked = nil
+	suite.delays = map[string]time.Duration{}
+	suite.responses = map[string]string{
+		"google_sheets-find-row":   `{"exports": {"$summary": "Found 1 row"}, "ret": [{"row": 7, "name": "Jane"}]}`,
+		"google_sheets-update-row": `{"exports": {"$summary": "Updated row 7"}, "ret": {"updatedRange": "A7:C7"}}`,
+		"slack-send-message":       `{"exports": {}, "ret": {"ts": "1.2"}}`,
+		"slack-delete-message":     `{"exports": {}, "ret": {"ok": true}}`,
+		"hubspot-get-contact":      `{"exports": {}, "ret": {"id": "c1", "plan": "pro"}}`,
+		"jira-create-issue":        `{"os": [{"k": "error", "err": {"name": "Error", "message": "project archived"}}]}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == oathPath {
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+			return
+		}
+
+		var request connect.InvokeActionRequest
+		suite.Require().NoError(json.NewDecoder(r.Body).Decode(&request))
+		suite.mu.Lock()
+		suite.invoked = append(suite.invoked, request)
+		response, ok := suite.responses[request.ID]
+		delay := suite.delays[request.ID]
+		suite.mu.Unlock()
+
+		time.Sleep(delay)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprint(w, `{"error": "boom"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, response)
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &connect.Client{Client: base}
+}
+
+func (suite *pipelineTestSuite) invokedKeys() []string {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	var keys []string
+	for _, request := range suite.invoked {
+		keys = append(keys, request.ID)
+	}
+	return keys
+}
+
+func (suite *pipelineTestSuite) TestSequence() {
+	require := suite.Require()
+
+	p, err := Sequence(
+		Step{
+			Name:         "find_row",
+			ComponentKey: "google_sheets-find-row",
+			Props:        connect.ConfiguredProps{"value": "{{input.email}}"},
+		},
+		Step{
+			Name:         "update_row",
+			ComponentKey: "google_sheets-update-row",
+			Props: connect.ConfiguredProps{
+				"row":   "{{steps.find_row.ret.0.row}}",
+				"cells": []any{"{{steps.find_row.ret.0.name}}", "contacted"},
+			},
+		},
+		Step{
+			Name:         "notify",
+			ComponentKey: "slack-send-message",
+			Props:        connect.ConfiguredProps{"text": "{{steps.update_row.summary}} ({{steps.update_row.ret.updatedRange}})"},
+		},
+	)
+	require.NoError(err)
+
+	result, err := p.Run(suite.ctx, suite.pipedreamClient, "jverce", map[string]any{"email": "jane@example.com"})
+	require.NoError(err)
+	require.Equal([]string{"find_row", "update_row", "notify"}, result.Order)
+	require.Equal([]string{"google_sheets-find-row", "google_sheets-update-row", "slack-send-message"}, suite.invokedKeys())
+
+	require.Equal("jane@example.com", suite.invoked[0].ConfiguredProps["value"])
+	require.Equal("jverce", suite.invoked[0].ExternalUserID)
+	require.EqualValues(7, suite.invoked[1].ConfiguredProps["row"])
+	require.Equal([]any{"Jane", "contacted"}, suite.invoked[1].ConfiguredProps["cells"])
+	require.Equal("Updated row 7 (A7:C7)", suite.invoked[2].ConfiguredProps["text"])
+
+	for _, name := range result.Order {
+		require.Equal(StepSucceeded, result.Steps[name].Status)
+	}
+	var sent struct {
+		TS string `json:"ts"`
+	}
+	require.NoError(result.Ret("notify", &sent))
+	require.Equal("1.2", sent.TS)
+}
+
+func (suite *pipelineTestSuite) TestDAG() {
+	require := suite.Require()
+	suite.delays["google_sheets-find-row"] = 50 * time.Millisecond
+
+	p, err := DAG(
+		Step{Name: "notify", ComponentKey: "slack-send-message", Props: connect.ConfiguredProps{
+			"text": "{{steps.contact.ret.plan}} row {{steps.find_row.ret.0.row}}",
+		}},
+		Step{Name: "find_row", ComponentKey: "google_sheets-find-row"},
+		Step{Name: "contact", ComponentKey: "hubspot-get-contact"},
+	)
+	require.NoError(err)
+
+	result, err := p.Run(suite.ctx, suite.pipedreamClient, "jverce", nil)
+	require.NoError(err)
+	// the independent steps run in parallel, the slower one finishes last
+	require.Equal([]string{"contact", "find_row", "notify"}, result.Order)
+	require.Equal("pro row 7", result.Steps["notify"].Props["text"])
+}
+
+func (suite *pipelineTestSuite) TestRollback() {
+	require := suite.Require()
+
+	p, err := Sequence(
+		Step{
+			Name:         "announce",
+			ComponentKey: "slack-send-message",
+			Props:        connect.ConfiguredProps{"text": "creating issue"},
+			Compensate: &Compensation{
+				ComponentKey: "slack-delete-message",
+				Props:        connect.ConfiguredProps{"ts": "{{steps.announce.ret.ts}}"},
+			},
+		},
+		Step{
+			Name:         "lookup",
+			ComponentKey: "hubspot-get-contact",
+			Compensate: &Compensation{
+				// a failing compensation is reported and does not stop the rollback
+				ComponentKey: "hubspot-restore-contact",
+			},
+		},
+		Step{Name: "create_issue", ComponentKey: "jira-create-issue"},
+		Step{Name: "notify", ComponentKey: "slack-send-message"},
+	)
+	require.NoError(err)
+
+	result, err := p.Run(suite.ctx, suite.pipedreamClient, "jverce", nil)
+	var stepErr *StepError
+	require.ErrorAs(err, &stepErr)
+	require.Equal("create_issue", stepErr.Step)
+	var actionErr *connect.ActionError
+	require.ErrorAs(err, &actionErr)
+	require.Equal("project archived", actionErr.Message)
+
+	var compensationErr *CompensationError
+	require.ErrorAs(err, &compensationErr)
+	require.Equal("lookup", compensationErr.Step)
+
+	require.Equal([]string{
+		"slack-send-message",
+		"hubspot-get-contact",
+		"jira-create-issue",
+		"hubspot-restore-contact",
+		"slack-delete-message",
+	}, suite.invokedKeys())
+	require.Equal("1.2", suite.invoked[4].ConfiguredProps["ts"])
+
+	require.Equal(StepCompensated, result.Steps["announce"].Status)
+	require.Equal(StepCompensationFailed, result.Steps["lookup"].Status)
+	require.Equal(StepFailed, result.Steps["create_issue"].Status)
+	require.Equal(StepSkipped, result.Steps["notify"].Status)
+	require.ErrorIs(result.Steps["notify"].Err, StepSkippedErr)
+}
+
+func (suite *pipelineTestSuite) TestTemplateFailure() {
+	require := suite.Require()
+
+	p, err := Sequence(
+		Step{Name: "find_row", ComponentKey: "google_sheets-find-row"},
+		Step{Name: "update_row", ComponentKey: "google_sheets-update-row", Props: connect.ConfiguredProps{
+			"row": "{{steps.find_row.ret.3.row}}",
+		}},
+	)
+	require.NoError(err)
+
+	_, err = p.Run(suite.ctx, suite.pipedreamClient, "jverce", nil)
+	var stepErr *StepError
+	require.ErrorAs(err, &stepErr)
+	require.Equal("update_row", stepErr.Step)
+	require.ErrorIs(err, UnresolvedReferenceErr)
+	require.Len(suite.invoked, 1)
+}
+
+func (suite *pipelineTestSuite) TestValidation() {
+	require := suite.Require()
+	step := func(name string, props connect.ConfiguredProps, deps ...string) Step {
+		return Step{Name: name, ComponentKey: "slack-send-message", Props: props, DependsOn: deps}
+	}
+
+	_, err := DAG(step("a", nil), step("a", nil))
+	require.ErrorContains(err, "duplicate step a")
+	_, err = DAG(step("a.b", nil))
+	require.ErrorContains(err, "invalid step name")
+	_, err = DAG(step("a", connect.ConfiguredProps{"x": "{{steps.missing.ret}}"}))
+	require.ErrorContains(err, "step a: unknown step missing")
+	_, err = DAG(step("a", nil, "c"), step("b", nil, "a"), step("c", connect.ConfiguredProps{"x": "{{steps.b.ret}}"}))
+	require.ErrorContains(err, "steps depend on each other: a -> c -> b -> a")
+	_, err = DAG(step("a", nil), Step{Name: "b", ComponentKey: "x", Compensate: &Compensation{
+		ComponentKey: "y",
+		Props:        connect.ConfiguredProps{"x": "{{steps.a.ret}}"},
+	}})
+	require.ErrorContains(err, "compensation references a")
+	_, err = DAG(step("a", connect.NewProps().App("sheets", "{{steps.missing.ret.id}}").Build()))
+	require.ErrorContains(err, "step a: unknown step missing")
+	_, err = DAG(step("a", nil), step("b", connect.ConfiguredProps{"x": "{{steps.a.output}}"}))
+	require.ErrorContains(err, "step b: {{steps.a.output}}: unknown step field output")
+	_, err = DAG(step("a", connect.ConfiguredProps{"x": make(chan int)}))
+	require.ErrorContains(err, "step a: encoding chan int")
+}
+
+func TestPipeline(t *testing.T) {
+	suite.Run(t, new(pipelineTestSuite))
+}
@@ -0,0 +1,235 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+)
+
+var UnresolvedReferenceErr error = errors.New("unresolved reference")
+
+// templatePattern matches the templates rooted at input or steps, other text
+// in braces, e.g. merge tags like {{first_name}}, is passed through unchanged
+var templatePattern = regexp.MustCompile(`\{\{\s*((?:input|steps)(?:\.[^{}]*?)?)\s*\}\}`)
+
+// scope is what templates reference: input.<path> and
+// steps.<name>.(ret|exports|summary).<path>
+type scope map[string]any
+
+func stepScope(result *connect.ActionRunResult) (map[string]any, error) {
+	out := map[string]any{"exports": map[string]any{}, "summary": "", "ret": nil}
+	if result == nil {
+		return out, nil
+	}
+	if result.Exports != nil {
+		out["exports"] = result.Exports
+	}
+	out["summary"] = result.Summary
+	if len(result.Ret) > 0 {
+		var ret any
+		if err := json.Unmarshal(result.Ret, &ret); err != nil {
+			return nil, fmt.Errorf("decoding return value: %w", err)
+		}
+		out["ret"] = ret
+	}
+	return out, nil
+}
+
+// lookup resolves a dotted path, numeric segments index arrays
+func (s scope) lookup(expr string) (any, error) {
+	var current any = map[string]any(s)
+	for i, segment := range strings.Split(expr, ".") {
+		switch v := current.(type) {
+		case map[string]any:
+			next, ok := v[segment]
+			if !ok {
+				return nil, fmt.Errorf("{{%s}}: %s: %w", expr, strings.Join(strings.Split(expr, ".")[:i+1], "."), UnresolvedReferenceErr)
+			}
+			current = next
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("{{%s}}: index %s of %d items: %w", expr, segment, len(v), UnresolvedReferenceErr)
+			}
+			current = v[index]
+		default:
+			return nil, fmt.Errorf("{{%s}}: %s is not an object or array: %w",
+				expr, strings.Join(strings.Split(expr, ".")[:i], "."), UnresolvedReferenceErr)
+		}
+	}
+	return current, nil
+}
+
+// render resolves the templates in value. A string that is a single template
+// is replaced by the referenced value, keeping its type; templates within
+// longer strings are interpolated. Values of other types, e.g. map[string]string
+// or structs, are rendered in their JSON form
+func (s scope) render(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, bool, json.Number, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value, nil
+	case string:
+		return s.renderString(v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			rendered, err := s.render(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = rendered
+		}
+		return out, nil
+	case connect.ConfiguredProps:
+		out := make(connect.ConfiguredProps, len(v))
+		for key, item := range v {
+			rendered, err := s.render(item)
+			if err != nil {
+				return nil, fmt.Errorf("prop %s: %w", key, err)
+			}
+			out[key] = rendered
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			rendered, err := s.render(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	case []string:
+		out := make([]any, len(v))
+		for i, item := range v {
+			rendered, err := s.renderString(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+
+	normalized, err := jsonValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.render(normalized)
+}
+
+// jsonValue decodes the JSON encoding of value, keeping numbers as json.Number
+func jsonValue(value any) (any, error) {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %T: %w", value, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("decoding %T: %w", value, err)
+	}
+	return out, nil
+}
+
+func (s scope) renderString(text string) (any, error) {
+	matches := templatePattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, nil
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(text) {
+		return s.lookup(text[matches[0][2]:matches[0][3]])
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m[0]])
+		value, err := s.lookup(text[m[2]:m[3]])
+		if err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case string:
+			b.WriteString(v)
+		case nil:
+		case map[string]any, []any:
+			bs, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			b.Write(bs)
+		default:
+			fmt.Fprint(&b, v)
+		}
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String(), nil
+}
+
+// references returns the names of the steps the templates in value refer to,
+// an error if value cannot be rendered or a step template has no name or an
+// unknown field
+func references(value any) ([]string, error) {
+	var refs []string
+	var errs []error
+	var walk func(any)
+	walk = func(value any) {
+		switch v := value.(type) {
+		case nil, bool, json.Number, int, int8, int16, int32, int64,
+			uint, uint8, uint16, uint32, uint64, float32, float64:
+		case string:
+			for _, m := range templatePattern.FindAllStringSubmatch(v, -1) {
+				if m[1] != "steps" && !strings.HasPrefix(m[1], "steps.") {
+					continue
+				}
+				segments := strings.SplitN(m[1], ".", 4)
+				if len(segments) < 2 || segments[1] == "" {
+					errs = append(errs, fmt.Errorf("{{%s}}: missing step name", m[1]))
+					continue
+				}
+				if len(segments) > 2 && !slices.Contains([]string{"ret", "exports", "summary"}, segments[2]) {
+					errs = append(errs, fmt.Errorf("{{%s}}: unknown step field %s, expected ret, exports or summary",
+						m[1], segments[2]))
+				}
+				refs = append(refs, segments[1])
+			}
+		case map[string]any:
+			for _, item := range v {
+				walk(item)
+			}
+		case connect.ConfiguredProps:
+			for _, item := range v {
+				walk(item)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case []string:
+			for _, item := range v {
+				walk(item)
+			}
+		default:
+			normalized, err := jsonValue(v)
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			walk(normalized)
+		}
+	}
+	walk(value)
+	return refs, errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+	"github.com/stretchr/testify/suite"
+)
+
+type templateTestSuite struct {
+	suite.Suite
+	scope scope
+}
+
+func (suite *templateTestSuite) SetupTest() {
+	find, err := stepScope(&connect.ActionRunResult{
+		Exports: map[string]any{"$summary": "Found 2 rows"},
+		Ret:     json.RawMessage(`[{"row": 7, "email": "jane@example.com"}, {"row": 9}]`),
+		Summary: "Found 2 rows",
+	})
+	suite.Require().NoError(err)
+
+	suite.scope = scope{
+		"input": map[string]any{"email": "jane@example.com", "tags": []any{"vip"}},
+		"steps": map[string]any{"find_row": find},
+	}
+}
+
+func (suite *templateTestSuite) TestRender() {
+	require := suite.Require()
+
+	rendered, err := suite.scope.render(connect.ConfiguredProps{
+		"row":     "{{steps.find_row.ret.0.row}}",
+		"first":   "{{ steps.find_row.ret.0 }}",
+		"note":    "Row {{steps.find_row.ret.0.row}} of {{steps.find_row.summary}} for {{input.email}}",
+		"tags":    "tags: {{input.tags}}",
+		"nested":  map[string]any{"to": []any{"{{input.email}}", "ops@example.com"}},
+		"list":    []string{"{{steps.find_row.ret.1.row}}"},
+		"literal": 42,
+		"braces":  "{not a template}",
+		"merge":   "Hi {{first_name}}, {{ inputs.email }}",
+	})
+	require.NoError(err)
+	require.Equal(connect.ConfiguredProps{
+		"row":     float64(7),
+		"first":   map[string]any{"row": float64(7), "email": "jane@example.com"},
+		"note":    "Row 7 of Found 2 rows for jane@example.com",
+		"tags":    `tags: ["vip"]`,
+		"nested":  map[string]any{"to": []any{"jane@example.com", "ops@example.com"}},
+		"list":    []any{float64(9)},
+		"literal": 42,
+		"braces":  "{not a template}",
+		"merge":   "Hi {{first_name}}, {{ inputs.email }}",
+	}, rendered)
+}
+
+func (suite *templateTestSuite) TestUnresolved() {
+	require := suite.Require()
+
+	for expr, msg := range map[string]string{
+		"{{steps.find_row.ret.5.row}}":     "index 5 of 2 items",
+		"{{steps.find_row.ret.0.missing}}": "steps.find_row.ret.0.missing",
+		"{{steps.update_row.ret}}":         "steps.update_row",
+		"x {{steps.find_row.summary.x}}":   "steps.find_row.summary is not an object or array",
+	} {
+		_, err := suite.scope.render(connect.ConfiguredProps{"p": expr})
+		require.ErrorIs(err, UnresolvedReferenceErr, expr)
+		require.ErrorContains(err, msg)
+		require.ErrorContains(err, "prop p")
+	}
+}
+
+func (suite *templateTestSuite) TestRenderTyped() {
+	require := suite.Require()
+
+	type filter struct {
+		Email string   `json:"email"`
+		Tags  []string `json:"tags"`
+		Limit int      `json:"limit"`
+	}
+	props := connect.NewProps().
+		App("sheets", "{{steps.find_row.ret.0.email}}").
+		Set("filter", filter{Email: "{{input.email}}", Tags: []string{"{{input.tags.0}}"}, Limit: 5}).
+		Build()
+	rendered, err := suite.scope.render(props)
+	require.NoError(err)
+	require.Equal(connect.ConfiguredProps{
+		"sheets": map[string]any{"authProvisionId": "jane@example.com"},
+		"filter": map[string]any{"email": "jane@example.com", "tags": []any{"vip"}, "limit": json.Number("5")},
+	}, rendered)
+
+	_, err = suite.scope.render(connect.ConfiguredProps{"p": map[string]any{"ch": make(chan int)}})
+	require.ErrorContains(err, "prop p: encoding chan int")
+}
+
+func (suite *templateTestSuite) TestReferences() {
+	require := suite.Require()
+	refs, err := references(connect.ConfiguredProps{
+		"a": "{{steps.find_row.ret.id}} and {{ steps.lookup.summary }}",
+		"b": map[string]any{"c": []any{"{{input.x}}", "{{steps.other.exports.y}}"}},
+		"d": map[string]string{"authProvisionId": "{{steps.account.ret.id}}"},
+		"n": 3,
+	})
+	require.NoError(err)
+	require.ElementsMatch([]string{"find_row", "lookup", "other", "account"}, refs)
+
+	_, err = references(connect.ConfiguredProps{"p": func() {}})
+	require.ErrorContains(err, "encoding func()")
+	_, err = references(connect.ConfiguredProps{"p": "{{steps}}"})
+	require.ErrorContains(err, "{{steps}}: missing step name")
+	_, err = references(connect.ConfiguredProps{"p": "{{steps.find_row.result.id}}"})
+	require.ErrorContains(err, "unknown step field result")
+}
+
+func TestTemplate(t *testing.T) {
+	suite.Run(t, new(templateTestSuite))
+}
@@ -0,0 +1,330 @@
+package connect
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var PolicyDeniedErr error = errors.New("denied by policy")
+
+type PolicyOperation string
+
+const (
+	PolicyInvokeAction  PolicyOperation = "invoke_action"
+	PolicyDeployTrigger PolicyOperation = "deploy_trigger"
+	PolicyProxy         PolicyOperation = "proxy"
+)
+
+type PolicyEffect string
+
+const (
+	PolicyAllow PolicyEffect = "allow"
+	PolicyDeny  PolicyEffect = "deny"
+)
+
+// PolicyRequest describes a call checked by a Policy. ComponentKey and App are
+// set for actions and triggers, Method and Host for proxy calls
+type PolicyRequest struct {
+	Operation      PolicyOperation   `json:"operation"`
+	ExternalUserID string            `json:"external_user_id"`
+	UserAttributes map[string]string `json:"user_attributes,omitempty"`
+	ComponentKey   string            `json:"component_key,omitempty"`
+	App            string            `json:"app,omitempty"`
+	Method         string            `json:"method,omitempty"`
+	Host           string            `json:"host,omitempty"`
+}
+
+// PolicyRule matches a request when every non-empty matcher matches. Lists match
+// when any entry matches, patterns use path.Match syntax, e.g. github-delete-*
+type PolicyRule struct {
+	Name       string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Effect     PolicyEffect      `json:"effect" yaml:"effect"`
+	Operations []PolicyOperation `json:"operations,omitempty" yaml:"operations,omitempty"`
+	Components []string          `json:"components,omitempty" yaml:"components,omitempty"`
+	Apps       []string          `json:"apps,omitempty" yaml:"apps,omitempty"`
+	Users      []string          `json:"users,omitempty" yaml:"users,omitempty"`
+	// Attributes maps a user attribute to the patterns one of which its value must match
+	Attributes map[string][]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	Methods    []string            `json:"methods,omitempty" yaml:"methods,omitempty"`
+	Hosts      []string            `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+	// Reason is reported in the PolicyDeniedError
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// UserAttributesFunc looks up the attributes of an end user, e.g. their plan
+type UserAttributesFunc func(ctx context.Context, externalUserID string) (map[string]string, error)
+
+// Policy holds ordered allow and deny rules, the first matching rule decides.
+// Requests no rule matches get the Default effect, allow if unset
+type Policy struct {
+	Default PolicyEffect `json:"default,omitempty" yaml:"default,omitempty"`
+	Rules   []PolicyRule `json:"rules" yaml:"rules"`
+
+	// Attributes is optional, its attributes are overridden by those of
+	// WithUserAttributes
+	Attributes UserAttributesFunc `json:"-" yaml:"-"`
+}
+
+// PolicyDeniedError is returned by InvokeAction, DeployTrigger and Proxy when
+// the Policy of the client denies the call
+type PolicyDeniedError struct {
+	Request PolicyRequest
+	// Rule is the name of the denying rule, empty for the default effect
+	Rule   string
+	Reason string
+}
+
+func (e *PolicyDeniedError) Error() string {
+	target := e.Request.ComponentKey
+	if e.Request.Operation == PolicyProxy {
+		target = e.Request.Method + " " + cmp.Or(e.Request.Host, "(relative URL)")
+	}
+	msg := fmt.Sprintf("%s %s for user %s denied by policy", e.Request.Operation, target, e.Request.ExternalUserID)
+	if e.Rule != "" {
+		msg += fmt.Sprintf(" rule %s", e.Rule)
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *PolicyDeniedError) Is(target error) bool {
+	return target == PolicyDeniedErr
+}
+
+type userAttributesKey struct{}
+
+// WithUserAttributes attaches the attributes of the end user acting in ctx, they
+// are matched by the Attributes of policy rules
+func WithUserAttributes(ctx context.Context, attributes map[string]string) context.Context {
+	return context.WithValue(ctx, userAttributesKey{}, attributes)
+}
+
+// LoadPolicy reads a policy from a JSON or YAML file
+func LoadPolicy(file string) (*Policy, error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy: %w", err)
+	}
+	policy, err := ParsePolicy(bs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return policy, nil
+}
+
+// ParsePolicy decodes a policy from JSON or YAML, unknown fields are rejected
+func ParsePolicy(data []byte) (*Policy, error) {
+	// YAML is a superset of JSON
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var policy Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("decoding policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate checks the effects, operations and patterns of the rules
+func (p *Policy) Validate() error {
+	var errs []error
+	switch p.Default {
+	case "", PolicyAllow, PolicyDeny:
+	default:
+		errs = append(errs, fmt.Errorf("default: unknown effect %q", p.Default))
+	}
+
+	for i, rule := range p.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		if rule.Effect != PolicyAllow && rule.Effect != PolicyDeny {
+			errs = append(errs, fmt.Errorf("rule %s: unknown effect %q", name, rule.Effect))
+		}
+		for _, op := range rule.Operations {
+			if op != PolicyInvokeAction && op != PolicyDeployTrigger && op != PolicyProxy {
+				errs = append(errs, fmt.Errorf("rule %s: unknown operation %q", name, op))
+			}
+		}
+
+		patterns := slices.Concat(rule.Components, rule.Apps, rule.Users, rule.Methods, rule.Hosts)
+		for _, values := range rule.Attributes {
+			patterns = append(patterns, values...)
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("rule %s: invalid pattern %q", name, pattern))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Evaluate returns a *PolicyDeniedError if the request is denied. The user
+// attributes are resolved when the request has none
+func (p *Policy) Evaluate(ctx context.Context, req PolicyRequest) error {
+	if req.UserAttributes == nil {
+		attributes, err := p.userAttributes(ctx, req.ExternalUserID)
+		if err != nil {
+			return err
+		}
+		req.UserAttributes = attributes
+	}
+
+	for _, rule := range p.Rules {
+		if !rule.matches(req) {
+			continue
+		}
+		if rule.Effect == PolicyDeny {
+			return &PolicyDeniedError{Request: req, Rule: rule.Name, Reason: rule.Reason}
+		}
+		return nil
+	}
+
+	if p.Default == PolicyDeny {
+		return &PolicyDeniedError{Request: req, Reason: "no rule allows it"}
+	}
+	return nil
+}
+
+func (p *Policy) userAttributes(ctx context.Context, externalUserID string) (map[string]string, error) {
+	attributes := map[string]string{}
+	if p.Attributes != nil {
+		resolved, err := p.Attributes(ctx, externalUserID)
+		if err != nil {
+			return nil, fmt.Errorf("resolving attributes of user %s: %w", externalUserID, err)
+		}
+		for k, v := range resolved {
+			attributes[k] = v
+		}
+	}
+	if fromCtx, ok := ctx.Value(userAttributesKey{}).(map[string]string); ok {
+		for k, v := range fromCtx {
+			attributes[k] = v
+		}
+	}
+	return attributes, nil
+}
+
+func (r *PolicyRule) matches(req PolicyRequest) bool {
+	if len(r.Operations) > 0 && !slices.Contains(r.Operations, req.Operation) {
+		return false
+	}
+
+	deny := r.Effect == PolicyDeny
+	matchers := []struct {
+		patterns []string
+		value    string
+		// unknown is set when an empty value means it could not be determined
+		unknown bool
+	}{
+		{r.Components, req.ComponentKey, false},
+		{r.Apps, req.App, req.App == ""},
+		{r.Users, req.ExternalUserID, false},
+		{r.Methods, strings.ToUpper(req.Method), false},
+		{r.Hosts, strings.ToLower(req.Host), req.Host == "" && req.Operation == PolicyProxy},
+	}
+
+	// an app or proxy host that could not be determined, e.g. the host of a
+	// relative URL, fails closed for deny rules that match the request by
+	// another matcher, and matches no other rule
+	unknown, matched := false, false
+	for _, m := range matchers {
+		if len(m.patterns) == 0 {
+			continue
+		}
+		if m.unknown && deny {
+			unknown = true
+			continue
+		}
+		if !matchAny(m.patterns, m.value) {
+			return false
+		}
+		matched = true
+	}
+	for attribute, patterns := range r.Attributes {
+		value, ok := req.UserAttributes[attribute]
+		if !ok || !matchAny(patterns, value) {
+			return false
+		}
+		matched = true
+	}
+	return !unknown || matched
+}
+
+// matchAny reports whether value matches one of patterns, an empty list
+// matches anything and an empty value matches no non-empty list
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if value == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(value)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// usesApps reports whether any rule restricts apps
+func (p *Policy) usesApps() bool {
+	return slices.ContainsFunc(p.Rules, func(r PolicyRule) bool { return len(r.Apps) > 0 })
+}
+
+// checkPolicy evaluates the Policy of the client if one is set
+func (c *Client) checkPolicy(ctx context.Context, req PolicyRequest) error {
+	if c.Policy == nil {
+		return nil
+	}
+	return c.Policy.Evaluate(ctx, req)
+}
+
+func componentPolicyRequest(op PolicyOperation, componentKey string, externalUserID string) PolicyRequest {
+	return PolicyRequest{
+		Operation:      op,
+		ExternalUserID: externalUserID,
+		ComponentKey:   componentKey,
+		App:            appFromComponentKey(componentKey),
+	}
+}
+
+// proxyPolicyRequest describes a proxy call. Relative URLs leave the host
+// empty, the app is the one of the proxied account when rules restrict apps
+func (c *Client) proxyPolicyRequest(ctx context.Context, pr ProxyRequest) (PolicyRequest, error) {
+	req := PolicyRequest{
+		Operation:      PolicyProxy,
+		ExternalUserID: pr.ExternalUserID,
+		Method:         strings.ToUpper(pr.Method),
+	}
+	if u, err := url.Parse(pr.URL); err == nil {
+		req.Host = strings.ToLower(u.Hostname())
+	}
+	if c.Policy != nil && c.Policy.usesApps() {
+		// the call is not let through with an app no rule could check
+		account, err := c.GetAccount(ctx, pr.ExternalUserID, "", false, pr.AccountID)
+		if err != nil {
+			return req, fmt.Errorf("looking up the app of account %s for the policy: %w", pr.AccountID, err)
+		}
+		req.App = account.Data.App.NameSlug
+	}
+	return req, nil
+}
@@ -0,0 +1,263 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type policyTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+	calls           []string
+}
+
+const policyYAML = `
+default: allow
+rules:
+  - name: admins
+    effect: allow
+    attributes:
+      role: [admin]
+  - name: no-repo-deletes
+    effect: deny
+    operations: [invoke_action]
+    components: ["github-delete-*"]
+    reason: deleting repositories is not allowed
+  - name: free-plan-writes
+    effect: deny
+    apps: [github, gitlab]
+    attributes:
+      plan: [free]
+    components: ["*-create-*", "*-update-*"]
+    reason: upgrade to create or update
+  - name: internal-hosts
+    effect: deny
+    operations: [proxy]
+    hosts: ["*.internal.example.com"]
+  - name: no-proxy-deletes
+    effect: deny
+    methods: [DELETE]
+`
+
+func (suite *policyTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.calls = nil
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == oathPath {
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+			return
+		}
+		suite.calls = append(suite.calls, r.URL.Path)
+		switch r.URL.Path {
+		case "/project-abc/actions/run":
+			_, _ = fmt.Fprint(w, `{"exports": {}, "ret": null}`)
+		case "/project-abc/triggers/deploy":
+			_, _ = fmt.Fprint(w, `{"data": {"id": "dc_1"}}`)
+		case "/project-abc/accounts/apn_1":
+			_, _ = fmt.Fprint(w, `{"data": {"id": "apn_1", "app": {"name_slug": "github"}}}`)
+		case "/project-abc/accounts/apn_2":
+			_, _ = fmt.Fprint(w, `{"data": {"id": "apn_2", "app": {"name_slug": "stripe"}}}`)
+		case "/project-abc/accounts/apn_3":
+			_, _ = fmt.Fprint(w, `{"data": {"id": "apn_3", "app": {"name_slug": "slack"}}}`)
+		case "/project-abc/accounts/apn_missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			_, _ = fmt.Fprint(w, `{}`)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	policy, err := ParsePolicy([]byte(policyYAML))
+	suite.Require().NoError(err)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base, Policy: policy}
+}
+
+func (suite *policyTestSuite) TestEvaluate() {
+	require := suite.Require()
+	policy := suite.pipedreamClient.Policy
+	policy.Attributes = func(_ context.Context, externalUserID string) (map[string]string, error) {
+		if externalUserID == "broken" {
+			return nil, errors.New("user service down")
+		}
+		return map[string]string{"plan": "free"}, nil
+	}
+
+	err := policy.Evaluate(suite.ctx, componentPolicyRequest(PolicyInvokeAction, "github-delete-repository", "user-1"))
+	var denied *PolicyDeniedError
+	require.ErrorAs(err, &denied)
+	require.ErrorIs(err, PolicyDeniedErr)
+	require.Equal("no-repo-deletes", denied.Rule)
+	require.Equal("github", denied.Request.App)
+	require.Equal("invoke_action github-delete-repository for user user-1 denied by policy rule no-repo-deletes: "+
+		"deleting repositories is not allowed", err.Error())
+
+	// deploying a trigger with the same key is not covered by the rule
+	require.NoError(policy.Evaluate(suite.ctx, componentPolicyRequest(PolicyDeployTrigger, "github-delete-repository", "user-1")))
+
+	// attributes come from the resolver and are overridden by the context
+	err = policy.Evaluate(suite.ctx, componentPolicyRequest(PolicyInvokeAction, "github-create-issue", "user-1"))
+	require.ErrorAs(err, &denied)
+	require.Equal("free-plan-writes", denied.Rule)
+	paid := WithUserAttributes(suite.ctx, map[string]string{"plan": "team"})
+	require.NoError(policy.Evaluate(paid, componentPolicyRequest(PolicyInvokeAction, "github-create-issue", "user-1")))
+	require.NoError(policy.Evaluate(suite.ctx, componentPolicyRequest(PolicyInvokeAction, "slack-create-channel", "user-1")))
+
+	// the first matching rule wins
+	admin := WithUserAttributes(suite.ctx, map[string]string{"role": "admin"})
+	require.NoError(policy.Evaluate(admin, componentPolicyRequest(PolicyInvokeAction, "github-delete-repository", "user-1")))
+
+	err = policy.Evaluate(suite.ctx, componentPolicyRequest(PolicyInvokeAction, "github-create-issue", "broken"))
+	require.ErrorContains(err, "user service down")
+	require.NotErrorIs(err, PolicyDeniedErr)
+
+	policy.Default = PolicyDeny
+	err = policy.Evaluate(suite.ctx, componentPolicyRequest(PolicyInvokeAction, "slack-send-message", "user-1"))
+	require.ErrorAs(err, &denied)
+	require.Empty(denied.Rule)
+}
+
+func (suite *policyTestSuite) TestClientHooks() {
+	require := suite.Require()
+	c := suite.pipedreamClient
+
+	_, err := c.InvokeAction(suite.ctx, "github-delete-repository", "user-1", ConfiguredProps{}, "")
+	require.ErrorIs(err, PolicyDeniedErr)
+	require.Empty(suite.calls)
+
+	_, err = c.InvokeAction(suite.ctx, "github-list-repositories", "user-1", ConfiguredProps{}, "")
+	require.NoError(err)
+
+	trigger, err := c.DeployTrigger(suite.ctx, "github-new-issue", "user-1", ConfiguredProps{}, "", "", "")
+	require.NoError(err)
+	require.Equal("dc_1", trigger.ID)
+
+	_, err = c.Proxy(suite.ctx, ProxyRequest{
+		ExternalUserID: "user-1",
+		AccountID:      "apn_1",
+		Method:         "get",
+		URL:            "https://billing.INTERNAL.example.com/v1/invoices",
+	})
+	var denied *PolicyDeniedError
+	require.ErrorAs(err, &denied)
+	require.Equal("internal-hosts", denied.Rule)
+	require.Equal("proxy GET billing.internal.example.com for user user-1 denied by policy rule internal-hosts", err.Error())
+
+	_, err = c.Proxy(suite.ctx, ProxyRequest{
+		ExternalUserID: "user-1",
+		AccountID:      "apn_1",
+		Method:         "delete",
+		URL:            "https://api.github.com/repos/a/b",
+	})
+	require.ErrorAs(err, &denied)
+	require.Equal("no-proxy-deletes", denied.Rule)
+
+	_, err = c.Proxy(suite.ctx, ProxyRequest{
+		ExternalUserID: "user-1",
+		AccountID:      "apn_1",
+		Method:         "GET",
+		URL:            "https://api.github.com/user",
+	})
+	require.NoError(err)
+	require.Equal([]string{
+		"/project-abc/actions/run",
+		"/project-abc/triggers/deploy",
+		// the rules restrict apps, so proxied accounts are looked up
+		"/project-abc/accounts/apn_1",
+		"/project-abc/accounts/apn_1",
+		"/project-abc/accounts/apn_1",
+	}, suite.calls[:5])
+	require.Len(suite.calls, 6)
+}
+
+func (suite *policyTestSuite) TestProxyFailsClosed() {
+	require := suite.Require()
+	c := suite.pipedreamClient
+	policy, err := ParsePolicy([]byte(`
+rules:
+  - name: internal-hosts
+    effect: deny
+    hosts: ["*.internal.example.com"]
+  - name: stripe-hosts
+    effect: deny
+    apps: [stripe]
+    hosts: ["*.stripe.com"]
+  - name: no-github
+    effect: deny
+    operations: [proxy]
+    apps: [github]
+`))
+	require.NoError(err)
+	c.Policy = policy
+
+	// relative URLs have no host to check, a rule restricting only hosts
+	// does not apply to them
+	_, err = c.Proxy(suite.ctx, ProxyRequest{ExternalUserID: "user-1", AccountID: "apn_3", Method: "GET", URL: "/api/chat.postMessage"})
+	require.NoError(err)
+
+	// but a rule matching the call by another matcher fails closed
+	_, err = c.Proxy(suite.ctx, ProxyRequest{ExternalUserID: "user-1", AccountID: "apn_2", Method: "GET", URL: "/v1/invoices"})
+	var denied *PolicyDeniedError
+	require.ErrorAs(err, &denied)
+	require.Equal("stripe-hosts", denied.Rule)
+	require.Equal("proxy GET (relative URL) for user user-1 denied by policy rule stripe-hosts", err.Error())
+	_, err = c.Proxy(suite.ctx, ProxyRequest{ExternalUserID: "user-1", AccountID: "apn_2", Method: "GET", URL: "https://example.com/v1/invoices"})
+	require.NoError(err)
+
+	// the app is the one of the proxied account
+	_, err = c.Proxy(suite.ctx, ProxyRequest{ExternalUserID: "user-1", AccountID: "apn_1", Method: "GET", URL: "https://api.example.com/user"})
+	require.ErrorAs(err, &denied)
+	require.Equal("no-github", denied.Rule)
+	require.Equal("github", denied.Request.App)
+
+	// and the call fails when the account cannot be found
+	_, err = c.Proxy(suite.ctx, ProxyRequest{ExternalUserID: "user-1", AccountID: "apn_missing", Method: "GET", URL: "https://api.example.com/user"})
+	require.ErrorContains(err, "looking up the app of account apn_missing for the policy")
+	require.False(errors.As(err, &denied))
+
+	// a host rule does not apply to actions, which have no host
+	_, err = c.InvokeAction(suite.ctx, "slack-send-message", "user-1", ConfiguredProps{}, "")
+	require.NoError(err)
+}
+
+func (suite *policyTestSuite) TestLoadPolicy() {
+	require := suite.Require()
+	dir := suite.T().TempDir()
+
+	file := filepath.Join(dir, "policy.json")
+	require.NoError(os.WriteFile(file, []byte(`{
+		"default": "deny",
+		"rules": [{"name": "slack", "effect": "allow", "apps": ["slack"]}]
+	}`), 0o600))
+	policy, err := LoadPolicy(file)
+	require.NoError(err)
+	require.Equal(PolicyDeny, policy.Default)
+	require.Equal([]PolicyRule{{Name: "slack", Effect: PolicyAllow, Apps: []string{"slack"}}}, policy.Rules)
+
+	_, err = ParsePolicy([]byte(`{"rules": [{"effect": "maybe", "components": ["[a-"], "operation": "proxy"}]}`))
+	require.ErrorContains(err, "field operation not found")
+
+	_, err = ParsePolicy([]byte(`{"rules": [{"effect": "maybe", "components": ["[a-"], "operations": ["run"]}]}`))
+	require.ErrorContains(err, `rule #0: unknown effect "maybe"`)
+	require.ErrorContains(err, `rule #0: unknown operation "run"`)
+	require.ErrorContains(err, `rule #0: invalid pattern "[a-"`)
+
+	_, err = LoadPolicy(filepath.Join(dir, "missing.yaml"))
+	require.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestPolicy(t *testing.T) {
+	suite.Run(t, new(policyTestSuite))
+}
@@ -0,0 +1,250 @@
+package connect
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PropOptionsCache caches remote prop options. Entries are keyed by component,
+// prop, external user and a hash of the other configured props, the query, the
+// page and the previous context. Concurrent identical lookups share one request.
+// Set it on Client.PropOptionsCache to enable it
+type PropOptionsCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*propCacheCall
+	// generation is bumped by every invalidation, fetches started before
+	// one do not cache their result
+	generation uint64
+}
+
+type propCacheEntry struct {
+	key            string
+	externalUserID string
+	accountIDs     []string
+	options        *PropOptions
+	expiresAt      time.Time
+}
+
+type propCacheCall struct {
+	done       chan struct{}
+	generation uint64
+	options    *PropOptions
+	err        error
+}
+
+// NewPropOptionsCache returns a cache keeping entries for ttl, at most maxEntries
+// of them. Zero values default to 5 minutes and 1000 entries
+func NewPropOptionsCache(ttl time.Duration, maxEntries int) *PropOptionsCache {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+
+	return &PropOptionsCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		inflight:   map[string]*propCacheCall{},
+	}
+}
+
+// Get returns the cached options for request or calls fetch, errors are not cached
+func (c *PropOptionsCache) Get(
+	ctx context.Context,
+	request ConfigurePropRequest,
+	fetch func(context.Context, ConfigurePropRequest) (*PropOptions, error),
+) (*PropOptions, error) {
+	key := propCacheKey(request)
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*propCacheEntry)
+		if c.now().Before(entry.expiresAt) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return clonePropOptions(entry.options), nil
+		}
+		c.remove(elem)
+	}
+
+	call, ok := c.inflight[key]
+	if !ok {
+		call = &propCacheCall{done: make(chan struct{}), generation: c.generation}
+		c.inflight[key] = call
+		// the fetch is shared, so it must not fail because the caller that
+		// started it gave up
+		go c.fetch(context.WithoutCancel(ctx), key, request, call, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return clonePropOptions(call.options), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *PropOptionsCache) fetch(
+	ctx context.Context,
+	key string,
+	request ConfigurePropRequest,
+	call *propCacheCall,
+	fetch func(context.Context, ConfigurePropRequest) (*PropOptions, error),
+) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.options, call.err = nil, fmt.Errorf("fetching prop options panicked: %v", r)
+		}
+
+		c.mu.Lock()
+		delete(c.inflight, key)
+		if call.err == nil && call.generation == c.generation {
+			c.add(&propCacheEntry{
+				key:            key,
+				externalUserID: request.ExternalUserID,
+				accountIDs:     authProvisionIDs(request.ConfiguredProps),
+				options:        call.options,
+				expiresAt:      c.now().Add(c.ttl),
+			})
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.options, call.err = fetch(ctx, request)
+}
+
+// InvalidateUser drops every entry of an external user and returns how many were removed
+func (c *PropOptionsCache) InvalidateUser(externalUserID string) int {
+	return c.removeWhere(func(e *propCacheEntry) bool {
+		return e.externalUserID == externalUserID
+	})
+}
+
+// InvalidateAccount drops every entry whose configured props reference the account
+func (c *PropOptionsCache) InvalidateAccount(accountID string) int {
+	return c.removeWhere(func(e *propCacheEntry) bool {
+		return slices.Contains(e.accountIDs, accountID)
+	})
+}
+
+// Purge drops all entries
+func (c *PropOptionsCache) Purge() {
+	c.removeWhere(func(*propCacheEntry) bool { return true })
+}
+
+func (c *PropOptionsCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *PropOptionsCache) add(entry *propCacheEntry) {
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *PropOptionsCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*propCacheEntry).key)
+}
+
+func (c *PropOptionsCache) removeWhere(match func(*propCacheEntry) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	removed := 0
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if match(elem.Value.(*propCacheEntry)) {
+			c.remove(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
+
+func propCacheKey(request ConfigurePropRequest) string {
+	// the prop being configured does not influence its own options
+	others := make(ConfiguredProps, len(request.ConfiguredProps))
+	for name, value := range request.ConfiguredProps {
+		if name != request.PropName {
+			others[name] = value
+		}
+	}
+
+	// encoding/json sorts map keys, which makes the encoding canonical
+	bs, _ := json.Marshal(struct {
+		Props          ConfiguredProps    `json:"p"`
+		Query          string             `json:"q"`
+		Page           int                `json:"n"`
+		PrevContext    PropOptionsContext `json:"c"`
+		DynamicPropsID string             `json:"d"`
+	}{others, request.Query, request.Page, request.PrevContext, request.DynamicPropsID})
+	sum := sha256.Sum256(bs)
+
+	return strings.Join([]string{
+		request.ComponentKey,
+		request.PropName,
+		request.ExternalUserID,
+		hex.EncodeToString(sum[:]),
+	}, "\x00")
+}
+
+// authProvisionIDs collects the account IDs referenced by app props
+func authProvisionIDs(props ConfiguredProps) []string {
+	var ids []string
+	for _, value := range props {
+		normalized, err := normalizeJSON(value)
+		if err != nil {
+			continue
+		}
+		if m, ok := normalized.(map[string]any); ok {
+			if id, ok := m["authProvisionId"].(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func clonePropOptions(p *PropOptions) *PropOptions {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	clone.Options = slices.Clone(p.Options)
+	clone.Observations = slices.Clone(p.Observations)
+	clone.StringOptions = slices.Clone(p.StringOptions)
+	return &clone
+}
@@ -0,0 +1,249 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type propCacheTestSuite struct {
+	suite.Suite
+	ctx   context.Context
+	now   time.Time
+	cache *PropOptionsCache
+	calls atomic.Int32
+}
+
+func (suite *propCacheTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	suite.cache = NewPropOptionsCache(time.Minute, 2)
+	suite.cache.now = func() time.Time { return suite.now }
+	suite.calls.Store(0)
+}
+
+func (suite *propCacheTestSuite) fetch(ctx context.Context, request ConfigurePropRequest) (*PropOptions, error) {
+	suite.calls.Add(1)
+	return &PropOptions{Options: []Value{{Label: request.PropName, Value: request.ExternalUserID}}}, nil
+}
+
+func (suite *propCacheTestSuite) request(user string, prop string, sheet string) ConfigurePropRequest {
+	return ConfigurePropRequest{
+		ExternalUserID: user,
+		ComponentKey:   "google_sheets-add-single-row",
+		PropName:       prop,
+		ConfiguredProps: ConfiguredProps{
+			"googleSheets": map[string]string{"authProvisionId": "apn_" + user},
+			"sheetId":      sheet,
+			prop:           "ignored for the key",
+		},
+	}
+}
+
+func (suite *propCacheTestSuite) TestGet_HitsAndExpires() {
+	require := suite.Require()
+
+	for range 3 {
+		options, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), suite.fetch)
+		require.NoError(err)
+		require.Equal("u1", options.Options[0].Value)
+	}
+	require.EqualValues(1, suite.calls.Load())
+
+	_, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s2"), suite.fetch)
+	require.NoError(err)
+	require.EqualValues(2, suite.calls.Load())
+
+	suite.now = suite.now.Add(2 * time.Minute)
+	_, err = suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), suite.fetch)
+	require.NoError(err)
+	require.EqualValues(3, suite.calls.Load())
+}
+
+func (suite *propCacheTestSuite) TestGet_EvictsLeastRecentlyUsed() {
+	require := suite.Require()
+
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), suite.fetch)
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u2", "worksheet", "s1"), suite.fetch)
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), suite.fetch)
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u3", "worksheet", "s1"), suite.fetch)
+	require.Equal(2, suite.cache.Len())
+	require.EqualValues(3, suite.calls.Load())
+
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), suite.fetch)
+	require.EqualValues(3, suite.calls.Load())
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u2", "worksheet", "s1"), suite.fetch)
+	require.EqualValues(4, suite.calls.Load())
+}
+
+func (suite *propCacheTestSuite) TestInvalidate() {
+	require := suite.Require()
+	suite.cache = NewPropOptionsCache(time.Minute, 10)
+
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), suite.fetch)
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u1", "column", "s1"), suite.fetch)
+	_, _ = suite.cache.Get(suite.ctx, suite.request("u2", "worksheet", "s1"), suite.fetch)
+
+	require.Equal(2, suite.cache.InvalidateUser("u1"))
+	require.Equal(1, suite.cache.InvalidateAccount("apn_u2"))
+	require.Equal(0, suite.cache.Len())
+}
+
+func (suite *propCacheTestSuite) TestGet_SharesConcurrentLookups() {
+	require := suite.Require()
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, request ConfigurePropRequest) (*PropOptions, error) {
+		suite.calls.Add(1)
+		<-release
+		return &PropOptions{}, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), fetch)
+			require.NoError(err)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.EqualValues(1, suite.calls.Load())
+}
+
+func (suite *propCacheTestSuite) TestGet_LeaderCancellation() {
+	require := suite.Require()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, request ConfigurePropRequest) (*PropOptions, error) {
+		suite.calls.Add(1)
+		close(started)
+		<-release
+		return &PropOptions{Errors: []string{}}, ctx.Err()
+	}
+
+	leaderCtx, cancel := context.WithCancel(suite.ctx)
+	leaderErr := make(chan error)
+	go func() {
+		_, err := suite.cache.Get(leaderCtx, suite.request("u1", "worksheet", "s1"), fetch)
+		leaderErr <- err
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		_, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), fetch)
+		waiter <- err
+	}()
+
+	cancel()
+	require.ErrorIs(<-leaderErr, context.Canceled)
+	close(release)
+	require.NoError(<-waiter)
+	require.EqualValues(1, suite.calls.Load())
+}
+
+func (suite *propCacheTestSuite) TestGet_InvalidatedDuringFetch() {
+	require := suite.Require()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, request ConfigurePropRequest) (*PropOptions, error) {
+		suite.calls.Add(1)
+		close(started)
+		<-release
+		return &PropOptions{}, nil
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), fetch)
+		done <- err
+	}()
+	<-started
+	suite.cache.InvalidateUser("u1")
+	close(release)
+	require.NoError(<-done)
+
+	// options fetched before the invalidation are not cached
+	require.Zero(suite.cache.Len())
+	_, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), suite.fetch)
+	require.NoError(err)
+	require.EqualValues(2, suite.calls.Load())
+}
+
+func (suite *propCacheTestSuite) TestGet_FetchPanics() {
+	require := suite.Require()
+	panics := func(ctx context.Context, request ConfigurePropRequest) (*PropOptions, error) {
+		suite.calls.Add(1)
+		panic("boom")
+	}
+
+	_, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), panics)
+	require.ErrorContains(err, "panicked: boom")
+
+	options, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), suite.fetch)
+	require.NoError(err)
+	require.NotNil(options)
+	require.EqualValues(2, suite.calls.Load())
+}
+
+func (suite *propCacheTestSuite) TestGet_DoesNotCacheErrors() {
+	require := suite.Require()
+	fail := func(ctx context.Context, request ConfigurePropRequest) (*PropOptions, error) {
+		suite.calls.Add(1)
+		return nil, errors.New("upstream down")
+	}
+
+	_, err := suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), fail)
+	require.Error(err)
+	_, err = suite.cache.Get(suite.ctx, suite.request("u1", "worksheet", "s1"), fail)
+	require.Error(err)
+	require.EqualValues(2, suite.calls.Load())
+}
+
+func (suite *propCacheTestSuite) TestClient_UsesCache() {
+	require := suite.Require()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+		case r.URL.Path == "/project-abc/components/configure":
+			suite.calls.Add(1)
+			_, _ = fmt.Fprint(w, `{"options": ["Sheet1"]}`)
+		}
+	}))
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	pipedreamClient := &Client{Client: base, PropOptionsCache: NewPropOptionsCache(0, 0)}
+
+	for range 2 {
+		options, err := pipedreamClient.GetPropOptions(suite.ctx,
+			"worksheet", "google_sheets-add-single-row", "u1", ConfiguredProps{"sheetId": "s1"})
+		require.NoError(err)
+		require.Equal([]Value{{Label: "Sheet1", Value: "Sheet1"}}, options.Options)
+	}
+	require.EqualValues(1, suite.calls.Load())
+}
+
+func TestPropCache(t *testing.T) {
+	suite.Run(t, new(propCacheTestSuite))
+}
@@ -0,0 +1,342 @@
+package connect
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// PropType is the type of a ConfigurableProp
+// https://pipedream.com/docs/components/contributing/api/#props
+type PropType string
+
+const (
+	PropTypeString       PropType = "string"
+	PropTypeStringArray  PropType = "string[]"
+	PropTypeInteger      PropType = "integer"
+	PropTypeIntegerArray PropType = "integer[]"
+	PropTypeBoolean      PropType = "boolean"
+	PropTypeObject       PropType = "object"
+	PropTypeAny          PropType = "any"
+	PropTypeApp          PropType = "app"
+	PropTypeAlert        PropType = "alert"
+	PropTypeSQL          PropType = "sql"
+	PropTypeDir          PropType = "dir"
+	PropTypeHTTPRequest  PropType = "http_request"
+	PropTypeDataStore    PropType = "data_store"
+	PropTypeTimer        PropType = "$.interface.timer"
+	PropTypeHTTP         PropType = "$.interface.http"
+	PropTypeAppHook      PropType = "$.interface.apphook"
+	PropTypeDB           PropType = "$.service.db"
+)
+
+var knownPropTypes = map[PropType]struct{}{
+	PropTypeString: {}, PropTypeStringArray: {}, PropTypeInteger: {}, PropTypeIntegerArray: {},
+	PropTypeBoolean: {}, PropTypeObject: {}, PropTypeAny: {}, PropTypeApp: {}, PropTypeAlert: {},
+	PropTypeSQL: {}, PropTypeDir: {}, PropTypeHTTPRequest: {}, PropTypeDataStore: {},
+	PropTypeTimer: {}, PropTypeHTTP: {}, PropTypeAppHook: {}, PropTypeDB: {},
+}
+
+// Known reports whether t is one of the prop types this package knows about
+func (t PropType) Known() bool {
+	_, ok := knownPropTypes[t]
+	return ok
+}
+
+func (t PropType) IsArray() bool {
+	return strings.HasSuffix(string(t), "[]")
+}
+
+// ElemType returns the element type of array types and t itself otherwise
+func (t PropType) ElemType() PropType {
+	return PropType(strings.TrimSuffix(string(t), "[]"))
+}
+
+// IsInterface reports whether the prop is an interface or service
+// ($.interface.timer, $.interface.http, $.service.db ...)
+func (t PropType) IsInterface() bool {
+	return strings.HasPrefix(string(t), "$.")
+}
+
+// UserConfigured reports whether end users are expected to supply a value for
+// the prop. HTTP interfaces, databases and alerts are provided by Pipedream
+func (t PropType) UserConfigured() bool {
+	switch t {
+	case PropTypeHTTP, PropTypeDB, PropTypeAppHook, PropTypeAlert, PropTypeDir:
+		return false
+	}
+	return true
+}
+
+// Required reports whether the prop must be present in ConfiguredProps
+func (p *ConfigurableProp) Required() bool {
+	return !p.Optional && !p.Disabled && p.Default == nil && p.Type.UserConfigured()
+}
+
+// PropError is a validation failure for a single prop
+type PropError struct {
+	Prop    string `json:"prop"`
+	Message string `json:"message"`
+}
+
+func (e PropError) Error() string {
+	return fmt.Sprintf("prop %s: %s", e.Prop, e.Message)
+}
+
+var InvalidPropsErr error = errors.New("invalid configured props")
+
+// PropValidationError lists every prop that failed validation
+type PropValidationError struct {
+	Errors []PropError `json:"errors"`
+}
+
+func (e *PropValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		msgs = append(msgs, fieldErr.Error())
+	}
+	return fmt.Sprintf("%s: %s", InvalidPropsErr, strings.Join(msgs, "; "))
+}
+
+func (e *PropValidationError) Is(target error) bool {
+	return target == InvalidPropsErr
+}
+
+// Field returns the validation error of a single prop
+func (e *PropValidationError) Field(prop string) (PropError, bool) {
+	for _, fieldErr := range e.Errors {
+		if fieldErr.Prop == prop {
+			return fieldErr, true
+		}
+	}
+	return PropError{}, false
+}
+
+// Validate checks props against the configurable props of the component without
+// calling the API: required props must be set, values must match the prop type,
+// integers must lie within Min and Max and unknown props are rejected.
+// It returns a *PropValidationError or nil
+func (c ComponentDetails) Validate(props ConfiguredProps) error {
+	return validateProps(c.ConfigurableProps, props, false)
+}
+
+// validateProps with partial set only checks the props present in props, used
+// when the component was reloaded with dynamic props we don't have locally
+func validateProps(definitions []*ConfigurableProp, props ConfiguredProps, partial bool) error {
+	var errs []PropError
+	known := make(map[string]struct{}, len(definitions))
+
+	for _, def := range definitions {
+		if def == nil {
+			continue
+		}
+		known[def.Name] = struct{}{}
+
+		value, ok := props[def.Name]
+		if !ok || value == nil {
+			if !partial && def.Required() {
+				errs = append(errs, PropError{Prop: def.Name, Message: "is required"})
+			}
+			continue
+		}
+
+		if msg := checkPropValue(def, value); msg != "" {
+			errs = append(errs, PropError{Prop: def.Name, Message: msg})
+		}
+	}
+
+	if !partial {
+		for name := range props {
+			if _, ok := known[name]; !ok {
+				errs = append(errs, PropError{Prop: name, Message: "is not a prop of the component"})
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sortPropErrors(definitions, errs)
+	return &PropValidationError{Errors: errs}
+}
+
+// sortPropErrors orders errors like the component declares its props, unknown props last
+func sortPropErrors(definitions []*ConfigurableProp, errs []PropError) {
+	order := map[string]int{}
+	for i, def := range definitions {
+		if def != nil {
+			order[def.Name] = i
+		}
+	}
+	rank := func(e PropError) int {
+		if i, ok := order[e.Prop]; ok {
+			return i
+		}
+		return len(definitions)
+	}
+
+	slices.SortStableFunc(errs, func(a, b PropError) int {
+		return cmp.Or(cmp.Compare(rank(a), rank(b)), cmp.Compare(a.Prop, b.Prop))
+	})
+}
+
+func checkPropValue(def *ConfigurableProp, value any) string {
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return fmt.Sprintf("cannot be encoded as JSON: %v", err)
+	}
+
+	// labeled values selected from options are sent as {"__lv": {"label", "value"}}
+	if m, ok := normalized.(map[string]any); ok && def.Type != PropTypeObject {
+		if _, ok := m["__lv"]; ok {
+			return ""
+		}
+	}
+
+	if def.Type.IsArray() {
+		items, ok := normalized.([]any)
+		if !ok {
+			return fmt.Sprintf("expected %s, got %s", def.Type, jsonKind(normalized))
+		}
+		for i, item := range items {
+			if msg := checkScalar(def, def.Type.ElemType(), item); msg != "" {
+				return fmt.Sprintf("item %d: %s", i, msg)
+			}
+		}
+		return ""
+	}
+
+	return checkScalar(def, def.Type, normalized)
+}
+
+func checkScalar(def *ConfigurableProp, t PropType, value any) string {
+	switch t {
+	case PropTypeString:
+		if _, ok := value.(string); !ok {
+			return fmt.Sprintf("expected %s, got %s", t, jsonKind(value))
+		}
+	case PropTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Sprintf("expected %s, got %s", t, jsonKind(value))
+		}
+	case PropTypeInteger:
+		n, ok := value.(json.Number)
+		if !ok {
+			return fmt.Sprintf("expected %s, got %s", t, jsonKind(value))
+		}
+		if !isInteger(n) {
+			return fmt.Sprintf("expected %s, got %s", t, n)
+		}
+		if def.Min != nil && compareNumber(n, *def.Min) < 0 {
+			return fmt.Sprintf("%s is less than the minimum %d", n, *def.Min)
+		}
+		if def.Max != nil && compareNumber(n, *def.Max) > 0 {
+			return fmt.Sprintf("%s is greater than the maximum %d", n, *def.Max)
+		}
+	case PropTypeObject:
+		if _, ok := value.(map[string]any); !ok {
+			return fmt.Sprintf("expected %s, got %s", t, jsonKind(value))
+		}
+	case PropTypeApp:
+		m, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Sprintf(`expected {"authProvisionId": "apn_..."}, got %s`, jsonKind(value))
+		}
+		if id, _ := m["authProvisionId"].(string); id == "" {
+			return "authProvisionId is required"
+		}
+	case PropTypeTimer:
+		m, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Sprintf(`expected {"intervalSeconds": n} or {"cron": "..."}, got %s`, jsonKind(value))
+		}
+		_, hasInterval := m["intervalSeconds"].(json.Number)
+		_, hasCron := m["cron"].(string)
+		if !hasInterval && !hasCron {
+			return "intervalSeconds or cron is required"
+		}
+	}
+
+	return ""
+}
+
+// normalizeJSON converts v into the generic value encoding/json would decode it
+// to, keeping numbers as json.Number
+func normalizeJSON(v any) (any, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// validateComponentProps fetches the component and validates props against it,
+// used by InvokeAction and DeployTrigger when Client.ValidateProps is set
+func (c *Client) validateComponentProps(
+	ctx context.Context,
+	componentType ComponentType,
+	componentKey string,
+	props ConfiguredProps,
+	dynamic bool,
+) error {
+	component, err := c.GetComponent(ctx, componentKey, componentType)
+	if err != nil {
+		return fmt.Errorf("fetching component %s for validation: %w", componentKey, err)
+	}
+	if component.Data == nil {
+		return fmt.Errorf("component %s not found: %w", componentKey, NotFoundErr)
+	}
+
+	return validateProps(component.Data.ConfigurableProps, props, dynamic)
+}
+
+// isInteger reports whether n is a whole number. Values within int64 are parsed
+// exactly, only larger ones go through float64
+func isInteger(n json.Number) bool {
+	if _, err := n.Int64(); err == nil {
+		return true
+	}
+	f, err := n.Float64()
+	return err == nil && f == math.Trunc(f)
+}
+
+// compareNumber compares n with bound, exactly if n is within int64
+func compareNumber(n json.Number, bound int) int {
+	if i, err := n.Int64(); err == nil {
+		return cmp.Compare(i, int64(bound))
+	}
+	f, _ := n.Float64()
+	return cmp.Compare(f, float64(bound))
+}
@@ -0,0 +1,186 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type propsTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+	component       ComponentDetails
+}
+
+func (suite *propsTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	one, hundred := 1, 100
+	suite.component = ComponentDetails{
+		Component: Component{Key: "gitlab-list-commits", Type: Actions},
+		ConfigurableProps: []*ConfigurableProp{
+			{Name: "gitlab", Type: PropTypeApp, App: "gitlab"},
+			{Name: "projectId", Type: PropTypeInteger, Min: &one},
+			{Name: "refName", Type: PropTypeString},
+			{Name: "issueIds", Type: PropTypeIntegerArray, Min: &one, Max: &hundred, Optional: true},
+			{Name: "draft", Type: PropTypeBoolean, Optional: true},
+			{Name: "http", Type: PropTypeHTTP},
+			{Name: "db", Type: PropTypeDB},
+			{Name: "timer", Type: PropTypeTimer, Default: map[string]any{"intervalSeconds": 900}},
+		},
+	}
+}
+
+func (suite *propsTestSuite) TestPropType() {
+	require := suite.Require()
+
+	require.True(PropTypeIntegerArray.IsArray())
+	require.Equal(PropTypeInteger, PropTypeIntegerArray.ElemType())
+	require.True(PropTypeDB.IsInterface())
+	require.False(PropTypeHTTP.UserConfigured())
+	require.True(PropTypeTimer.UserConfigured())
+	require.True(PropType("$.interface.timer").Known())
+	require.False(PropType("carrier_pigeon").Known())
+}
+
+func (suite *propsTestSuite) TestValidate_Valid() {
+	require := suite.Require()
+
+	err := suite.component.Validate(ConfiguredProps{
+		"gitlab":    map[string]string{"authProvisionId": "apn_kVh9AoD"},
+		"projectId": 45672541,
+		"refName":   "main",
+		"issueIds":  []int{1, 2, 3},
+		"draft":     false,
+		"timer":     map[string]any{"cron": "0 9 * * 1-5"},
+	})
+	require.NoError(err)
+}
+
+func (suite *propsTestSuite) TestValidate_FieldErrors() {
+	require := suite.Require()
+
+	err := suite.component.Validate(ConfiguredProps{
+		"gitlab":    map[string]string{"authProvisionId": ""},
+		"projectId": 0.5,
+		"issueIds":  "1,2,3",
+		"draft":     "yes",
+		"timer":     map[string]any{"every": "day"},
+		"projectID": 1,
+	})
+	require.True(errors.Is(err, InvalidPropsErr))
+
+	var validationErr *PropValidationError
+	require.True(errors.As(err, &validationErr))
+	require.Equal([]PropError{
+		{Prop: "gitlab", Message: "authProvisionId is required"},
+		{Prop: "projectId", Message: "expected integer, got 0.5"},
+		{Prop: "refName", Message: "is required"},
+		{Prop: "issueIds", Message: "expected integer[], got string"},
+		{Prop: "draft", Message: "expected boolean, got string"},
+		{Prop: "timer", Message: "intervalSeconds or cron is required"},
+		{Prop: "projectID", Message: "is not a prop of the component"},
+	}, validationErr.Errors)
+}
+
+func (suite *propsTestSuite) TestValidate_Range() {
+	require := suite.Require()
+
+	err := suite.component.Validate(ConfiguredProps{
+		"gitlab":    map[string]any{"authProvisionId": "apn_kVh9AoD"},
+		"projectId": 1,
+		"refName":   "main",
+		"issueIds":  []any{5, 101},
+	})
+
+	var validationErr *PropValidationError
+	require.True(errors.As(err, &validationErr))
+	fieldErr, ok := validationErr.Field("issueIds")
+	require.True(ok)
+	require.Equal("item 1: 101 is greater than the maximum 100", fieldErr.Message)
+
+	// zero bounds are enforced too
+	zero := 0
+	component := ComponentDetails{ConfigurableProps: []*ConfigurableProp{
+		{Name: "offset", Type: PropTypeInteger, Min: &zero},
+		{Name: "delta", Type: PropTypeInteger, Max: &zero},
+	}}
+	err = component.Validate(ConfiguredProps{"offset": -1, "delta": 1})
+	require.True(errors.As(err, &validationErr))
+	fieldErr, ok = validationErr.Field("offset")
+	require.True(ok)
+	require.Equal("-1 is less than the minimum 0", fieldErr.Message)
+	fieldErr, ok = validationErr.Field("delta")
+	require.True(ok)
+	require.Equal("1 is greater than the maximum 0", fieldErr.Message)
+	require.NoError(component.Validate(ConfiguredProps{"offset": 0, "delta": 0}))
+
+	// integers beyond 2^53 are compared exactly
+	limit := 1 << 53
+	component = ComponentDetails{ConfigurableProps: []*ConfigurableProp{
+		{Name: "id", Type: PropTypeInteger, Max: &limit},
+	}}
+	require.NoError(component.Validate(ConfiguredProps{"id": int64(limit)}))
+	require.NoError(component.JSONSchema().Validate(ConfiguredProps{"id": int64(limit)}))
+	err = component.Validate(ConfiguredProps{"id": int64(limit + 1)})
+	require.ErrorContains(err, "9007199254740993 is greater than the maximum 9007199254740992")
+	err = component.JSONSchema().Validate(ConfiguredProps{"id": int64(limit + 1)})
+	require.ErrorContains(err, "9007199254740993 is greater than the maximum 9007199254740992")
+}
+
+func (suite *propsTestSuite) TestInvokeAction_ValidatesBeforeRunning() {
+	require := suite.Require()
+	ran := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/project-abc/actions/gitlab-list-commits":
+			_, _ = fmt.Fprint(w, `{
+				"data": {
+					"key": "gitlab-list-commits",
+					"configurable_props": [
+						{"name": "gitlab", "type": "app", "app": "gitlab"},
+						{"name": "projectId", "type": "integer"}
+					]
+				}
+			}`)
+		case r.URL.Path == "/project-abc/actions/run":
+			ran = true
+			_, _ = fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base, ValidateProps: true}
+
+	_, err := suite.pipedreamClient.InvokeAction(suite.ctx, "gitlab-list-commits", "jverce",
+		ConfiguredProps{"projectId": "45672541"}, "")
+	require.True(errors.Is(err, InvalidPropsErr))
+	require.False(ran)
+
+	_, err = suite.pipedreamClient.InvokeAction(suite.ctx, "gitlab-list-commits", "jverce",
+		ConfiguredProps{
+			"gitlab":    map[string]string{"authProvisionId": "apn_kVh9AoD"},
+			"projectId": 45672541,
+		}, "")
+	require.NoError(err)
+	require.True(ran)
+}
+
+func TestProps(t *testing.T) {
+	suite.Run(t, new(propsTestSuite))
+}
@@ -38,6 +38,13 @@ func (c *Client) Proxy(
 	if err := pr.Validate(); err != nil {
 		return nil, fmt.Errorf("proxy validation: %w", err)
 	}
+	policyReq, err := c.proxyPolicyRequest(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkPolicy(ctx, policyReq); err != nil {
+		return nil, err
+	}
 	encoded := base64.RawURLEncoding.EncodeToString([]byte(pr.URL))
 	proxyURL := c.ConnectURL().ResolveReference(&url.URL{
 		Path: path.Join(
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type cronTestSuite struct {
+	suite.Suite
+}
+
+func (suite *cronTestSuite) next(expr string, from time.Time, n int) []time.Time {
+	cron, err := ParseCron(expr)
+	suite.Require().NoError(err)
+
+	var out []time.Time
+	for range n {
+		from = cron.Next(from)
+		out = append(out, from)
+	}
+	return out
+}
+
+func (suite *cronTestSuite) TestNext() {
+	require := suite.Require()
+	utc := func(s string) time.Time {
+		t, err := time.Parse(time.DateTime, s)
+		require.NoError(err)
+		return t
+	}
+	// a Friday
+	from := utc("2025-03-07 08:59:30")
+
+	require.Equal([]time.Time{
+		utc("2025-03-07 09:00:00"),
+		utc("2025-03-10 09:00:00"),
+		utc("2025-03-11 09:00:00"),
+	}, suite.next("0 9 * * mon-fri", from, 3))
+
+	require.Equal([]time.Time{
+		utc("2025-03-07 09:00:00"),
+		utc("2025-03-07 09:15:00"),
+		utc("2025-03-07 09:30:00"),
+	}, suite.next("*/15 * * * *", from, 3))
+
+	require.Equal([]time.Time{
+		utc("2025-03-07 09:05:00"),
+		utc("2025-03-07 09:35:00"),
+		utc("2025-03-07 10:05:00"),
+	}, suite.next("5/30 9-10 * * *", from, 3))
+
+	require.Equal([]time.Time{
+		utc("2025-04-01 00:00:00"),
+		utc("2025-07-01 00:00:00"),
+	}, suite.next("0 0 1 jan,apr,jul,oct *", from, 2))
+
+	// day of month or day of week when both are restricted
+	require.Equal([]time.Time{
+		utc("2025-03-09 12:00:00"),
+		utc("2025-03-13 12:00:00"),
+		utc("2025-03-16 12:00:00"),
+	}, suite.next("0 12 13 * 7", from, 3))
+
+	require.Equal([]time.Time{utc("2025-03-08 00:00:00")}, suite.next("@daily", from, 1))
+	require.Equal([]time.Time{utc("2028-02-29 00:00:00")}, suite.next("0 0 29 2 *", from, 1))
+	require.Equal([]time.Time{{}}, suite.next("0 0 30 2 *", from, 1))
+}
+
+func (suite *cronTestSuite) TestNextInTimezone() {
+	require := suite.Require()
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(err)
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(err)
+
+	// 2:30 does not exist when clocks go forward on 2025-03-30
+	from := time.Date(2025, 3, 29, 12, 0, 0, 0, berlin)
+	require.Equal([]time.Time{
+		time.Date(2025, 3, 31, 2, 30, 0, 0, berlin),
+	}, suite.next("30 2 * * *", from, 1))
+
+	require.Equal([]time.Time{
+		time.Date(2025, 3, 30, 1, 0, 0, 0, berlin),
+		time.Date(2025, 3, 30, 3, 0, 0, 0, berlin),
+	}, suite.next("0 * * * *", time.Date(2025, 3, 30, 0, 30, 0, 0, berlin), 2))
+
+	// and 2:30 happens twice when they fall back on 2025-10-26
+	twice := suite.next("30 2 * * *", time.Date(2025, 10, 26, 0, 0, 0, 0, berlin), 2)
+	require.Equal(time.Hour, twice[1].Sub(twice[0]))
+
+	// half hour offsets keep local hours
+	require.Equal([]time.Time{
+		time.Date(2025, 3, 7, 10, 0, 0, 0, kolkata),
+	}, suite.next("0 10 * * *", time.Date(2025, 3, 7, 9, 10, 0, 0, kolkata), 1))
+}
+
+func (suite *cronTestSuite) TestParseErrors() {
+	require := suite.Require()
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+	} {
+		_, err := ParseCron(expr)
+		require.Error(err, expr)
+	}
+
+	cron, err := ParseCron("0 9 * * MON-FRI")
+	require.NoError(err)
+	require.Equal("0 9 * * MON-FRI", cron.String())
+}
+
+func TestCron(t *testing.T) {
+	suite.Run(t, new(cronTestSuite))
+}
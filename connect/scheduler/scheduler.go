@@ -0,0 +1,507 @@
+// Package scheduler runs Connect actions on cron schedules inside the process,
+// without deploying a Pipedream workflow per end user.
+//
+//	s := scheduler.New(client, scheduler.Options{Store: store})
+//	_, err := s.Add(ctx, scheduler.Schedule{
+//		Cron:           "0 9 * * mon-fri",
+//		Timezone:       "Europe/Berlin",
+//		ComponentKey:   "slack-send-message",
+//		ExternalUserID: "jverce",
+//		Props:          props,
+//	})
+//	err = s.Run(ctx)
+package scheduler
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+)
+
+// CatchUp decides what happens to runs missed while no replica was running
+type CatchUp string
+
+const (
+	// CatchUpLatest runs once for the latest missed time and skips the others
+	CatchUpLatest CatchUp = "latest"
+	// CatchUpAll runs every missed time in order, up to Options.MaxCatchUp
+	CatchUpAll CatchUp = "all"
+	// CatchUpNone skips missed times older than Options.Grace
+	CatchUpNone CatchUp = "none"
+)
+
+type Schedule struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Cron is a five field cron expression, see ParseCron
+	Cron string `json:"cron"`
+	// Timezone is an IANA time zone name, defaults to UTC
+	Timezone       string                  `json:"timezone,omitempty"`
+	ComponentKey   string                  `json:"component_key"`
+	ExternalUserID string                  `json:"external_user_id"`
+	Props          connect.ConfiguredProps `json:"configured_props"`
+	// CatchUp defaults to CatchUpLatest
+	CatchUp   CatchUp   `json:"catch_up,omitempty"`
+	Paused    bool      `json:"paused,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// NextRunAt is the next time the schedule is due, zero when paused or if the
+	// expression never matches again
+	NextRunAt time.Time `json:"next_run_at,omitzero"`
+	LastRunAt time.Time `json:"last_run_at,omitzero"`
+}
+
+// clone deep copies the schedule, the props through JSON with numbers kept as
+// json.Number
+func (s *Schedule) clone() (*Schedule, error) {
+	out := *s
+	if s.Props != nil {
+		bs, err := json.Marshal(s.Props)
+		if err != nil {
+			return nil, fmt.Errorf("encoding props of schedule %s: %w", s.ID, err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(bs))
+		dec.UseNumber()
+		out.Props = nil
+		if err := dec.Decode(&out.Props); err != nil {
+			return nil, fmt.Errorf("decoding props of schedule %s: %w", s.ID, err)
+		}
+	}
+	return &out, nil
+}
+
+// next returns the first time after t the schedule is due
+func (s *Schedule) next(t time.Time) (time.Time, error) {
+	tt, err := s.timetable()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return tt.next(t), nil
+}
+
+// timetable parses the cron expression and time zone of the schedule
+func (s *Schedule) timetable() (*timetable, error) {
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(cmp.Or(s.Timezone, "UTC"))
+	if err != nil {
+		return nil, fmt.Errorf("timezone %q: %w", s.Timezone, err)
+	}
+	return &timetable{cron: cron, loc: loc}, nil
+}
+
+type timetable struct {
+	cron *Cron
+	loc  *time.Location
+}
+
+// next returns the first time after t the cron expression matches in the time
+// zone, zero if it never matches again
+func (tt *timetable) next(t time.Time) time.Time {
+	next := tt.cron.Next(t.In(tt.loc))
+	if next.IsZero() {
+		return next
+	}
+	return next.UTC()
+}
+
+type RunStatus string
+
+const (
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	// RunSkipped runs were missed and not caught up
+	RunSkipped RunStatus = "skipped"
+)
+
+// Run is an entry of the run history of a schedule
+type Run struct {
+	ScheduleID  string    `json:"schedule_id"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	StartedAt   time.Time `json:"started_at,omitzero"`
+	FinishedAt  time.Time `json:"finished_at,omitzero"`
+	Status      RunStatus `json:"status"`
+	// CatchUp is set for runs of missed times
+	CatchUp bool   `json:"catch_up,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Invoker runs actions, *connect.Client implements it
+type Invoker interface {
+	InvokeAction(
+		ctx context.Context,
+		componentKey string,
+		externalUserID string,
+		props connect.ConfiguredProps,
+		dynamicPropsID string,
+		opts ...connect.InvokeActionOption,
+	) (*connect.ActionRunResult, error)
+}
+
+// LeaderLock elects the replica running due schedules. TryAcquire acquires or
+// renews the lock for ttl and reports whether this replica holds it
+type LeaderLock interface {
+	TryAcquire(ctx context.Context, ttl time.Duration) (bool, error)
+	Release(ctx context.Context) error
+}
+
+// LocalLock is always held, for deployments with a single replica
+type LocalLock struct{}
+
+func (LocalLock) TryAcquire(context.Context, time.Duration) (bool, error) { return true, nil }
+
+func (LocalLock) Release(context.Context) error { return nil }
+
+type Options struct {
+	// Store defaults to a MemoryStore
+	Store Store
+	// Lock defaults to LocalLock
+	Lock LeaderLock
+	// Now defaults to time.Now
+	Now func() time.Time
+	// Interval is how often due schedules are checked, defaults to 30 seconds
+	Interval time.Duration
+	// LockTTL defaults to three intervals
+	LockTTL time.Duration
+	// MaxCatchUp limits the missed runs of CatchUpAll, defaults to 10
+	MaxCatchUp int
+	// Grace is how late a run may start and still count as on time, defaults to
+	// two intervals
+	Grace time.Duration
+	// Logger defaults to slog.Default
+	Logger *slog.Logger
+}
+
+type Scheduler struct {
+	invoker Invoker
+	opts    Options
+}
+
+func New(invoker Invoker, opts Options) *Scheduler {
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	if opts.Lock == nil {
+		opts.Lock = LocalLock{}
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = 3 * opts.Interval
+	}
+	if opts.MaxCatchUp <= 0 {
+		opts.MaxCatchUp = 10
+	}
+	if opts.Grace <= 0 {
+		opts.Grace = 2 * opts.Interval
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Scheduler{invoker: invoker, opts: opts}
+}
+
+func (s *Scheduler) now() time.Time {
+	return s.opts.Now().UTC()
+}
+
+// Add validates and stores a new schedule, its ID and next run are set
+func (s *Scheduler) Add(ctx context.Context, schedule Schedule) (*Schedule, error) {
+	if schedule.ComponentKey == "" || schedule.ExternalUserID == "" {
+		return nil, errors.New("component key and external user ID are required")
+	}
+	switch schedule.CatchUp {
+	case "", CatchUpLatest, CatchUpAll, CatchUpNone:
+	default:
+		return nil, fmt.Errorf("unknown catch up %q", schedule.CatchUp)
+	}
+
+	if schedule.ID == "" {
+		id, err := newScheduleID()
+		if err != nil {
+			return nil, err
+		}
+		schedule.ID = id
+	}
+	now := s.now()
+	schedule.CreatedAt = now
+	schedule.LastRunAt = time.Time{}
+	schedule.NextRunAt = time.Time{}
+	if !schedule.Paused {
+		next, err := schedule.next(now)
+		if err != nil {
+			return nil, err
+		}
+		schedule.NextRunAt = next
+	} else if _, err := schedule.next(now); err != nil {
+		return nil, err
+	}
+
+	if err := s.opts.Store.SaveSchedule(ctx, &schedule); err != nil {
+		return nil, fmt.Errorf("saving schedule: %w", err)
+	}
+	return &schedule, nil
+}
+
+func (s *Scheduler) Get(ctx context.Context, id string) (*Schedule, error) {
+	return s.opts.Store.GetSchedule(ctx, id)
+}
+
+func (s *Scheduler) List(ctx context.Context) ([]*Schedule, error) {
+	return s.opts.Store.ListSchedules(ctx)
+}
+
+func (s *Scheduler) Remove(ctx context.Context, id string) error {
+	return s.opts.Store.DeleteSchedule(ctx, id)
+}
+
+// Pause stops runs until Resume, runs due meanwhile are not caught up
+func (s *Scheduler) Pause(ctx context.Context, id string) (*Schedule, error) {
+	schedule, err := s.opts.Store.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Paused = true
+	schedule.NextRunAt = time.Time{}
+	if err := s.opts.Store.SaveSchedule(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("saving schedule %s: %w", id, err)
+	}
+	return schedule, nil
+}
+
+func (s *Scheduler) Resume(ctx context.Context, id string) (*Schedule, error) {
+	schedule, err := s.opts.Store.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	next, err := schedule.next(s.now())
+	if err != nil {
+		return nil, err
+	}
+	schedule.Paused = false
+	schedule.NextRunAt = next
+	if err := s.opts.Store.SaveSchedule(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("saving schedule %s: %w", id, err)
+	}
+	return schedule, nil
+}
+
+// Runs returns the run history of a schedule, latest first
+func (s *Scheduler) Runs(ctx context.Context, id string, limit int) ([]Run, error) {
+	return s.opts.Store.ListRuns(ctx, id, limit)
+}
+
+// Run checks for due schedules every interval until ctx is done, on the replica
+// holding the leader lock
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	defer func() {
+		// ctx is done, the lock is released on a fresh one
+		release, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := s.opts.Lock.Release(release); err != nil {
+			s.opts.Logger.Warn("releasing scheduler lock", "error", err)
+		}
+	}()
+
+	for {
+		if _, err := s.Tick(ctx); err != nil && ctx.Err() == nil {
+			s.opts.Logger.Error("running due schedules", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick runs the schedules due now if this replica holds the leader lock and
+// returns the recorded runs. Run calls it every interval, tests call it directly.
+// The lock is renewed before every due schedule, a replica that lost it stops
+func (s *Scheduler) Tick(ctx context.Context) ([]Run, error) {
+	leader, err := s.opts.Lock.TryAcquire(ctx, s.opts.LockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring scheduler lock: %w", err)
+	}
+	if !leader {
+		return nil, nil
+	}
+
+	schedules, err := s.opts.Store.ListSchedules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing schedules: %w", err)
+	}
+
+	var runs []Run
+	var errs []error
+	renew := false
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			break
+		}
+		if !schedule.due(s.now()) {
+			continue
+		}
+		// the runs of the previous schedules may have outlasted the lock
+		if renew {
+			leader, err := s.opts.Lock.TryAcquire(ctx, s.opts.LockTTL)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("renewing scheduler lock: %w", err))
+				break
+			}
+			if !leader {
+				break
+			}
+		}
+		renew = true
+
+		scheduleRuns, err := s.runDue(ctx, schedule.ID)
+		runs = append(runs, scheduleRuns...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", schedule.ID, err))
+		}
+	}
+	return runs, errors.Join(errs...)
+}
+
+func (s *Schedule) due(now time.Time) bool {
+	return !s.Paused && !s.NextRunAt.IsZero() && !s.NextRunAt.After(now)
+}
+
+// runDue runs a schedule if it is still due. The next run is claimed in the
+// store before the action is invoked, so a crash of the replica skips a run
+// rather than repeating it and another replica never runs it again
+func (s *Scheduler) runDue(ctx context.Context, id string) ([]Run, error) {
+	schedule, err := s.opts.Store.GetSchedule(ctx, id)
+	if errors.Is(err, ScheduleNotFoundErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading schedule: %w", err)
+	}
+	now := s.now()
+	if !schedule.due(now) {
+		return nil, nil
+	}
+	tt, err := schedule.timetable()
+	if err != nil {
+		return nil, err
+	}
+
+	// only the latest missed times are kept, the history would otherwise grow
+	// with the length of the outage
+	var due []time.Time
+	dropped := 0
+	for at := schedule.NextRunAt; !at.IsZero() && !at.After(now); {
+		due = append(due, at)
+		if len(due) > s.opts.MaxCatchUp+1 {
+			due = due[1:]
+			dropped++
+		}
+		at = tt.next(at)
+	}
+	claimed := schedule.NextRunAt
+	schedule.NextRunAt = tt.next(now)
+	ok, err := s.opts.Store.ClaimSchedule(ctx, schedule, claimed)
+	if err != nil {
+		return nil, fmt.Errorf("claiming schedule: %w", err)
+	}
+	if !ok {
+		// another replica ran it since it was read
+		return nil, nil
+	}
+	if dropped > 0 {
+		s.opts.Logger.Info("skipped missed scheduled runs", "schedule", schedule.ID, "count", dropped)
+	}
+
+	toRun, skipped := s.catchUp(schedule.CatchUp, due, now)
+
+	var runs []Run
+	for _, at := range skipped {
+		runs = append(runs, Run{ScheduleID: schedule.ID, ScheduledAt: at, Status: RunSkipped, CatchUp: true})
+	}
+	for _, at := range toRun {
+		runs = append(runs, s.invoke(ctx, schedule, at, now.Sub(at) > s.opts.Grace))
+	}
+
+	var errs []error
+	for _, run := range runs {
+		if err := s.opts.Store.AppendRun(ctx, run); err != nil {
+			errs = append(errs, fmt.Errorf("recording run: %w", err))
+		}
+	}
+
+	if last := runs[len(runs)-1]; last.Status != RunSkipped {
+		// a schedule paused or changed meanwhile is left alone
+		schedule.LastRunAt = last.StartedAt
+		if _, err := s.opts.Store.ClaimSchedule(ctx, schedule, schedule.NextRunAt); err != nil {
+			errs = append(errs, fmt.Errorf("saving schedule: %w", err))
+		}
+	}
+	return runs, errors.Join(errs...)
+}
+
+// catchUp splits the due times into those to run and those to skip
+func (s *Scheduler) catchUp(policy CatchUp, due []time.Time, now time.Time) (run []time.Time, skip []time.Time) {
+	latest := due[len(due)-1]
+	switch policy {
+	case CatchUpAll:
+		if len(due) > s.opts.MaxCatchUp {
+			return due[len(due)-s.opts.MaxCatchUp:], due[:len(due)-s.opts.MaxCatchUp]
+		}
+		return due, nil
+	case CatchUpNone:
+		if now.Sub(latest) > s.opts.Grace {
+			return nil, due
+		}
+		return []time.Time{latest}, due[:len(due)-1]
+	default:
+		return []time.Time{latest}, due[:len(due)-1]
+	}
+}
+
+func (s *Scheduler) invoke(ctx context.Context, schedule *Schedule, at time.Time, late bool) Run {
+	run := Run{ScheduleID: schedule.ID, ScheduledAt: at, StartedAt: s.now(), CatchUp: late}
+
+	result, err := s.invoker.InvokeAction(ctx,
+		schedule.ComponentKey, schedule.ExternalUserID, schedule.Props, "")
+	run.FinishedAt = s.now()
+	if err != nil {
+		run.Status = RunFailed
+		run.Error = err.Error()
+		s.opts.Logger.Warn("scheduled action failed",
+			"schedule", schedule.ID, "component", schedule.ComponentKey, "error", err)
+		return run
+	}
+
+	run.Status = RunSucceeded
+	if result != nil {
+		run.Summary = result.Summary
+	}
+	return run
+}
+
+func newScheduleID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating schedule ID: %w", err)
+	}
+	return "sch_" + hex.EncodeToString(b), nil
+}
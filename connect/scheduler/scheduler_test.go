@@ -0,0 +1,394 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+	"github.com/stretchr/testify/suite"
+)
+
+type invocation struct {
+	componentKey   string
+	externalUserID string
+	props          connect.ConfiguredProps
+	at             time.Time
+}
+
+type fakeInvoker struct {
+	suite *schedulerTestSuite
+	mu    sync.Mutex
+	calls []invocation
+	err   error
+}
+
+func (f *fakeInvoker) InvokeAction(
+	_ context.Context,
+	componentKey string,
+	externalUserID string,
+	props connect.ConfiguredProps,
+	_ string,
+	_ ...connect.InvokeActionOption,
+) (*connect.ActionRunResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, invocation{componentKey, externalUserID, props, f.suite.now})
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &connect.ActionRunResult{Summary: "Sent message"}, nil
+}
+
+type fakeLock struct {
+	held     bool
+	ttl      time.Duration
+	released bool
+	acquires int
+	// heldFor loses the lock after that many acquires if positive
+	heldFor int
+}
+
+func (l *fakeLock) TryAcquire(_ context.Context, ttl time.Duration) (bool, error) {
+	l.ttl = ttl
+	l.acquires++
+	if l.heldFor > 0 && l.acquires > l.heldFor {
+		return false, nil
+	}
+	return l.held, nil
+}
+
+// staleStore lists the schedules as they were when it was created
+type staleStore struct {
+	Store
+	schedules []*Schedule
+}
+
+func (s *staleStore) ListSchedules(context.Context) ([]*Schedule, error) {
+	return s.schedules, nil
+}
+
+func (l *fakeLock) Release(context.Context) error {
+	l.released = true
+	return nil
+}
+
+type schedulerTestSuite struct {
+	suite.Suite
+	ctx     context.Context
+	now     time.Time
+	invoker *fakeInvoker
+	lock    *fakeLock
+	store   Store
+}
+
+func (suite *schedulerTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	// a Friday
+	suite.now = time.Date(2025, 3, 7, 7, 0, 0, 0, time.UTC)
+	suite.invoker = &fakeInvoker{suite: suite}
+	suite.lock = &fakeLock{held: true}
+	suite.store = NewMemoryStore()
+}
+
+func (suite *schedulerTestSuite) scheduler() *Scheduler {
+	return New(suite.invoker, Options{
+		Store:    suite.store,
+		Lock:     suite.lock,
+		Now:      func() time.Time { return suite.now },
+		Interval: time.Minute,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+}
+
+func weekdayMorning(catchUp CatchUp) Schedule {
+	return Schedule{
+		Name:           "standup reminder",
+		Cron:           "0 9 * * mon-fri",
+		Timezone:       "Europe/Berlin",
+		ComponentKey:   "slack-send-message",
+		ExternalUserID: "jverce",
+		Props:          connect.ConfiguredProps{"channel": "C1", "text": "standup"},
+		CatchUp:        catchUp,
+	}
+}
+
+func (suite *schedulerTestSuite) TestTick() {
+	require := suite.Require()
+	s := suite.scheduler()
+
+	schedule, err := s.Add(suite.ctx, weekdayMorning(""))
+	require.NoError(err)
+	require.NotEmpty(schedule.ID)
+	// 9:00 in Berlin is 8:00 UTC in March
+	require.Equal(time.Date(2025, 3, 7, 8, 0, 0, 0, time.UTC), schedule.NextRunAt)
+
+	runs, err := s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Empty(runs)
+
+	suite.now = time.Date(2025, 3, 7, 8, 0, 20, 0, time.UTC)
+	runs, err = s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Equal([]Run{{
+		ScheduleID:  schedule.ID,
+		ScheduledAt: time.Date(2025, 3, 7, 8, 0, 0, 0, time.UTC),
+		StartedAt:   suite.now,
+		FinishedAt:  suite.now,
+		Status:      RunSucceeded,
+		Summary:     "Sent message",
+	}}, runs)
+	require.Len(suite.invoker.calls, 1)
+	require.Equal("slack-send-message", suite.invoker.calls[0].componentKey)
+	require.Equal("jverce", suite.invoker.calls[0].externalUserID)
+	require.Equal("standup", suite.invoker.calls[0].props["text"])
+	require.Equal(3*time.Minute, suite.lock.ttl)
+
+	// the next run skips the weekend
+	stored, err := s.Get(suite.ctx, schedule.ID)
+	require.NoError(err)
+	require.Equal(time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC), stored.NextRunAt)
+	require.Equal(suite.now, stored.LastRunAt)
+
+	runs, err = s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Empty(runs)
+
+	// only the leader runs due schedules
+	suite.lock.held = false
+	suite.now = time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	runs, err = s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Empty(runs)
+	require.Len(suite.invoker.calls, 1)
+
+	suite.lock.held = true
+	suite.invoker.err = errors.New("channel_not_found")
+	runs, err = s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Equal(RunFailed, runs[0].Status)
+	require.Equal("channel_not_found", runs[0].Error)
+
+	history, err := s.Runs(suite.ctx, schedule.ID, 0)
+	require.NoError(err)
+	require.Len(history, 2)
+	require.Equal(RunFailed, history[0].Status)
+	require.Equal(RunSucceeded, history[1].Status)
+}
+
+func (suite *schedulerTestSuite) TestCatchUp() {
+	require := suite.Require()
+	s := suite.scheduler()
+
+	latest, err := s.Add(suite.ctx, weekdayMorning(CatchUpLatest))
+	require.NoError(err)
+	all, err := s.Add(suite.ctx, weekdayMorning(CatchUpAll))
+	require.NoError(err)
+	none, err := s.Add(suite.ctx, weekdayMorning(CatchUpNone))
+	require.NoError(err)
+
+	// down from Friday until Wednesday noon, missing Fri, Mon, Tue and Wed
+	suite.now = time.Date(2025, 3, 12, 11, 0, 0, 0, time.UTC)
+	_, err = s.Tick(suite.ctx)
+	require.NoError(err)
+
+	count := func(id string) (ran int, skipped int) {
+		runs, err := s.Runs(suite.ctx, id, 0)
+		require.NoError(err)
+		for _, run := range runs {
+			require.True(run.CatchUp)
+			if run.Status == RunSkipped {
+				skipped++
+			} else {
+				ran++
+			}
+		}
+		return ran, skipped
+	}
+
+	ran, skipped := count(latest.ID)
+	require.Equal([]int{1, 3}, []int{ran, skipped})
+	runs, err := s.Runs(suite.ctx, latest.ID, 1)
+	require.NoError(err)
+	require.Equal(time.Date(2025, 3, 12, 8, 0, 0, 0, time.UTC), runs[0].ScheduledAt)
+
+	ran, skipped = count(all.ID)
+	require.Equal([]int{4, 0}, []int{ran, skipped})
+
+	ran, skipped = count(none.ID)
+	require.Equal([]int{0, 4}, []int{ran, skipped})
+
+	require.Len(suite.invoker.calls, 5)
+	for _, id := range []string{latest.ID, all.ID, none.ID} {
+		schedule, err := s.Get(suite.ctx, id)
+		require.NoError(err)
+		require.Equal(time.Date(2025, 3, 13, 8, 0, 0, 0, time.UTC), schedule.NextRunAt)
+	}
+}
+
+func (suite *schedulerTestSuite) TestConcurrentReplicas() {
+	require := suite.Require()
+	s := suite.scheduler()
+
+	_, err := s.Add(suite.ctx, weekdayMorning(""))
+	require.NoError(err)
+	_, err = s.Add(suite.ctx, weekdayMorning(""))
+	require.NoError(err)
+	suite.now = time.Date(2025, 3, 7, 8, 0, 0, 0, time.UTC)
+
+	// the lock is renewed before each due schedule, a replica losing it stops
+	suite.lock.heldFor = 1
+	runs, err := s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Len(runs, 1)
+	first := runs[0].ScheduleID
+	require.Equal(2, suite.lock.acquires)
+
+	// a replica acting on a list read before the runs does not repeat them
+	snapshot, err := suite.store.ListSchedules(suite.ctx)
+	require.NoError(err)
+	suite.lock.heldFor = 0
+	runs, err = s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Len(runs, 1)
+	require.NotEqual(first, runs[0].ScheduleID)
+
+	suite.store = &staleStore{Store: suite.store, schedules: snapshot}
+	runs, err = suite.scheduler().Tick(suite.ctx)
+	require.NoError(err)
+	require.Empty(runs)
+	require.Len(suite.invoker.calls, 2)
+
+	// claiming fails once the stored next run moved on
+	stored, err := suite.store.GetSchedule(suite.ctx, first)
+	require.NoError(err)
+	claimed, err := suite.store.ClaimSchedule(suite.ctx, stored, suite.now)
+	require.NoError(err)
+	require.False(claimed)
+	claimed, err = suite.store.ClaimSchedule(suite.ctx, stored, stored.NextRunAt)
+	require.NoError(err)
+	require.True(claimed)
+}
+
+func (suite *schedulerTestSuite) TestStoreCopies() {
+	require := suite.Require()
+
+	props := connect.ConfiguredProps{"n": 1, "channel": "C1"}
+	schedule := &Schedule{ID: "sch_1", Props: props}
+	require.NoError(suite.store.SaveSchedule(suite.ctx, schedule))
+
+	got, err := suite.store.GetSchedule(suite.ctx, "sch_1")
+	require.NoError(err)
+	got.Props["n"] = "mutated"
+	require.Equal(1, props["n"])
+
+	stored, err := suite.store.GetSchedule(suite.ctx, "sch_1")
+	require.NoError(err)
+	require.Equal(json.Number("1"), stored.Props["n"])
+	listed, err := suite.store.ListSchedules(suite.ctx)
+	require.NoError(err)
+	require.Equal(json.Number("1"), listed[0].Props["n"])
+
+	schedule.Props = connect.ConfiguredProps{"ch": make(chan int)}
+	require.ErrorContains(suite.store.SaveSchedule(suite.ctx, schedule), "encoding props of schedule sch_1")
+}
+
+func (suite *schedulerTestSuite) TestPauseResumeRemove() {
+	require := suite.Require()
+	s := suite.scheduler()
+
+	schedule, err := s.Add(suite.ctx, weekdayMorning(CatchUpAll))
+	require.NoError(err)
+	_, err = s.Pause(suite.ctx, schedule.ID)
+	require.NoError(err)
+
+	suite.now = time.Date(2025, 3, 11, 12, 0, 0, 0, time.UTC)
+	runs, err := s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Empty(runs)
+
+	// runs due while paused are not caught up
+	resumed, err := s.Resume(suite.ctx, schedule.ID)
+	require.NoError(err)
+	require.False(resumed.Paused)
+	require.Equal(time.Date(2025, 3, 12, 8, 0, 0, 0, time.UTC), resumed.NextRunAt)
+
+	require.NoError(s.Remove(suite.ctx, schedule.ID))
+	_, err = s.Get(suite.ctx, schedule.ID)
+	require.ErrorIs(err, ScheduleNotFoundErr)
+
+	for _, invalid := range []Schedule{
+		{Cron: "0 9 * * *", ComponentKey: "slack-send-message"},
+		{Cron: "0 25 * * *", ComponentKey: "slack-send-message", ExternalUserID: "jverce"},
+		{Cron: "0 9 * * *", Timezone: "Mars/Olympus", ComponentKey: "slack-send-message", ExternalUserID: "jverce"},
+		{Cron: "0 9 * * *", ComponentKey: "slack-send-message", ExternalUserID: "jverce", CatchUp: "twice"},
+	} {
+		_, err := s.Add(suite.ctx, invalid)
+		require.Error(err)
+	}
+}
+
+func (suite *schedulerTestSuite) TestFileStore() {
+	require := suite.Require()
+	path := filepath.Join(suite.T().TempDir(), "schedules.json")
+
+	store, err := OpenFileStore(path)
+	require.NoError(err)
+	suite.store = store
+	schedule, err := suite.scheduler().Add(suite.ctx, weekdayMorning(""))
+	require.NoError(err)
+
+	suite.now = time.Date(2025, 3, 7, 8, 0, 0, 0, time.UTC)
+	_, err = suite.scheduler().Tick(suite.ctx)
+	require.NoError(err)
+
+	// a restarted process continues with the stored schedule and history
+	suite.store, err = OpenFileStore(path)
+	require.NoError(err)
+	s := suite.scheduler()
+	stored, err := s.Get(suite.ctx, schedule.ID)
+	require.NoError(err)
+	require.Equal(time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC), stored.NextRunAt)
+	require.Equal("standup", stored.Props["text"])
+
+	runs, err := s.Runs(suite.ctx, schedule.ID, 10)
+	require.NoError(err)
+	require.Len(runs, 1)
+
+	runs, err = s.Tick(suite.ctx)
+	require.NoError(err)
+	require.Empty(runs)
+}
+
+func (suite *schedulerTestSuite) TestRun() {
+	require := suite.Require()
+	s := suite.scheduler()
+	_, err := s.Add(suite.ctx, weekdayMorning(""))
+	require.NoError(err)
+	suite.now = time.Date(2025, 3, 7, 8, 0, 0, 0, time.UTC)
+
+	ctx, cancel := context.WithCancel(suite.ctx)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	// the first tick happens right away
+	require.Eventually(func() bool {
+		suite.invoker.mu.Lock()
+		defer suite.invoker.mu.Unlock()
+		return len(suite.invoker.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(<-done, context.Canceled)
+	require.True(suite.lock.released)
+}
+
+func TestScheduler(t *testing.T) {
+	suite.Run(t, new(schedulerTestSuite))
+}
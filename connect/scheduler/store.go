@@ -0,0 +1,240 @@
+package scheduler
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ScheduleNotFoundErr error = errors.New("schedule does not exist")
+
+// Store persists schedules and their run history
+type Store interface {
+	// SaveSchedule creates or replaces the schedule with the same ID
+	SaveSchedule(ctx context.Context, schedule *Schedule) error
+	GetSchedule(ctx context.Context, id string) (*Schedule, error)
+	// ClaimSchedule saves the schedule only if the stored one is still due at
+	// nextRunAt and reports whether it did. Replicas claim a due run with it
+	ClaimSchedule(ctx context.Context, schedule *Schedule, nextRunAt time.Time) (bool, error)
+	DeleteSchedule(ctx context.Context, id string) error
+	ListSchedules(ctx context.Context) ([]*Schedule, error)
+	AppendRun(ctx context.Context, run Run) error
+	// ListRuns returns the latest runs of a schedule first, at most limit if positive
+	ListRuns(ctx context.Context, scheduleID string, limit int) ([]Run, error)
+}
+
+// defaultHistory is the number of runs kept per schedule
+const defaultHistory = 100
+
+// MemoryStore keeps schedules and the latest runs of every schedule in memory
+type MemoryStore struct {
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+	runs      map[string][]Run
+	// History is the number of runs kept per schedule, defaults to 100
+	History int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		schedules: map[string]*Schedule{},
+		runs:      map[string][]Run{},
+		History:   defaultHistory,
+	}
+}
+
+func (s *MemoryStore) SaveSchedule(_ context.Context, schedule *Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, err := schedule.clone()
+	if err != nil {
+		return err
+	}
+	s.schedules[schedule.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetSchedule(_ context.Context, id string) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", id, ScheduleNotFoundErr)
+	}
+	return schedule.clone()
+}
+
+func (s *MemoryStore) ClaimSchedule(_ context.Context, schedule *Schedule, nextRunAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.schedules[schedule.ID]
+	if !ok || !stored.NextRunAt.Equal(nextRunAt) {
+		return false, nil
+	}
+	stored, err := schedule.clone()
+	if err != nil {
+		return false, err
+	}
+	s.schedules[schedule.ID] = stored
+	return true, nil
+}
+
+func (s *MemoryStore) DeleteSchedule(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[id]; !ok {
+		return fmt.Errorf("%s: %w", id, ScheduleNotFoundErr)
+	}
+	delete(s.schedules, id)
+	delete(s.runs, id)
+	return nil
+}
+
+func (s *MemoryStore) ListSchedules(_ context.Context) ([]*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Schedule
+	for _, schedule := range s.schedules {
+		clone, err := schedule.clone()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, clone)
+	}
+	slices.SortFunc(out, func(a, b *Schedule) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return out, nil
+}
+
+func (s *MemoryStore) AppendRun(_ context.Context, run Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := append(s.runs[run.ScheduleID], run)
+	if history := cmp.Or(s.History, defaultHistory); len(runs) > history {
+		runs = slices.Clone(runs[len(runs)-history:])
+	}
+	s.runs[run.ScheduleID] = runs
+	return nil
+}
+
+func (s *MemoryStore) ListRuns(_ context.Context, scheduleID string, limit int) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := slices.Clone(s.runs[scheduleID])
+	slices.Reverse(runs)
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// FileStore is a MemoryStore written to a JSON file after every change. It is
+// meant for a single process, replicas need a shared store
+type FileStore struct {
+	*MemoryStore
+	path string
+	// mu serializes changes with the writes they trigger
+	mu sync.Mutex
+}
+
+type fileStoreData struct {
+	Schedules []*Schedule      `json:"schedules"`
+	Runs      map[string][]Run `json:"runs"`
+}
+
+// OpenFileStore loads the store at path, a missing file is an empty store
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading schedule store %s: %w", path, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var data fileStoreData
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("decoding schedule store %s: %w", path, err)
+	}
+	for _, schedule := range data.Schedules {
+		s.schedules[schedule.ID] = schedule
+	}
+	for id, runs := range data.Runs {
+		s.runs[id] = runs
+	}
+	return s, nil
+}
+
+func (s *FileStore) SaveSchedule(ctx context.Context, schedule *Schedule) error {
+	return s.change(func() error { return s.MemoryStore.SaveSchedule(ctx, schedule) })
+}
+
+func (s *FileStore) ClaimSchedule(ctx context.Context, schedule *Schedule, nextRunAt time.Time) (bool, error) {
+	claimed := false
+	err := s.change(func() error {
+		var err error
+		claimed, err = s.MemoryStore.ClaimSchedule(ctx, schedule, nextRunAt)
+		return err
+	})
+	return claimed, err
+}
+
+func (s *FileStore) DeleteSchedule(ctx context.Context, id string) error {
+	return s.change(func() error { return s.MemoryStore.DeleteSchedule(ctx, id) })
+}
+
+func (s *FileStore) AppendRun(ctx context.Context, run Run) error {
+	return s.change(func() error { return s.MemoryStore.AppendRun(ctx, run) })
+}
+
+func (s *FileStore) change(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := fn(); err != nil {
+		return err
+	}
+
+	s.MemoryStore.mu.Lock()
+	data := fileStoreData{Runs: s.runs}
+	for _, schedule := range s.schedules {
+		data.Schedules = append(data.Schedules, schedule)
+	}
+	slices.SortFunc(data.Schedules, func(a, b *Schedule) int { return cmp.Compare(a.ID, b.ID) })
+	bs, err := json.MarshalIndent(data, "", "  ")
+	s.MemoryStore.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding schedule store: %w", err)
+	}
+
+	// write to a sibling file and rename so an interrupted write never corrupts the store
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing schedule store %s: %w", s.path, err)
+	}
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing schedule store %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing schedule store %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("writing schedule store %s: %w", s.path, err)
+	}
+	return nil
+}
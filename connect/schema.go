@@ -0,0 +1,293 @@
+package connect
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is the subset of JSON Schema draft 2020-12 produced by
+// ComponentDetails.JSONSchema. Pipedream specific behaviour is described with
+// x-pd-* extension keywords that form renderers can ignore
+type JSONSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+
+	Const     any           `json:"const,omitempty"`
+	Enum      []any         `json:"enum,omitempty"`
+	OneOf     []*JSONSchema `json:"oneOf,omitempty"`
+	AnyOf     []*JSONSchema `json:"anyOf,omitempty"`
+	Default   any           `json:"default,omitempty"`
+	Minimum   *int          `json:"minimum,omitempty"`
+	Maximum   *int          `json:"maximum,omitempty"`
+	MinLength *int          `json:"minLength,omitempty"`
+	ReadOnly  bool          `json:"readOnly,omitempty"`
+	WriteOnly bool          `json:"writeOnly,omitempty"`
+
+	PropType      PropType `json:"x-pd-type,omitempty"`
+	App           string   `json:"x-pd-app,omitempty"`
+	RemoteOptions bool     `json:"x-pd-remote-options,omitempty"`
+	UseQuery      bool     `json:"x-pd-use-query,omitempty"`
+	ReloadProps   bool     `json:"x-pd-reload-props,omitempty"`
+}
+
+// JSONSchema describes the user configured props of the component as an object
+// schema. Props provided by Pipedream, like HTTP interfaces and databases, are
+// left out. Static options become oneOf lists of const values titled with their
+// label, props with remote options are marked with x-pd-remote-options
+func (c ComponentDetails) JSONSchema() *JSONSchema {
+	closed := false
+	schema := &JSONSchema{
+		Schema:               JSONSchemaDialect,
+		Title:                c.Name,
+		Description:          c.Description,
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		AdditionalProperties: &closed,
+	}
+
+	for _, prop := range c.ConfigurableProps {
+		if prop == nil || !prop.Type.UserConfigured() {
+			continue
+		}
+		schema.Properties[prop.Name] = propSchema(prop)
+		if prop.Required() {
+			schema.Required = append(schema.Required, prop.Name)
+		}
+	}
+
+	return schema
+}
+
+func propSchema(prop *ConfigurableProp) *JSONSchema {
+	value := typeSchema(prop, prop.Type.ElemType())
+	if options := staticOptions(prop.Options); len(options) > 0 {
+		for _, option := range options {
+			value.OneOf = append(value.OneOf, &JSONSchema{Title: option.Label, Const: option.Value})
+		}
+	}
+
+	schema := value
+	if prop.Type.IsArray() {
+		schema = &JSONSchema{Type: "array", Items: value}
+	}
+
+	schema.Title = prop.Label
+	schema.Description = prop.Description
+	schema.Default = prop.Default
+	schema.ReadOnly = prop.Disabled
+	schema.WriteOnly = prop.Secret
+	schema.PropType = prop.Type
+	schema.App = prop.App
+	schema.RemoteOptions = prop.RemoteOptions != nil && *prop.RemoteOptions
+	schema.UseQuery = prop.UseQuery
+	schema.ReloadProps = prop.ReloadProps
+
+	return schema
+}
+
+func typeSchema(prop *ConfigurableProp, t PropType) *JSONSchema {
+	one := 1
+	switch t {
+	case PropTypeString, PropTypeSQL:
+		return &JSONSchema{Type: "string"}
+	case PropTypeBoolean:
+		return &JSONSchema{Type: "boolean"}
+	case PropTypeInteger:
+		return &JSONSchema{Type: "integer", Minimum: prop.Min, Maximum: prop.Max}
+	case PropTypeObject:
+		return &JSONSchema{Type: "object"}
+	case PropTypeApp:
+		return &JSONSchema{
+			Type: "object",
+			Properties: map[string]*JSONSchema{
+				"authProvisionId": {Type: "string", MinLength: &one},
+			},
+			Required: []string{"authProvisionId"},
+		}
+	case PropTypeTimer:
+		return &JSONSchema{
+			Type: "object",
+			Properties: map[string]*JSONSchema{
+				"intervalSeconds": {Type: "integer"},
+				"cron":            {Type: "string"},
+			},
+			AnyOf: []*JSONSchema{
+				{Required: []string{"intervalSeconds"}},
+				{Required: []string{"cron"}},
+			},
+		}
+	}
+	return &JSONSchema{}
+}
+
+// Validate checks props against the schema and returns a *PropValidationError
+// listing the failing props, or nil. Only the keywords JSONSchema emits are
+// supported
+func (s *JSONSchema) Validate(props ConfiguredProps) error {
+	normalized, err := normalizeJSON(props)
+	if err != nil {
+		return fmt.Errorf("encoding configured props: %w", err)
+	}
+	object, ok := normalized.(map[string]any)
+	if !ok {
+		object = map[string]any{}
+	}
+
+	var errs []PropError
+	for _, name := range s.Required {
+		if value, ok := object[name]; !ok || value == nil {
+			errs = append(errs, PropError{Prop: name, Message: "is required"})
+		}
+	}
+
+	for name, value := range object {
+		propSchema, ok := s.Properties[name]
+		switch {
+		case !ok && s.AdditionalProperties != nil && !*s.AdditionalProperties:
+			errs = append(errs, PropError{Prop: name, Message: "is not a prop of the component"})
+		case !ok || value == nil:
+			continue
+		default:
+			if msg := propSchema.check(propSchema.unwrapLabeled(value)); msg != "" {
+				errs = append(errs, PropError{Prop: name, Message: msg})
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(errs, func(a, b PropError) int {
+		return strings.Compare(a.Prop, b.Prop)
+	})
+	return &PropValidationError{Errors: errs}
+}
+
+// unwrapLabeled replaces a labeled value selected from options, sent as
+// {"__lv": {"label", "value"}}, by its value like ComponentDetails.Validate
+// accepts it. Values of object props are kept as they are
+func (s *JSONSchema) unwrapLabeled(value any) any {
+	if s.PropType == PropTypeObject {
+		return value
+	}
+	return unwrapLabeled(value)
+}
+
+// check validates a normalized JSON value and describes the first failure
+func (s *JSONSchema) check(value any) string {
+	if s.Type != "" && !schemaTypeMatches(s.Type, value) {
+		return fmt.Sprintf("expected %s, got %s", s.Type, jsonKind(value))
+	}
+
+	if s.Const != nil && !jsonEqual(s.Const, value) {
+		return fmt.Sprintf("must be %v", s.Const)
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return jsonEqual(e, value) }) {
+		return fmt.Sprintf("%v is not one of the allowed values", value)
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, option := range s.OneOf {
+			if option.check(value) == "" {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Sprintf("%v is not one of the options", value)
+		}
+	}
+	if len(s.AnyOf) > 0 && !slices.ContainsFunc(s.AnyOf, func(option *JSONSchema) bool {
+		return option.check(value) == ""
+	}) {
+		return "matches none of the allowed shapes"
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		if s.Minimum != nil && compareNumber(v, *s.Minimum) < 0 {
+			return fmt.Sprintf("%s is less than the minimum %d", v, *s.Minimum)
+		}
+		if s.Maximum != nil && compareNumber(v, *s.Maximum) > 0 {
+			return fmt.Sprintf("%s is greater than the maximum %d", v, *s.Maximum)
+		}
+	case string:
+		if s.MinLength != nil && len([]rune(v)) < *s.MinLength {
+			return fmt.Sprintf("is shorter than the minimum length %d", *s.MinLength)
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				if msg := s.Items.check(item); msg != "" {
+					return fmt.Sprintf("item %d: %s", i, msg)
+				}
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if field, ok := v[name]; !ok || field == nil {
+				return fmt.Sprintf("%s is required", name)
+			}
+		}
+		for name, field := range v {
+			if fieldSchema, ok := s.Properties[name]; ok && field != nil {
+				if msg := fieldSchema.check(field); msg != "" {
+					return fmt.Sprintf("%s: %s", name, msg)
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+func schemaTypeMatches(schemaType string, value any) bool {
+	switch schemaType {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		return isInteger(n)
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	}
+	return jsonKind(value) == schemaType
+}
+
+// jsonEqual compares values after normalizing them, so 1 and json.Number("1") are equal
+func jsonEqual(a, b any) bool {
+	na, errA := normalizeJSON(a)
+	nb, errB := normalizeJSON(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if numA, ok := na.(json.Number); ok {
+		numB, ok := nb.(json.Number)
+		if !ok {
+			return false
+		}
+		ia, errA := numA.Int64()
+		ib, errB := numB.Int64()
+		if errA == nil && errB == nil {
+			return ia == ib
+		}
+		fa, _ := numA.Float64()
+		fb, _ := numB.Float64()
+		return fa == fb
+	}
+	return reflect.DeepEqual(na, nb)
+}
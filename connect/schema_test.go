@@ -0,0 +1,189 @@
+package connect
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type schemaTestSuite struct {
+	suite.Suite
+	component ComponentDetails
+}
+
+func (suite *schemaTestSuite) SetupTest() {
+	remote := true
+	one, five := 1, 5
+	suite.component = ComponentDetails{
+		Component: Component{
+			Key:         "slack-send-message",
+			Name:        "Send Message",
+			Description: "Send a message to a channel",
+		},
+		ConfigurableProps: []*ConfigurableProp{
+			{Name: "slack", Type: PropTypeApp, App: "slack"},
+			{Name: "channel", Type: PropTypeString, Label: "Channel", RemoteOptions: &remote, UseQuery: true, ReloadProps: true},
+			{Name: "text", Type: PropTypeString, Label: "Text", Description: "Message text"},
+			{Name: "parse", Type: PropTypeString, Optional: true, Options: []any{
+				map[string]any{"label": "Full", "value": "full"},
+				map[string]any{"label": "None", "value": "none"},
+			}},
+			{Name: "priority", Type: PropTypeInteger, Min: &one, Max: &five, Default: 3},
+			{Name: "tags", Type: PropTypeStringArray, Optional: true, Options: []any{"a", "b"}},
+			{Name: "token", Type: PropTypeString, Secret: true, Optional: true},
+			{Name: "http", Type: PropTypeHTTP},
+		},
+	}
+}
+
+func (suite *schemaTestSuite) TestJSONSchema() {
+	require := suite.Require()
+
+	bs, err := json.Marshal(suite.component.JSONSchema())
+	require.NoError(err)
+
+	require.JSONEq(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "Send Message",
+		"description": "Send a message to a channel",
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["slack", "channel", "text"],
+		"properties": {
+			"slack": {
+				"type": "object",
+				"properties": {"authProvisionId": {"type": "string", "minLength": 1}},
+				"required": ["authProvisionId"],
+				"x-pd-type": "app",
+				"x-pd-app": "slack"
+			},
+			"channel": {
+				"title": "Channel",
+				"type": "string",
+				"x-pd-type": "string",
+				"x-pd-remote-options": true,
+				"x-pd-use-query": true,
+				"x-pd-reload-props": true
+			},
+			"text": {
+				"title": "Text",
+				"description": "Message text",
+				"type": "string",
+				"x-pd-type": "string"
+			},
+			"parse": {
+				"type": "string",
+				"oneOf": [{"title": "Full", "const": "full"}, {"title": "None", "const": "none"}],
+				"x-pd-type": "string"
+			},
+			"priority": {
+				"type": "integer",
+				"minimum": 1,
+				"maximum": 5,
+				"default": 3,
+				"x-pd-type": "integer"
+			},
+			"tags": {
+				"type": "array",
+				"items": {
+					"type": "string",
+					"oneOf": [{"title": "a", "const": "a"}, {"title": "b", "const": "b"}]
+				},
+				"x-pd-type": "string[]"
+			},
+			"token": {
+				"type": "string",
+				"writeOnly": true,
+				"x-pd-type": "string"
+			}
+		}
+	}`, string(bs))
+}
+
+func (suite *schemaTestSuite) TestValidate() {
+	require := suite.Require()
+	schema := suite.component.JSONSchema()
+
+	require.NoError(schema.Validate(ConfiguredProps{
+		"slack":    map[string]string{"authProvisionId": "apn_123"},
+		"channel":  "C123",
+		"text":     "hello",
+		"parse":    "full",
+		"priority": 5,
+		"tags":     []string{"b"},
+	}))
+
+	// values selected from options may be labeled
+	labeled := func(label string, value any) map[string]any {
+		return map[string]any{"__lv": map[string]any{"label": label, "value": value}}
+	}
+	props := ConfiguredProps{
+		"slack":    map[string]string{"authProvisionId": "apn_123"},
+		"channel":  labeled("#general", "C123"),
+		"text":     "hello",
+		"parse":    labeled("Full", "full"),
+		"priority": labeled("High", 5),
+	}
+	require.NoError(suite.component.Validate(props))
+	require.NoError(schema.Validate(props))
+	props["priority"] = labeled("Urgent", 7)
+	require.ErrorContains(schema.Validate(props), "priority: 7 is greater than the maximum 5")
+
+	err := schema.Validate(ConfiguredProps{
+		"slack":    map[string]string{"authProvisionId": ""},
+		"text":     42,
+		"parse":    "markdown",
+		"priority": 7,
+		"tags":     []string{"a", "c"},
+		"unknown":  true,
+	})
+	require.ErrorIs(err, InvalidPropsErr)
+
+	var validationErr *PropValidationError
+	require.True(errors.As(err, &validationErr))
+	require.Equal([]PropError{
+		{Prop: "channel", Message: "is required"},
+		{Prop: "parse", Message: "markdown is not one of the options"},
+		{Prop: "priority", Message: "7 is greater than the maximum 5"},
+		{Prop: "slack", Message: "authProvisionId: is shorter than the minimum length 1"},
+		{Prop: "tags", Message: "item 1: c is not one of the options"},
+		{Prop: "text", Message: "expected string, got number"},
+		{Prop: "unknown", Message: "is not a prop of the component"},
+	}, validationErr.Errors)
+}
+
+func (suite *schemaTestSuite) TestValidate_RoundTrip() {
+	require := suite.Require()
+	ten := 10
+
+	bs, err := json.Marshal(ComponentDetails{ConfigurableProps: []*ConfigurableProp{
+		{Name: "timer", Type: PropTypeTimer},
+		{Name: "ids", Type: PropTypeIntegerArray, Min: &ten},
+	}}.JSONSchema())
+	require.NoError(err)
+
+	var schema JSONSchema
+	require.NoError(json.Unmarshal(bs, &schema))
+
+	require.NoError(schema.Validate(ConfiguredProps{
+		"timer": map[string]any{"cron": "0 * * * *"},
+		"ids":   []int{10, 11},
+	}))
+
+	err = schema.Validate(ConfiguredProps{
+		"timer": map[string]any{"interval": 60},
+		"ids":   []any{10, 1.5},
+	})
+	var validationErr *PropValidationError
+	require.True(errors.As(err, &validationErr))
+	require.Equal([]PropError{
+		{Prop: "ids", Message: "item 1: expected integer, got number"},
+		{Prop: "timer", Message: "matches none of the allowed shapes"},
+	}, validationErr.Errors)
+}
+
+func TestSchema(t *testing.T) {
+	suite.Run(t, new(schemaTestSuite))
+}
@@ -0,0 +1,258 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConfigSession walks through the configuration of a single component for an
+// end user. It keeps the configurable props, the values configured so far and
+// the dynamic props ID, and reloads the props whenever a prop with ReloadProps
+// changes. A session is plain JSON so it can be stored between HTTP requests,
+// reattach it to a client with ResumeConfigSession
+type ConfigSession struct {
+	ComponentKey   string              `json:"component_key"`
+	ComponentType  ComponentType       `json:"component_type"`
+	ExternalUserID string              `json:"external_user_id"`
+	Props          []*ConfigurableProp `json:"props"`
+	Configured     ConfiguredProps     `json:"configured_props"`
+	DynamicPropsID string              `json:"dynamic_props_id,omitempty"`
+
+	client *Client
+}
+
+// NewConfigSession fetches the component and starts an empty configuration
+func (c *Client) NewConfigSession(
+	ctx context.Context,
+	componentKey string,
+	componentType ComponentType,
+	externalUserID string,
+) (*ConfigSession, error) {
+	component, err := c.GetComponent(ctx, componentKey, componentType)
+	if err != nil {
+		return nil, fmt.Errorf("starting config session for %s: %w", componentKey, err)
+	}
+	if component.Data == nil {
+		return nil, fmt.Errorf("component %s: %w", componentKey, NotFoundErr)
+	}
+
+	return &ConfigSession{
+		ComponentKey:   componentKey,
+		ComponentType:  componentType,
+		ExternalUserID: externalUserID,
+		Props:          component.Data.ConfigurableProps,
+		Configured:     ConfiguredProps{},
+		client:         c,
+	}, nil
+}
+
+// ResumeConfigSession restores a session serialized with json.Marshal
+func (c *Client) ResumeConfigSession(data []byte) (*ConfigSession, error) {
+	var session ConfigSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("decoding config session: %w", err)
+	}
+	if session.ComponentKey == "" {
+		return nil, errors.New("config session has no component key")
+	}
+	if session.Configured == nil {
+		session.Configured = ConfiguredProps{}
+	}
+	session.client = c
+
+	return &session, nil
+}
+
+// Prop returns the configurable prop called name
+func (s *ConfigSession) Prop(name string) (*ConfigurableProp, bool) {
+	for _, prop := range s.Props {
+		if prop != nil && prop.Name == name {
+			return prop, true
+		}
+	}
+	return nil, false
+}
+
+// NextProp returns the first required prop that has no value yet, or nil when
+// every required prop is configured
+func (s *ConfigSession) NextProp() *ConfigurableProp {
+	for _, prop := range s.Props {
+		if prop == nil || !prop.Required() {
+			continue
+		}
+		if value, ok := s.Configured[prop.Name]; !ok || value == nil {
+			return prop
+		}
+	}
+	return nil
+}
+
+// Options lists the values a prop accepts, fetching remote options when the
+// prop has them. The query is sent to Pipedream for props that support searching
+// and otherwise keeps the options whose label or value contains it
+func (s *ConfigSession) Options(
+	ctx context.Context,
+	propName string,
+	query string,
+) ([]Value, error) {
+	prop, ok := s.Prop(propName)
+	if !ok {
+		return nil, fmt.Errorf("prop %s: %w", propName, NotFoundErr)
+	}
+
+	if !(prop.RemoteOptions != nil && *prop.RemoteOptions || prop.Type == PropTypeApp) {
+		return filterOptions(staticOptions(prop.Options), query), nil
+	}
+
+	request := ConfigurePropRequest{
+		ExternalUserID:  s.ExternalUserID,
+		ComponentKey:    s.ComponentKey,
+		PropName:        propName,
+		ConfiguredProps: s.Configured,
+		DynamicPropsID:  s.DynamicPropsID,
+	}
+	if prop.UseQuery {
+		request.Query = query
+	}
+
+	options, err := s.client.AllPropOptions(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching options for prop %s: %w", propName, err)
+	}
+	if prop.UseQuery {
+		return options, nil
+	}
+
+	return filterOptions(options, query), nil
+}
+
+// Set configures a prop and reloads the component props when the prop asks for it
+func (s *ConfigSession) Set(ctx context.Context, propName string, value any) error {
+	prop, ok := s.Prop(propName)
+	if !ok {
+		return fmt.Errorf("prop %s: %w", propName, NotFoundErr)
+	}
+	if msg := checkPropValue(prop, value); msg != "" {
+		return &PropValidationError{Errors: []PropError{{Prop: propName, Message: msg}}}
+	}
+
+	if s.Configured == nil {
+		s.Configured = ConfiguredProps{}
+	}
+	s.Configured[propName] = value
+
+	if prop.ReloadProps {
+		return s.Reload(ctx)
+	}
+	return nil
+}
+
+// Reload asks Pipedream for the props matching the current configuration and
+// drops values of props that no longer exist
+func (s *ConfigSession) Reload(ctx context.Context) error {
+	reloaded, err := s.client.ReloadComponentProps(ctx,
+		s.ComponentType, s.Configured, s.ExternalUserID, s.ComponentKey, s.DynamicPropsID)
+	if err != nil {
+		return fmt.Errorf("reloading props of %s: %w", s.ComponentKey, err)
+	}
+	if err := reloaded.Err(s.ComponentKey); err != nil {
+		return err
+	}
+
+	if reloaded.DynamicProps.ID != "" {
+		s.DynamicPropsID = reloaded.DynamicProps.ID
+	}
+	if len(reloaded.DynamicProps.ConfigurableProps) == 0 {
+		return nil
+	}
+
+	props := make([]*ConfigurableProp, 0, len(reloaded.DynamicProps.ConfigurableProps))
+	for _, prop := range reloaded.DynamicProps.ConfigurableProps {
+		props = append(props, &prop)
+	}
+	s.Props = props
+
+	for name := range s.Configured {
+		if _, ok := s.Prop(name); !ok {
+			delete(s.Configured, name)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the configured values against the current props
+func (s *ConfigSession) Validate() error {
+	return validateProps(s.Props, s.Configured, false)
+}
+
+// Ready reports whether the component can be invoked or deployed
+func (s *ConfigSession) Ready() bool {
+	return s.Validate() == nil
+}
+
+// Invoke runs the configured action
+func (s *ConfigSession) Invoke(ctx context.Context, opts ...InvokeActionOption) (*ActionRunResult, error) {
+	if s.ComponentType != Actions {
+		return nil, fmt.Errorf("component %s is not an action", s.ComponentKey)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.client.InvokeAction(ctx,
+		s.ComponentKey, s.ExternalUserID, s.Configured, s.DynamicPropsID, opts...)
+}
+
+// Deploy deploys the configured trigger, webhookURL and workflowID are optional
+func (s *ConfigSession) Deploy(
+	ctx context.Context,
+	webhookURL string,
+	workflowID string,
+) (*Trigger, error) {
+	if s.ComponentType != Triggers {
+		return nil, fmt.Errorf("component %s is not a trigger", s.ComponentKey)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.client.DeployTrigger(ctx, s.ComponentKey, s.ExternalUserID,
+		s.Configured, webhookURL, s.DynamicPropsID, workflowID)
+}
+
+// staticOptions converts ConfigurableProp.Options, strings or label/value objects, into values
+func staticOptions(options []any) []Value {
+	values := make([]Value, 0, len(options))
+	for _, option := range options {
+		switch o := option.(type) {
+		case string:
+			values = append(values, Value{Label: o, Value: o})
+		case map[string]any:
+			label, _ := o["label"].(string)
+			values = append(values, Value{Label: label, Value: o["value"]})
+		default:
+			values = append(values, Value{Label: fmt.Sprint(o), Value: o})
+		}
+	}
+	return values
+}
+
+func filterOptions(options []Value, query string) []Value {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return options
+	}
+
+	var filtered []Value
+	for _, option := range options {
+		if strings.Contains(strings.ToLower(option.Label), query) ||
+			strings.Contains(strings.ToLower(fmt.Sprint(option.Value)), query) {
+			filtered = append(filtered, option)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,135 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type sessionTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+	runRequest      InvokeActionRequest
+}
+
+func (suite *sessionTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	require := suite.Require()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/project-abc/actions/slack-send-message":
+			_, _ = fmt.Fprint(w, `{
+				"data": {
+					"key": "slack-send-message",
+					"type": "action",
+					"configurable_props": [
+						{"name": "slack", "type": "app", "app": "slack"},
+						{"name": "channel", "type": "string", "remoteOptions": true},
+						{"name": "mode", "type": "string", "options": ["text", "blocks"], "reloadProps": true}
+					]
+				}
+			}`)
+		case r.URL.Path == "/project-abc/components/configure":
+			_, _ = fmt.Fprint(w, `{
+				"options": [
+					{"label": "#general", "value": "C01"},
+					{"label": "#random", "value": "C02"}
+				]
+			}`)
+		case r.URL.Path == "/project-abc/actions/props":
+			var body ReloadComponentPropsRequest
+			raw, err := io.ReadAll(r.Body)
+			require.NoError(err)
+			require.NoError(json.Unmarshal(raw, &body))
+			require.Equal("text", body.ConfiguredProps["mode"])
+
+			_, _ = fmt.Fprint(w, `{
+				"dynamicProps": {
+					"id": "dyp_123",
+					"configurableProps": [
+						{"name": "slack", "type": "app", "app": "slack"},
+						{"name": "channel", "type": "string", "remoteOptions": true},
+						{"name": "mode", "type": "string", "options": ["text", "blocks"], "reloadProps": true},
+						{"name": "text", "type": "string"}
+					]
+				}
+			}`)
+		case r.URL.Path == "/project-abc/actions/run":
+			raw, err := io.ReadAll(r.Body)
+			require.NoError(err)
+			require.NoError(json.Unmarshal(raw, &suite.runRequest))
+			_, _ = fmt.Fprint(w, `{"exports": {"$summary": "sent"}}`)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+}
+
+func (suite *sessionTestSuite) TestConfigSession_Flow() {
+	require := suite.Require()
+
+	session, err := suite.pipedreamClient.NewConfigSession(suite.ctx,
+		"slack-send-message", Actions, "user-123")
+	require.NoError(err)
+	require.Equal("slack", session.NextProp().Name)
+	require.False(session.Ready())
+
+	require.NoError(session.Set(suite.ctx, "slack", map[string]string{"authProvisionId": "apn_1"}))
+	require.Equal("channel", session.NextProp().Name)
+
+	options, err := session.Options(suite.ctx, "channel", "gen")
+	require.NoError(err)
+	require.Equal([]Value{{Label: "#general", Value: "C01"}}, options)
+	require.NoError(session.Set(suite.ctx, "channel", "C01"))
+
+	options, err = session.Options(suite.ctx, "mode", "")
+	require.NoError(err)
+	require.Len(options, 2)
+
+	err = session.Set(suite.ctx, "mode", 42)
+	require.True(errors.Is(err, InvalidPropsErr))
+
+	require.NoError(session.Set(suite.ctx, "mode", "text"))
+	require.Equal("dyp_123", session.DynamicPropsID)
+	require.Equal("text", session.NextProp().Name)
+
+	saved, err := json.Marshal(session)
+	require.NoError(err)
+
+	resumed, err := suite.pipedreamClient.ResumeConfigSession(saved)
+	require.NoError(err)
+	require.NoError(resumed.Set(suite.ctx, "text", "hello"))
+	require.Nil(resumed.NextProp())
+	require.True(resumed.Ready())
+
+	_, err = resumed.Invoke(suite.ctx)
+	require.NoError(err)
+	require.Equal("dyp_123", suite.runRequest.DynamicPropsID)
+	require.Equal("hello", suite.runRequest.ConfiguredProps["text"])
+
+	_, err = resumed.Deploy(suite.ctx, "", "")
+	require.Error(err)
+}
+
+func TestSession(t *testing.T) {
+	suite.Run(t, new(sessionTestSuite))
+}
@@ -0,0 +1,145 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/cloudsquid/pipedream-go-sdk/internal"
+)
+
+// NewStashID is sent as stash_id to create a new file stash for a run
+const NewStashID = "NEW"
+
+// stashUploadsExport is the export listing the files of the stash of a run
+const stashUploadsExport = "$filestash_uploads"
+
+var StashFileNotFoundErr error = errors.New("file is not in the stash")
+
+// StashFile is a file in a stash, the URLs are pre-signed and expire
+type StashFile struct {
+	// LocalPath is where the action sees the file, e.g. /tmp/report.pdf
+	LocalPath string `json:"localPath"`
+	S3Key     string `json:"s3Key"`
+	GetURL    string `json:"get_url"`
+	PutURL    string `json:"put_url"`
+}
+
+// Name returns the base name of the file
+func (f StashFile) Name() string {
+	return path.Base(f.LocalPath)
+}
+
+// StashFiles lists the files in the stash of the run, it is empty unless the
+// action was invoked with WithNewStash or WithStash. The Connect API has no
+// documented endpoint listing a stash by ID, so the files of a stash are the
+// ones reported by the runs using it
+func (r *ActionRunResult) StashFiles() ([]StashFile, error) {
+	raw, ok := r.Exports[stashUploadsExport]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	bs, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", stashUploadsExport, err)
+	}
+	var files []StashFile
+	if err := json.Unmarshal(bs, &files); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", stashUploadsExport, err)
+	}
+	return files, nil
+}
+
+// StashFile returns the stashed file the action wrote to localPath, relative
+// paths are resolved against /tmp
+func (r *ActionRunResult) StashFile(localPath string) (StashFile, error) {
+	files, err := r.StashFiles()
+	if err != nil {
+		return StashFile{}, err
+	}
+	return findStashFile(files, r.StashID, localPath)
+}
+
+// stashPath resolves relative paths against /tmp, where actions see stashed files
+func stashPath(localPath string) string {
+	if !strings.HasPrefix(localPath, "/") {
+		localPath = path.Join("/tmp", localPath)
+	}
+	return path.Clean(localPath)
+}
+
+func findStashFile(files []StashFile, stashID string, localPath string) (StashFile, error) {
+	localPath = stashPath(localPath)
+	for _, file := range files {
+		if path.Clean(file.LocalPath) == localPath {
+			return file, nil
+		}
+	}
+	return StashFile{}, fmt.Errorf("%s in stash %s: %w", localPath, stashID, StashFileNotFoundErr)
+}
+
+// DownloadStashFile streams the content of a stashed file, the caller closes
+// the reader
+func (c *Client) DownloadStashFile(ctx context.Context, file StashFile) (io.ReadCloser, error) {
+	if file.GetURL == "" {
+		return nil, fmt.Errorf("stash file %s has no download URL", file.LocalPath)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.GetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating new request: %w", err)
+	}
+
+	// the URL is pre-signed, the access token must not be sent along
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading stash file %s: %w", file.LocalPath, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("downloading stash file %s: %w", file.LocalPath,
+			&internal.StatusError{StatusCode: resp.StatusCode, Body: string(body)})
+	}
+	return resp.Body, nil
+}
+
+// UploadStashFile replaces the content of a stashed file with the content of
+// r, size is the length in bytes or -1 if unknown. Actions invoked WithStash
+// afterwards read the new content from file.LocalPath
+func (c *Client) UploadStashFile(ctx context.Context, file StashFile, r io.Reader, size int64) error {
+	if file.PutURL == "" {
+		return fmt.Errorf("stash file %s has no upload URL", file.LocalPath)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, file.PutURL, r)
+	if err != nil {
+		return fmt.Errorf("creating new request: %w", err)
+	}
+	if size >= 0 {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading stash file %s: %w", file.LocalPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("uploading stash file %s: %w", file.LocalPath,
+			&internal.StatusError{StatusCode: resp.StatusCode, Body: string(body)})
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,140 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type stashTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+	requests        []InvokeActionRequest
+	stored          map[string]string
+}
+
+func (suite *stashTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.requests = nil
+	suite.stored = map[string]string{"report.pdf": "%PDF-1.7"}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require := suite.Require()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/project-abc/actions/run":
+			var request InvokeActionRequest
+			require.NoError(json.NewDecoder(r.Body).Decode(&request))
+			suite.requests = append(suite.requests, request)
+			_, _ = fmt.Fprintf(w, `{
+				"exports": {"$filestash_uploads": [{
+					"localPath": "/tmp/report.pdf",
+					"s3Key": "stash_1/report.pdf",
+					"get_url": "%[1]s/s3/report.pdf?sig=get",
+					"put_url": "%[1]s/s3/report.pdf?sig=put"
+				}]},
+				"ret": "/tmp/report.pdf",
+				"stash_id": "stash_1"
+			}`, server.URL)
+		case strings.HasPrefix(r.URL.Path, "/s3/"):
+			// pre-signed URLs are called without the access token
+			require.Empty(r.Header.Get("Authorization"))
+			name := strings.TrimPrefix(r.URL.Path, "/s3/")
+			switch r.Method {
+			case http.MethodGet:
+				content, ok := suite.stored[name]
+				if !ok {
+					http.Error(w, "NoSuchKey", http.StatusNotFound)
+					return
+				}
+				_, _ = io.WriteString(w, content)
+			case http.MethodPut:
+				require.Equal("put", r.URL.Query().Get("sig"))
+				bs, err := io.ReadAll(r.Body)
+				require.NoError(err)
+				suite.stored[name] = string(bs)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+}
+
+func (suite *stashTestSuite) TestRoundTrip() {
+	require := suite.Require()
+
+	result, err := suite.pipedreamClient.InvokeAction(suite.ctx,
+		"google_drive-download-file", "jverce", ConfiguredProps{"fileId": "f1"}, "", WithNewStash())
+	require.NoError(err)
+	require.Equal(NewStashID, suite.requests[0].StashID)
+	require.Equal("stash_1", result.StashID)
+
+	files, err := result.StashFiles()
+	require.NoError(err)
+	require.Len(files, 1)
+	require.Equal("report.pdf", files[0].Name())
+
+	file, err := result.StashFile("report.pdf")
+	require.NoError(err)
+	require.Equal(files[0], file)
+	_, err = result.StashFile("/tmp/missing.pdf")
+	require.ErrorIs(err, StashFileNotFoundErr)
+
+	body, err := suite.pipedreamClient.DownloadStashFile(suite.ctx, file)
+	require.NoError(err)
+	content, err := io.ReadAll(body)
+	require.NoError(err)
+	require.NoError(body.Close())
+	require.Equal("%PDF-1.7", string(content))
+
+	require.NoError(suite.pipedreamClient.UploadStashFile(suite.ctx, file, strings.NewReader("signed"), 6))
+	require.Equal("signed", suite.stored["report.pdf"])
+
+	// the next action reads the uploaded file from the same stash
+	_, err = suite.pipedreamClient.InvokeAction(suite.ctx,
+		"google_drive-upload-file", "jverce", ConfiguredProps{"filePath": file.LocalPath}, "", WithStash(result.StashID))
+	require.NoError(err)
+	require.Equal("stash_1", suite.requests[1].StashID)
+}
+
+func (suite *stashTestSuite) TestErrors() {
+	require := suite.Require()
+
+	result, err := suite.pipedreamClient.InvokeAction(suite.ctx, "google_drive-download-file", "jverce", ConfiguredProps{}, "")
+	require.NoError(err)
+	require.Empty(suite.requests[0].StashID)
+
+	file, err := result.StashFile("report.pdf")
+	require.NoError(err)
+	delete(suite.stored, "report.pdf")
+	_, err = suite.pipedreamClient.DownloadStashFile(suite.ctx, file)
+	code, ok := HTTPStatusCode(err)
+	require.True(ok)
+	require.Equal(http.StatusNotFound, code)
+
+	_, err = suite.pipedreamClient.DownloadStashFile(suite.ctx, StashFile{LocalPath: "/tmp/x"})
+	require.ErrorContains(err, "has no download URL")
+
+	files, err := (&ActionRunResult{}).StashFiles()
+	require.NoError(err)
+	require.Empty(files)
+}
+
+func TestStash(t *testing.T) {
+	suite.Run(t, new(stashTestSuite))
+}
@@ -0,0 +1,257 @@
+package connect
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var defaultAgeBuckets = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	90 * 24 * time.Hour,
+	365 * 24 * time.Hour,
+}
+
+type AccountStatsOptions struct {
+	// ExternalUserID and App optionally narrow the accounts considered
+	ExternalUserID string
+	App            string
+	// AgeBuckets are the positive upper bounds of the connection age histogram,
+	// defaults to 1, 7, 30, 90 and 365 days. A final unbounded bucket is always added
+	AgeBuckets []time.Duration
+	// Now is the reference time for connection ages, defaults to time.Now
+	Now time.Time
+}
+
+type AgeBucket struct {
+	Label string `json:"label"`
+	// UpperBound is exclusive, it is zero for the unbounded last bucket
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      int           `json:"count"`
+}
+
+type AccountCounts struct {
+	Total     int `json:"total"`
+	Healthy   int `json:"healthy"`
+	Unhealthy int `json:"unhealthy"`
+	Dead      int `json:"dead"`
+}
+
+// UnhealthyRatio is the fraction of accounts that are not healthy, dead ones included
+func (c AccountCounts) UnhealthyRatio() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Unhealthy) / float64(c.Total)
+}
+
+func (c *AccountCounts) add(a *Account) {
+	c.Total++
+	if a.Healthy {
+		c.Healthy++
+	} else {
+		c.Unhealthy++
+	}
+	if a.Dead {
+		c.Dead++
+	}
+}
+
+type AppAccountStats struct {
+	App string `json:"app"`
+	AccountCounts
+	Users int         `json:"users"`
+	Ages  []AgeBucket `json:"ages"`
+}
+
+type UserAccountStats struct {
+	ExternalUserID string `json:"external_user_id"`
+	AccountCounts
+	Apps []string `json:"apps"`
+}
+
+// AccountStatsReport aggregates the accounts of a project, apps and users are sorted by name
+type AccountStatsReport struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	AccountCounts
+	Apps  []AppAccountStats  `json:"apps"`
+	Users []UserAccountStats `json:"users"`
+	Ages  []AgeBucket        `json:"ages"`
+}
+
+// App returns the stats of a single app by its name slug
+func (r *AccountStatsReport) App(nameSlug string) (AppAccountStats, bool) {
+	i := slices.IndexFunc(r.Apps, func(s AppAccountStats) bool { return s.App == nameSlug })
+	if i < 0 {
+		return AppAccountStats{}, false
+	}
+	return r.Apps[i], true
+}
+
+// AccountStats pages through all accounts and aggregates them by app, health and external user
+func (c *Client) AccountStats(
+	ctx context.Context,
+	opts AccountStatsOptions,
+) (*AccountStatsReport, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	buckets := opts.AgeBuckets
+	if len(buckets) == 0 {
+		buckets = defaultAgeBuckets
+	}
+	for _, bound := range buckets {
+		if bound <= 0 {
+			return nil, fmt.Errorf("invalid age bucket %s: bounds must be positive", bound)
+		}
+	}
+	buckets = slices.Compact(slices.Sorted(slices.Values(buckets)))
+
+	report := &AccountStatsReport{
+		GeneratedAt: now.UTC(),
+		Ages:        newAgeBuckets(buckets),
+	}
+	apps := map[string]*AppAccountStats{}
+	appUsers := map[string]map[string]struct{}{}
+	users := map[string]*UserAccountStats{}
+
+	err := c.walkAccounts(ctx,
+		accountsQuery(opts.ExternalUserID, opts.App, "", false),
+		func(page []*Account) error {
+			for _, account := range page {
+				report.add(account)
+
+				app := account.App.NameSlug
+				if apps[app] == nil {
+					apps[app] = &AppAccountStats{App: app, Ages: newAgeBuckets(buckets)}
+					appUsers[app] = map[string]struct{}{}
+				}
+				apps[app].add(account)
+				appUsers[app][account.ExternalID] = struct{}{}
+
+				user := users[account.ExternalID]
+				if user == nil {
+					user = &UserAccountStats{ExternalUserID: account.ExternalID}
+					users[account.ExternalID] = user
+				}
+				user.add(account)
+				if !slices.Contains(user.Apps, app) {
+					user.Apps = append(user.Apps, app)
+				}
+
+				if !account.CreatedAt.IsZero() {
+					age := now.Sub(account.CreatedAt)
+					countAge(report.Ages, age)
+					countAge(apps[app].Ages, age)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("collecting account stats: %w", err)
+	}
+
+	for app, stats := range apps {
+		stats.Users = len(appUsers[app])
+		report.Apps = append(report.Apps, *stats)
+	}
+	slices.SortFunc(report.Apps, func(a, b AppAccountStats) int {
+		return cmp.Compare(a.App, b.App)
+	})
+
+	for _, stats := range users {
+		slices.Sort(stats.Apps)
+		report.Users = append(report.Users, *stats)
+	}
+	slices.SortFunc(report.Users, func(a, b UserAccountStats) int {
+		return cmp.Compare(a.ExternalUserID, b.ExternalUserID)
+	})
+
+	return report, nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *AccountStatsReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per app followed by a total row, with a column per age bucket
+func (r *AccountStatsReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"app", "total", "healthy", "unhealthy", "dead", "users"}
+	for _, bucket := range r.Ages {
+		header = append(header, "age "+bucket.Label)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	row := func(name string, counts AccountCounts, users int, ages []AgeBucket) []string {
+		record := []string{
+			name,
+			strconv.Itoa(counts.Total),
+			strconv.Itoa(counts.Healthy),
+			strconv.Itoa(counts.Unhealthy),
+			strconv.Itoa(counts.Dead),
+			strconv.Itoa(users),
+		}
+		for _, bucket := range ages {
+			record = append(record, strconv.Itoa(bucket.Count))
+		}
+		return record
+	}
+
+	for _, app := range r.Apps {
+		if err := cw.Write(row(app.App, app.AccountCounts, app.Users, app.Ages)); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write(row("*", r.AccountCounts, len(r.Users), r.Ages)); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func newAgeBuckets(bounds []time.Duration) []AgeBucket {
+	buckets := make([]AgeBucket, 0, len(bounds)+1)
+	for _, bound := range bounds {
+		buckets = append(buckets, AgeBucket{Label: "<" + formatAge(bound), UpperBound: bound})
+	}
+	if len(bounds) > 0 {
+		buckets = append(buckets, AgeBucket{Label: ">=" + formatAge(bounds[len(bounds)-1])})
+	} else {
+		buckets = append(buckets, AgeBucket{Label: "all"})
+	}
+	return buckets
+}
+
+// countAge counts age in its bucket, the last one takes the ages beyond all bounds
+func countAge(buckets []AgeBucket, age time.Duration) {
+	for i := range buckets {
+		if i == len(buckets)-1 || age < buckets[i].UpperBound {
+			buckets[i].Count++
+			return
+		}
+	}
+}
+
+func formatAge(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	}
+	return d.String()
+}
@@ -0,0 +1,142 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/stretchr/testify/suite"
+)
+
+type statsTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *Client
+}
+
+func (suite *statsTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{
+				"access_token": "new-access-token",
+				"expires_in": 3600
+			}`)
+		case r.URL.Path == "/project-abc/accounts" && r.URL.Query().Get("after") == "":
+			_, _ = fmt.Fprint(w, `{
+				"page_info": {"total_count": 4, "count": 2, "end_cursor": "c1"},
+				"data": [
+					{"id": "apn_1", "external_id": "user-1", "healthy": true,
+					 "app": {"name_slug": "google_sheets"}, "created_at": "2025-06-01T06:00:00Z"},
+					{"id": "apn_2", "external_id": "user-2", "healthy": true,
+					 "app": {"name_slug": "google_sheets"}, "created_at": "2025-03-01T12:00:00Z"}
+				]
+			}`)
+		case r.URL.Path == "/project-abc/accounts":
+			_, _ = fmt.Fprint(w, `{
+				"page_info": {"total_count": 4, "count": 2, "end_cursor": "c2"},
+				"data": [
+					{"id": "apn_3", "external_id": "user-1", "healthy": true,
+					 "app": {"name_slug": "hubspot"}, "created_at": "2024-01-01T12:00:00Z"},
+					{"id": "apn_4", "external_id": "user-3", "healthy": false, "dead": true,
+					 "app": {"name_slug": "hubspot"}, "created_at": "2025-05-20T12:00:00Z"}
+				]
+			}`)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "",
+		"", nil, server.URL, server.URL)
+	suite.pipedreamClient = &Client{Client: base}
+}
+
+func (suite *statsTestSuite) stats() *AccountStatsReport {
+	report, err := suite.pipedreamClient.AccountStats(suite.ctx, AccountStatsOptions{
+		Now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+	})
+	suite.Require().NoError(err)
+	return report
+}
+
+func (suite *statsTestSuite) TestAccountStats_Aggregates() {
+	require := suite.Require()
+	report := suite.stats()
+
+	require.Equal(4, report.Total)
+	require.Equal(1, report.Dead)
+	require.Len(report.Users, 3)
+	require.Equal([]string{"google_sheets", "hubspot"}, report.Users[0].Apps)
+
+	sheets, ok := report.App("google_sheets")
+	require.True(ok)
+	require.Equal(2, sheets.Total)
+	require.Equal(2, sheets.Users)
+
+	hubspot, ok := report.App("hubspot")
+	require.True(ok)
+	require.Equal(0.5, hubspot.UnhealthyRatio())
+
+	counts := map[string]int{}
+	for _, bucket := range report.Ages {
+		counts[bucket.Label] = bucket.Count
+	}
+	require.Equal(map[string]int{
+		"<1d": 1, "<7d": 0, "<30d": 1, "<90d": 0, "<365d": 1, ">=365d": 1,
+	}, counts)
+}
+
+func (suite *statsTestSuite) TestAccountStats_Output() {
+	require := suite.Require()
+	report := suite.stats()
+
+	var csvOut bytes.Buffer
+	require.NoError(report.WriteCSV(&csvOut))
+	records, err := csv.NewReader(&csvOut).ReadAll()
+	require.NoError(err)
+	require.Len(records, 4)
+	require.Equal([]string{"hubspot", "2", "1", "1", "1", "2", "0", "0", "1", "0", "0", "1"}, records[2])
+
+	var jsonOut bytes.Buffer
+	require.NoError(report.WriteJSON(&jsonOut))
+	var decoded AccountStatsReport
+	require.NoError(json.Unmarshal(jsonOut.Bytes(), &decoded))
+	require.Equal(report.Total, decoded.Total)
+	require.Equal(report.Apps, decoded.Apps)
+}
+
+func (suite *statsTestSuite) TestAccountStats_Buckets() {
+	require := suite.Require()
+
+	report, err := suite.pipedreamClient.AccountStats(suite.ctx, AccountStatsOptions{
+		Now:        time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		AgeBuckets: []time.Duration{30 * 24 * time.Hour, 24 * time.Hour, 24 * time.Hour},
+	})
+	require.NoError(err)
+	counts := map[string]int{}
+	for _, bucket := range report.Ages {
+		counts[bucket.Label] = bucket.Count
+	}
+	require.Equal(map[string]int{"<1d": 1, "<30d": 1, ">=30d": 2}, counts)
+
+	for _, bound := range []time.Duration{0, -time.Hour} {
+		_, err = suite.pipedreamClient.AccountStats(suite.ctx, AccountStatsOptions{
+			AgeBuckets: []time.Duration{24 * time.Hour, bound},
+		})
+		require.ErrorContains(err, "bounds must be positive")
+	}
+}
+
+func TestStats(t *testing.T) {
+	suite.Run(t, new(statsTestSuite))
+}
@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+)
+
+var (
+	UnknownToolErr      error = errors.New("unknown tool")
+	ToolConflictErr     error = errors.New("tool name is taken by another component")
+	NoHealthyAccountErr error = errors.New("end user has no healthy account for app")
+)
+
+// Dispatcher runs the tool calls of a model as Connect actions
+type Dispatcher struct {
+	client *connect.Client
+
+	mu    sync.RWMutex
+	tools map[string]*Tool
+}
+
+func NewDispatcher(client *connect.Client, tools ...*Tool) (*Dispatcher, error) {
+	d := &Dispatcher{client: client, tools: map[string]*Tool{}}
+	if err := d.Add(tools...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Add registers the tools, replacing tools of the same component. A name used
+// by a tool of another component fails with ToolConflictErr
+func (d *Dispatcher) Add(tools ...*Tool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, tool := range tools {
+		if existing, ok := d.tools[tool.Name]; ok && existing.ComponentKey != tool.ComponentKey {
+			return fmt.Errorf("tool %s of %s and %s: %w",
+				tool.Name, existing.ComponentKey, tool.ComponentKey, ToolConflictErr)
+		}
+		d.tools[tool.Name] = tool
+	}
+	return nil
+}
+
+// Load fetches the actions and registers their tools
+func (d *Dispatcher) Load(ctx context.Context, componentKeys ...string) error {
+	for _, key := range componentKeys {
+		component, err := d.client.GetComponent(ctx, key, connect.Actions)
+		if err != nil {
+			return fmt.Errorf("fetching action %s: %w", key, err)
+		}
+		if component.Data == nil {
+			return fmt.Errorf("action %s: %w", key, connect.NotFoundErr)
+		}
+
+		tool, err := FromComponent(component.Data)
+		if err != nil {
+			return err
+		}
+		if err := d.Add(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tools returns the registered tools ordered by name
+func (d *Dispatcher) Tools() []*Tool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	tools := make([]*Tool, 0, len(d.tools))
+	for _, tool := range d.tools {
+		tools = append(tools, tool)
+	}
+	slices.SortFunc(tools, func(a, b *Tool) int { return cmp.Compare(a.Name, b.Name) })
+	return tools
+}
+
+func (d *Dispatcher) OpenAITools() []OpenAITool {
+	var out []OpenAITool
+	for _, tool := range d.Tools() {
+		out = append(out, tool.OpenAI())
+	}
+	return out
+}
+
+func (d *Dispatcher) AnthropicTools() []AnthropicTool {
+	var out []AnthropicTool
+	for _, tool := range d.Tools() {
+		out = append(out, tool.Anthropic())
+	}
+	return out
+}
+
+// Dispatch runs the tool called name for externalUserID. arguments is the JSON
+// object produced by the model, OpenAI's function arguments or Anthropic's tool
+// input. The arguments are validated against the input schema and every app
+// prop gets the end user's healthy account, the most recently updated one when
+// there are several
+func (d *Dispatcher) Dispatch(
+	ctx context.Context,
+	externalUserID string,
+	name string,
+	arguments json.RawMessage,
+) (*connect.ActionRunResult, error) {
+	tool, ok := d.Tool(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", UnknownToolErr, name)
+	}
+
+	props := connect.ConfiguredProps{}
+	if len(bytes.TrimSpace(arguments)) > 0 && !bytes.Equal(bytes.TrimSpace(arguments), []byte("null")) {
+		if err := json.Unmarshal(arguments, &props); err != nil {
+			return nil, fmt.Errorf("decoding arguments of %s: %w", name, err)
+		}
+	}
+	if err := tool.InputSchema.Validate(props); err != nil {
+		return nil, fmt.Errorf("arguments of %s: %w", name, err)
+	}
+
+	authProps, err := d.AuthProps(ctx, externalUserID, tool)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(props, authProps)
+
+	return d.client.InvokeAction(ctx, tool.ComponentKey, externalUserID, props, "")
+}
+
+func (d *Dispatcher) Tool(name string) (*Tool, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	tool, ok := d.tools[name]
+	return tool, ok
+}
+
+// AuthProps returns the app props of tool set to the end user's healthy accounts
+func (d *Dispatcher) AuthProps(
+	ctx context.Context,
+	externalUserID string,
+	tool *Tool,
+) (connect.ConfiguredProps, error) {
+	props := connect.ConfiguredProps{}
+	for _, appProp := range tool.AppProps {
+		account, err := d.healthyAccount(ctx, externalUserID, appProp.App)
+		if err != nil {
+			return nil, err
+		}
+		props[appProp.Name] = map[string]string{"authProvisionId": account.ID}
+	}
+	return props, nil
+}
+
+func (d *Dispatcher) healthyAccount(
+	ctx context.Context,
+	externalUserID string,
+	app string,
+) (*connect.Account, error) {
+	accounts, err := d.client.ListAllAccounts(ctx, externalUserID, app, "", false)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s accounts of %s: %w", app, externalUserID, err)
+	}
+
+	var best *connect.Account
+	for _, account := range accounts {
+		if account == nil || !account.Healthy || account.Dead {
+			continue
+		}
+		if best == nil || account.UpdatedAt.After(best.UpdatedAt) {
+			best = account
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w %s", NoHealthyAccountErr, app)
+	}
+	return best, nil
+}
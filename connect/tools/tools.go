@@ -0,0 +1,166 @@
+// Package tools describes Connect actions as LLM tools and dispatches the tool
+// calls of a model back to InvokeAction
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+)
+
+// maxNameLength is the tool name limit of the OpenAI and Anthropic APIs
+const maxNameLength = 64
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// AppProp is an app prop of the action, filled with an account of the end user
+// when the tool is called
+type AppProp struct {
+	Name string `json:"name"`
+	App  string `json:"app"`
+}
+
+// Tool is an action described for tool calling. The input schema only contains
+// the props the model has to fill in
+type Tool struct {
+	Name         string              `json:"name"`
+	Description  string              `json:"description"`
+	InputSchema  *connect.JSONSchema `json:"input_schema"`
+	ComponentKey string              `json:"component_key"`
+	AppProps     []AppProp           `json:"app_props,omitempty"`
+}
+
+// FromComponent builds the tool for an action. App props are removed from the
+// input schema and recorded in AppProps, Pipedream extension keywords are dropped
+func FromComponent(component *connect.ComponentDetails) (*Tool, error) {
+	if component == nil || component.Key == "" {
+		return nil, fmt.Errorf("component without key")
+	}
+
+	tool := &Tool{
+		Name:         ToolName(component.Key),
+		Description:  description(component),
+		ComponentKey: component.Key,
+	}
+
+	schema := component.JSONSchema()
+	schema.Schema = ""
+	schema.Title = ""
+	schema.Description = ""
+	schema.Required = nil
+	for _, prop := range component.ConfigurableProps {
+		if prop == nil {
+			continue
+		}
+		if prop.Type == connect.PropTypeApp {
+			tool.AppProps = append(tool.AppProps, AppProp{Name: prop.Name, App: prop.App})
+			delete(schema.Properties, prop.Name)
+			continue
+		}
+		if _, ok := schema.Properties[prop.Name]; ok && prop.Required() {
+			schema.Required = append(schema.Required, prop.Name)
+		}
+	}
+	for name, propSchema := range schema.Properties {
+		schema.Properties[name] = stripExtensions(propSchema)
+	}
+	tool.InputSchema = schema
+
+	return tool, nil
+}
+
+// ToolName converts a component key into a valid tool name. Keys that have to
+// be altered get a short hash of the key appended, so they stay unique
+func ToolName(componentKey string) string {
+	name := invalidNameChars.ReplaceAllString(componentKey, "_")
+	if name == componentKey && len(name) <= maxNameLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(componentKey))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	if len(name) > maxNameLength-len(suffix) {
+		name = name[:maxNameLength-len(suffix)]
+	}
+	return name + suffix
+}
+
+func description(component *connect.ComponentDetails) string {
+	parts := []string{}
+	if component.Name != "" {
+		parts = append(parts, component.Name)
+	}
+	if component.Description != "" {
+		parts = append(parts, component.Description)
+	}
+	if len(parts) == 0 {
+		return component.Key
+	}
+	return strings.Join(parts, ": ")
+}
+
+// stripExtensions returns a copy of schema without x-pd-* keywords, which strict
+// tool schemas reject
+func stripExtensions(schema *connect.JSONSchema) *connect.JSONSchema {
+	if schema == nil {
+		return nil
+	}
+
+	clone := *schema
+	clone.PropType = ""
+	clone.App = ""
+	clone.RemoteOptions = false
+	clone.UseQuery = false
+	clone.ReloadProps = false
+	clone.Items = stripExtensions(schema.Items)
+	if schema.Properties != nil {
+		clone.Properties = maps.Clone(schema.Properties)
+		for name, property := range clone.Properties {
+			clone.Properties[name] = stripExtensions(property)
+		}
+	}
+	return &clone
+}
+
+// OpenAITool is a tool in the format of the OpenAI chat completions API
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+type OpenAIFunction struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Parameters  *connect.JSONSchema `json:"parameters"`
+}
+
+// AnthropicTool is a tool in the format of the Anthropic messages API
+type AnthropicTool struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	InputSchema *connect.JSONSchema `json:"input_schema"`
+}
+
+func (t *Tool) OpenAI() OpenAITool {
+	return OpenAITool{
+		Type: "function",
+		Function: OpenAIFunction{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.InputSchema,
+		},
+	}
+}
+
+func (t *Tool) Anthropic() AnthropicTool {
+	return AnthropicTool{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: t.InputSchema,
+	}
+}
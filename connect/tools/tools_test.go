@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudsquid/pipedream-go-sdk/client"
+	"github.com/cloudsquid/pipedream-go-sdk/connect"
+	"github.com/stretchr/testify/suite"
+)
+
+const oathPath = "/oauth/token"
+
+type toolsTestSuite struct {
+	suite.Suite
+	ctx             context.Context
+	pipedreamClient *connect.Client
+	component       *connect.ComponentDetails
+	accounts        string
+	invoked         []connect.InvokeActionRequest
+}
+
+func (suite *toolsTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.invoked = nil
+	remote := true
+	suite.component = &connect.ComponentDetails{
+		Component: connect.Component{
+			Key:         "slack-send-message",
+			Name:        "Send Message",
+			Description: "Send a message to a channel",
+		},
+		ConfigurableProps: []*connect.ConfigurableProp{
+			{Name: "slack", Type: connect.PropTypeApp, App: "slack"},
+			{Name: "channel", Type: connect.PropTypeString, Label: "Channel", RemoteOptions: &remote},
+			{Name: "text", Type: connect.PropTypeString, Label: "Text"},
+			{Name: "mrkdwn", Type: connect.PropTypeBoolean, Optional: true},
+		},
+	}
+	suite.accounts = `{"data": [
+		{"id": "apn_dead", "healthy": false, "dead": true, "updated_at": "2025-03-01T00:00:00Z"},
+		{"id": "apn_old", "healthy": true, "updated_at": "2025-01-01T00:00:00Z"},
+		{"id": "apn_new", "healthy": true, "updated_at": "2025-02-01T00:00:00Z"}
+	]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == oathPath:
+			_, _ = fmt.Fprint(w, `{"access_token": "new-access-token", "expires_in": 3600}`)
+		case r.URL.Path == "/project-abc/actions/slack-send-message":
+			_ = json.NewEncoder(w).Encode(connect.GetComponentResponse{Data: suite.component})
+		case r.URL.Path == "/project-abc/accounts":
+			suite.Require().Equal("jverce", r.URL.Query().Get("external_user_id"))
+			suite.Require().Equal("slack", r.URL.Query().Get("app"))
+			_, _ = fmt.Fprint(w, suite.accounts)
+		case r.URL.Path == "/project-abc/actions/run":
+			body, err := io.ReadAll(r.Body)
+			suite.Require().NoError(err)
+			var request connect.InvokeActionRequest
+			suite.Require().NoError(json.Unmarshal(body, &request))
+			suite.invoked = append(suite.invoked, request)
+			_, _ = fmt.Fprint(w, `{"exports": {"$summary": "Sent message"}, "ret": {"ok": true}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	suite.T().Cleanup(server.Close)
+
+	base := client.NewClient("", "project-abc", "development", "", "", nil, server.URL, server.URL)
+	suite.pipedreamClient = &connect.Client{Client: base}
+}
+
+func (suite *toolsTestSuite) TestFromComponent() {
+	require := suite.Require()
+
+	tool, err := FromComponent(suite.component)
+	require.NoError(err)
+	require.Equal("slack-send-message", tool.Name)
+	require.Equal("Send Message: Send a message to a channel", tool.Description)
+	require.Equal([]AppProp{{Name: "slack", App: "slack"}}, tool.AppProps)
+
+	bs, err := json.Marshal(tool.OpenAI())
+	require.NoError(err)
+	require.JSONEq(`{
+		"type": "function",
+		"function": {
+			"name": "slack-send-message",
+			"description": "Send Message: Send a message to a channel",
+			"parameters": {
+				"type": "object",
+				"additionalProperties": false,
+				"required": ["channel", "text"],
+				"properties": {
+					"channel": {"type": "string", "title": "Channel"},
+					"text": {"type": "string", "title": "Text"},
+					"mrkdwn": {"type": "boolean"}
+				}
+			}
+		}
+	}`, string(bs))
+
+	bs, err = json.Marshal(tool.Anthropic())
+	require.NoError(err)
+	require.Contains(string(bs), `"input_schema":{`)
+	require.NotContains(string(bs), "x-pd-")
+
+	// the component keeps its extension keywords
+	require.True(suite.component.JSONSchema().Properties["channel"].RemoteOptions)
+}
+
+func (suite *toolsTestSuite) TestToolName() {
+	require := suite.Require()
+
+	require.Equal("google_sheets-add-single-row", ToolName("google_sheets-add-single-row"))
+	require.Regexp(`^a_b_c_[0-9a-f]{8}$`, ToolName("a.b c"))
+	require.NotEqual(ToolName("a.b c"), ToolName("a b.c"))
+
+	long := ToolName(strings.Repeat("x", 100))
+	require.Len(long, maxNameLength)
+	require.NotEqual(long, ToolName(strings.Repeat("x", 101)))
+}
+
+func (suite *toolsTestSuite) TestDispatcher_Conflict() {
+	require := suite.Require()
+
+	tool, err := FromComponent(suite.component)
+	require.NoError(err)
+	dispatcher, err := NewDispatcher(suite.pipedreamClient, tool, tool)
+	require.NoError(err)
+
+	other := *tool
+	other.ComponentKey = "slack-send-message-v2"
+	require.ErrorIs(dispatcher.Add(&other), ToolConflictErr)
+	_, err = NewDispatcher(suite.pipedreamClient, tool, &other)
+	require.ErrorIs(err, ToolConflictErr)
+}
+
+func (suite *toolsTestSuite) TestDispatch() {
+	require := suite.Require()
+
+	dispatcher, err := NewDispatcher(suite.pipedreamClient)
+	require.NoError(err)
+	require.NoError(dispatcher.Load(suite.ctx, "slack-send-message"))
+	require.Len(dispatcher.OpenAITools(), 1)
+	require.Len(dispatcher.AnthropicTools(), 1)
+
+	result, err := dispatcher.Dispatch(suite.ctx, "jverce", "slack-send-message",
+		json.RawMessage(`{"channel": "C123", "text": "hi"}`))
+	require.NoError(err)
+	require.JSONEq(`{"ok": true}`, string(result.Ret))
+
+	require.Len(suite.invoked, 1)
+	require.Equal("slack-send-message", suite.invoked[0].ID)
+	require.Equal("jverce", suite.invoked[0].ExternalUserID)
+	require.Equal(connect.ConfiguredProps{
+		"slack":   map[string]any{"authProvisionId": "apn_new"},
+		"channel": "C123",
+		"text":    "hi",
+	}, suite.invoked[0].ConfiguredProps)
+}
+
+func (suite *toolsTestSuite) TestDispatch_Errors() {
+	require := suite.Require()
+
+	tool, err := FromComponent(suite.component)
+	require.NoError(err)
+	dispatcher, err := NewDispatcher(suite.pipedreamClient, tool)
+	require.NoError(err)
+
+	_, err = dispatcher.Dispatch(suite.ctx, "jverce", "jira-create-issue", nil)
+	require.ErrorIs(err, UnknownToolErr)
+
+	_, err = dispatcher.Dispatch(suite.ctx, "jverce", "slack-send-message", json.RawMessage(`{"channel": 1}`))
+	require.ErrorIs(err, connect.InvalidPropsErr)
+
+	// the model can't pick the account
+	_, err = dispatcher.Dispatch(suite.ctx, "jverce", "slack-send-message",
+		json.RawMessage(`{"channel": "C1", "text": "hi", "slack": {"authProvisionId": "apn_x"}}`))
+	var validationErr *connect.PropValidationError
+	require.True(errors.As(err, &validationErr))
+	_, ok := validationErr.Field("slack")
+	require.True(ok)
+
+	suite.accounts = `{"data": [{"id": "apn_dead", "dead": true}]}`
+	_, err = dispatcher.Dispatch(suite.ctx, "jverce", "slack-send-message",
+		json.RawMessage(`{"channel": "C1", "text": "hi"}`))
+	require.ErrorIs(err, NoHealthyAccountErr)
+	require.Empty(suite.invoked)
+}
+
+func TestTools(t *testing.T) {
+	suite.Run(t, new(toolsTestSuite))
+}
@@ -18,6 +18,8 @@ type Trigger struct {
 	ID                string             `json:"id"`
 	OwnerID           string             `json:"owner_id"`
 	ComponentID       string             `json:"component_id"`
+	ComponentKey      string             `json:"component_key,omitempty"`
+	ComponentVersion  string             `json:"component_version,omitempty"`
 	ConfigurableProps []ConfigurableProp `json:"configurable_props,omitempty"`
 	ConfiguredProps   ConfiguredProps    `json:"configured_props,omitempty"`
 	Active            bool               `json:"active,omitempty"`
@@ -109,6 +111,17 @@ func (c *Client) DeployTrigger(
 	dynamicPropsID string, // OPTIONAL
 	workflowID string, // OPTIONAL
 ) (*Trigger, error) {
+	if err := c.checkPolicy(ctx, componentPolicyRequest(PolicyDeployTrigger, componentKey, externalUserID)); err != nil {
+		return nil, err
+	}
+
+	if c.ValidateProps {
+		err := c.validateComponentProps(ctx, Triggers, componentKey, configuredProps, dynamicPropsID != "")
+		if err != nil {
+			return nil, fmt.Errorf("validating props for trigger %s: %w", componentKey, err)
+		}
+	}
+
 	baseURL := c.ConnectURL().ResolveReference(&url.URL{
 		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "triggers", "deploy"),
 	})
@@ -165,12 +178,42 @@ func (c *Client) ListDeployedTriggers(
 	ctx context.Context,
 	externalUserID string,
 ) (*TriggerList, error) {
-	baseURL := c.ConnectURL().ResolveReference(&url.URL{
-		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "deployed-triggers"),
-	})
+	queryParams := url.Values{}
+	internal.AddQueryParams(queryParams, "external_user_id", externalUserID)
 
+	return c.listDeployedTriggers(ctx, queryParams)
+}
+
+// ListAllDeployedTriggers follows the page cursors of ListDeployedTriggers and returns every trigger
+func (c *Client) ListAllDeployedTriggers(
+	ctx context.Context,
+	externalUserID string,
+) ([]Trigger, error) {
 	queryParams := url.Values{}
 	internal.AddQueryParams(queryParams, "external_user_id", externalUserID)
+
+	var triggers []Trigger
+	for {
+		page, err := c.listDeployedTriggers(ctx, queryParams)
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, page.Data...)
+
+		if !hasNextPage(page.PageInfo, len(page.Data), len(triggers), queryParams.Get("after")) {
+			return triggers, nil
+		}
+		queryParams.Set("after", page.PageInfo.EndCursor)
+	}
+}
+
+func (c *Client) listDeployedTriggers(
+	ctx context.Context,
+	queryParams url.Values,
+) (*TriggerList, error) {
+	baseURL := c.ConnectURL().ResolveReference(&url.URL{
+		Path: path.Join(c.ConnectURL().Path, c.ProjectID(), "deployed-triggers"),
+	})
 	baseURL.RawQuery = queryParams.Encode()
 
 	req, err := http.NewRequest(http.MethodGet, baseURL.String(), nil)
@@ -29,3 +29,15 @@ func (c *Client) doRequestViaOauth(
 
 	return response, nil
 }
+
+// hasNextPage reports whether a cursor paginated listing has more pages after the
+// current one. Servers that keep returning the cursor they were given are treated as done
+func hasNextPage(info PageInfo, pageLen int, total int, after string) bool {
+	if pageLen == 0 || info.EndCursor == "" || info.EndCursor == after {
+		return false
+	}
+	if info.TotalCount > 0 && total >= info.TotalCount {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,418 @@
+package connect
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type WebhookEventType string
+
+const (
+	ConnectionSuccess WebhookEventType = "CONNECTION_SUCCESS"
+	ConnectionError   WebhookEventType = "CONNECTION_ERROR"
+)
+
+const (
+	WebhookTimestampHeader = "X-PD-Timestamp"
+	WebhookSignatureHeader = "X-PD-Signature"
+	WebhookEventIDHeader   = "X-PD-Event-ID"
+	WebhookSecretHeader    = "X-PD-Webhook-Secret"
+)
+
+var (
+	WebhookVerificationErr error = errors.New("webhook verification failed")
+	WebhookReplayErr       error = errors.New("webhook delivery was already processed or is too old")
+	WebhookInFlightErr     error = errors.New("webhook delivery is being processed")
+)
+
+// DefaultWebhookTolerance is the timestamp tolerance of handlers that set none
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// ConnectionEvent is the payload Pipedream posts to the webhookURI passed to AcquireUserToken
+// https://pipedream.com/docs/connect/webhooks
+type ConnectionEvent struct {
+	Event            WebhookEventType `json:"event"`
+	ConnectToken     string           `json:"connect_token,omitempty"`
+	Environment      string           `json:"environment,omitempty"`
+	ConnectSessionID int64            `json:"connect_session_id,omitempty"`
+	Account          *Account         `json:"account,omitempty"`
+	Error            string           `json:"error,omitempty"`
+
+	// ExternalUserID is read from the payload when present, otherwise from the
+	// external_user_id query parameter of the webhook URI
+	ExternalUserID string `json:"external_user_id,omitempty"`
+
+	// ID and Timestamp are filled in by the handler and used for replay protection
+	ID        string    `json:"-"`
+	Timestamp time.Time `json:"-"`
+}
+
+// Err returns the connection error as a Go error for CONNECTION_ERROR events
+func (e *ConnectionEvent) Err() error {
+	if e.Event != ConnectionError {
+		return nil
+	}
+	if e.Error == "" {
+		return errors.New("connection failed")
+	}
+	return errors.New(e.Error)
+}
+
+type ConnectionEventFunc func(ctx context.Context, event *ConnectionEvent) error
+
+// WebhookVerifier authenticates an incoming webhook delivery before it is parsed
+type WebhookVerifier func(r *http.Request, body []byte) error
+
+// SharedSecretVerifier accepts deliveries carrying the secret either in the
+// X-PD-Webhook-Secret header or in the secret query parameter of the webhook URI
+func SharedSecretVerifier(secret string) WebhookVerifier {
+	return func(r *http.Request, body []byte) error {
+		got := r.Header.Get(WebhookSecretHeader)
+		if got == "" {
+			got = r.URL.Query().Get("secret")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			return fmt.Errorf("shared secret mismatch: %w", WebhookVerificationErr)
+		}
+		return nil
+	}
+}
+
+// HMACVerifier checks the X-PD-Signature header, the hex encoded HMAC-SHA256
+// of "<X-PD-Timestamp>.<body>" keyed with secret, optionally prefixed with "sha256="
+func HMACVerifier(secret string) WebhookVerifier {
+	return func(r *http.Request, body []byte) error {
+		signature := strings.TrimPrefix(r.Header.Get(WebhookSignatureHeader), "sha256=")
+		if signature == "" {
+			return fmt.Errorf("missing %s header: %w", WebhookSignatureHeader, WebhookVerificationErr)
+		}
+		got, err := hex.DecodeString(signature)
+		if err != nil {
+			return fmt.Errorf("decoding signature: %w", WebhookVerificationErr)
+		}
+		if !hmac.Equal(got, SignWebhookPayload(secret, r.Header.Get(WebhookTimestampHeader), body)) {
+			return fmt.Errorf("signature mismatch: %w", WebhookVerificationErr)
+		}
+		return nil
+	}
+}
+
+// SignWebhookPayload computes the signature checked by HMACVerifier
+func SignWebhookPayload(secret string, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+type ReplayStatus string
+
+const (
+	ReplayNew       ReplayStatus = "new"
+	ReplayInFlight  ReplayStatus = "in_flight"
+	ReplayProcessed ReplayStatus = "processed"
+)
+
+// ReplayStore remembers deliveries being processed and processed ones
+type ReplayStore interface {
+	// Claim records an unknown id as in flight until expiresAt and returns the
+	// status id had before
+	Claim(ctx context.Context, id string, expiresAt time.Time) (ReplayStatus, error)
+	// Complete marks a claimed id as processed
+	Complete(ctx context.Context, id string) error
+	// Forget removes id so that a retried delivery is processed again
+	Forget(ctx context.Context, id string) error
+}
+
+type memoryReplayStore struct {
+	mu   sync.Mutex
+	seen map[string]*replayEntry
+	now  func() time.Time
+}
+
+type replayEntry struct {
+	expiresAt time.Time
+	processed bool
+}
+
+// NewMemoryReplayStore returns an in-process ReplayStore, only suitable for a single replica
+func NewMemoryReplayStore() ReplayStore {
+	return &memoryReplayStore{seen: map[string]*replayEntry{}, now: time.Now}
+}
+
+func (s *memoryReplayStore) Claim(_ context.Context, id string, expiresAt time.Time) (ReplayStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, entry := range s.seen {
+		if now.After(entry.expiresAt) {
+			delete(s.seen, k)
+		}
+	}
+
+	if entry, ok := s.seen[id]; ok {
+		if entry.processed {
+			return ReplayProcessed, nil
+		}
+		return ReplayInFlight, nil
+	}
+	s.seen[id] = &replayEntry{expiresAt: expiresAt}
+	return ReplayNew, nil
+}
+
+func (s *memoryReplayStore) Complete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.seen[id]; ok {
+		entry.processed = true
+	}
+	return nil
+}
+
+func (s *memoryReplayStore) Forget(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seen, id)
+	return nil
+}
+
+// WebhookHandler receives Connect connection lifecycle webhooks
+type WebhookHandler struct {
+	// Verify is optional, see SharedSecretVerifier and HMACVerifier
+	Verify WebhookVerifier
+	// Tolerance rejects deliveries whose X-PD-Timestamp header is missing or
+	// further away from now. Zero means DefaultWebhookTolerance, a negative
+	// value disables the check
+	Tolerance time.Duration
+	// Replays is optional, when set every event ID is only dispatched once.
+	// Events whose dispatch failed are forgotten so that retries are processed
+	Replays ReplayStore
+	// MaxBodyBytes defaults to 1MB
+	MaxBodyBytes int64
+	// OnError is optional and receives the errors of failed dispatches, which
+	// are answered with a generic message
+	OnError func(error)
+
+	now       func() time.Time
+	mu        sync.RWMutex
+	onSuccess []ConnectionEventFunc
+	onError   []ConnectionEventFunc
+}
+
+func NewWebhookHandler(verify WebhookVerifier) *WebhookHandler {
+	return &WebhookHandler{
+		Verify:  verify,
+		Replays: NewMemoryReplayStore(),
+	}
+}
+
+// OnConnectionSuccess registers fn for CONNECTION_SUCCESS events
+func (h *WebhookHandler) OnConnectionSuccess(fn ConnectionEventFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onSuccess = append(h.onSuccess, fn)
+}
+
+// OnConnectionError registers fn for CONNECTION_ERROR events
+func (h *WebhookHandler) OnConnectionError(fn ConnectionEventFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onError = append(h.onError, fn)
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	event, err := h.Parse(r)
+	switch {
+	case errors.Is(err, WebhookVerificationErr):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, WebhookReplayErr):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, WebhookInFlightErr):
+		// the sender retries, the delivery in flight may still fail
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Dispatch(r.Context(), event); err != nil {
+		h.reportError(fmt.Errorf("dispatching webhook event %s: %w", event.ID, err))
+		http.Error(w, "processing webhook failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Parse verifies the request and decodes it into a ConnectionEvent. The event
+// ID is claimed in Replays, pass the event to Dispatch, which marks it as
+// processed or forgets it again if a callback fails
+func (h *WebhookHandler) Parse(r *http.Request) (*ConnectionEvent, error) {
+	limit := h.MaxBodyBytes
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading webhook body: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("webhook body exceeds %d bytes", limit)
+	}
+
+	if h.Verify != nil {
+		if err := h.Verify(r, body); err != nil {
+			return nil, err
+		}
+	}
+
+	var event ConnectionEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("decoding webhook body: %w", err)
+	}
+
+	switch event.Event {
+	case ConnectionSuccess, ConnectionError:
+	default:
+		return nil, fmt.Errorf("unknown webhook event %q", event.Event)
+	}
+
+	if event.ExternalUserID == "" {
+		event.ExternalUserID = r.URL.Query().Get("external_user_id")
+	}
+
+	if err := h.checkReplay(r, body, &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (h *WebhookHandler) checkReplay(r *http.Request, body []byte, event *ConnectionEvent) error {
+	now := time.Now()
+	if h.now != nil {
+		now = h.now()
+	}
+
+	if ts := r.Header.Get(WebhookTimestampHeader); ts != "" {
+		seconds, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s header %q: %w", WebhookTimestampHeader, ts, WebhookVerificationErr)
+		}
+		event.Timestamp = time.Unix(seconds, 0)
+	}
+
+	tolerance := h.tolerance()
+	if tolerance > 0 {
+		if event.Timestamp.IsZero() {
+			return fmt.Errorf("missing %s header: %w", WebhookTimestampHeader, WebhookReplayErr)
+		}
+		if skew := now.Sub(event.Timestamp).Abs(); skew > tolerance {
+			return fmt.Errorf("timestamp is %s away from now: %w", skew, WebhookReplayErr)
+		}
+	}
+
+	event.ID = r.Header.Get(WebhookEventIDHeader)
+	if event.ID == "" && event.ConnectSessionID != 0 {
+		// a connect session ends with exactly one event
+		event.ID = fmt.Sprintf("%s:%d", event.Event, event.ConnectSessionID)
+	}
+	if event.ID == "" {
+		sum := sha256.Sum256(body)
+		event.ID = hex.EncodeToString(sum[:])
+	}
+
+	if h.Replays == nil {
+		return nil
+	}
+
+	window := tolerance
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	status, err := h.Replays.Claim(r.Context(), event.ID, now.Add(2*window))
+	if err != nil {
+		return fmt.Errorf("checking replay store: %w", err)
+	}
+	switch status {
+	case ReplayInFlight:
+		return fmt.Errorf("event %s: %w", event.ID, WebhookInFlightErr)
+	case ReplayProcessed:
+		return fmt.Errorf("event %s: %w", event.ID, WebhookReplayErr)
+	}
+	return nil
+}
+
+func (h *WebhookHandler) tolerance() time.Duration {
+	if h.Tolerance == 0 {
+		return DefaultWebhookTolerance
+	}
+	return h.Tolerance
+}
+
+func (h *WebhookHandler) reportError(err error) {
+	if h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+// Dispatch calls every callback registered for the event type and joins their
+// errors. The event ID is then marked as processed in Replays, or removed again
+// on error
+func (h *WebhookHandler) Dispatch(ctx context.Context, event *ConnectionEvent) error {
+	h.mu.RLock()
+	var callbacks []ConnectionEventFunc
+	switch event.Event {
+	case ConnectionSuccess:
+		callbacks = append(callbacks, h.onSuccess...)
+	case ConnectionError:
+		callbacks = append(callbacks, h.onError...)
+	}
+	h.mu.RUnlock()
+
+	var errs []error
+	for _, fn := range callbacks {
+		if err := fn(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if h.Replays != nil && event.ID != "" {
+		ctx := context.WithoutCancel(ctx)
+		if len(errs) > 0 {
+			if err := h.Replays.Forget(ctx, event.ID); err != nil {
+				errs = append(errs, fmt.Errorf("forgetting event %s in replay store: %w", event.ID, err))
+			}
+		} else if err := h.Replays.Complete(ctx, event.ID); err != nil {
+			// the callbacks succeeded, a retry would repeat them
+			h.reportError(fmt.Errorf("completing event %s in replay store: %w", event.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
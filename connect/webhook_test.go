@@ -0,0 +1,249 @@
+package connect
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type webhookTestSuite struct {
+	suite.Suite
+	ctx context.Context
+	now time.Time
+}
+
+func (suite *webhookTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.now = time.Now().UTC().Truncate(time.Second)
+}
+
+const successPayload = `{
+	"event": "CONNECTION_SUCCESS",
+	"connect_token": "ctok_abc",
+	"environment": "development",
+	"connect_session_id": 123,
+	"account": {
+		"id": "apn_XehyZPr",
+		"name": "My Slack workspace",
+		"healthy": true,
+		"app": {"id": "app_OkrhR1", "name_slug": "slack"}
+	}
+}`
+
+func (suite *webhookTestSuite) newRequest(body string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/pd?external_user_id=user-123", strings.NewReader(body))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(suite.now.Unix(), 10))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func (suite *webhookTestSuite) newHandler(verify WebhookVerifier) *WebhookHandler {
+	h := NewWebhookHandler(verify)
+	h.now = func() time.Time { return suite.now }
+	return h
+}
+
+func (suite *webhookTestSuite) TestServeHTTP_DispatchesSuccess() {
+	require := suite.Require()
+	h := suite.newHandler(nil)
+
+	var got *ConnectionEvent
+	h.OnConnectionSuccess(func(ctx context.Context, event *ConnectionEvent) error {
+		got = event
+		return nil
+	})
+	h.OnConnectionError(func(ctx context.Context, event *ConnectionEvent) error {
+		suite.Fail("error callback must not be called")
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+
+	require.Equal(http.StatusNoContent, rec.Code)
+	require.NotNil(got)
+	require.Equal("user-123", got.ExternalUserID)
+	require.Equal("apn_XehyZPr", got.Account.ID)
+	require.Equal("slack", got.Account.App.NameSlug)
+	require.Equal("CONNECTION_SUCCESS:123", got.ID)
+	require.NoError(got.Err())
+}
+
+func (suite *webhookTestSuite) TestServeHTTP_DispatchesError() {
+	require := suite.Require()
+	h := suite.newHandler(nil)
+
+	var got *ConnectionEvent
+	h.OnConnectionError(func(ctx context.Context, event *ConnectionEvent) error {
+		got = event
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(`{
+		"event": "CONNECTION_ERROR",
+		"connect_session_id": 124,
+		"external_user_id": "user-456",
+		"error": "account limit reached"
+	}`, nil))
+
+	require.Equal(http.StatusNoContent, rec.Code)
+	require.Equal("user-456", got.ExternalUserID)
+	require.EqualError(got.Err(), "account limit reached")
+}
+
+func (suite *webhookTestSuite) TestServeHTTP_RejectsReplay() {
+	require := suite.Require()
+	h := suite.newHandler(nil)
+
+	calls := 0
+	h.OnConnectionSuccess(func(ctx context.Context, event *ConnectionEvent) error {
+		calls++
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+	require.Equal(http.StatusNoContent, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+	require.Equal(http.StatusConflict, rec.Code)
+	require.Equal(1, calls)
+}
+
+func (suite *webhookTestSuite) TestServeHTTP_RetriesFailedDispatch() {
+	require := suite.Require()
+	h := suite.newHandler(nil)
+
+	calls := 0
+	h.OnConnectionSuccess(func(ctx context.Context, event *ConnectionEvent) error {
+		calls++
+		if calls == 1 {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+
+	var reported []error
+	h.OnError = func(err error) { reported = append(reported, err) }
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+	require.Equal(http.StatusInternalServerError, rec.Code)
+	// the error of the callback is not sent back
+	require.NotContains(rec.Body.String(), "database")
+	require.Len(reported, 1)
+	require.ErrorContains(reported[0], "database unavailable")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+	require.Equal(http.StatusNoContent, rec.Code)
+	require.Equal(2, calls)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+	require.Equal(http.StatusConflict, rec.Code)
+}
+
+func (suite *webhookTestSuite) TestServeHTTP_DuplicateInFlight() {
+	require := suite.Require()
+	h := suite.newHandler(nil)
+
+	calls := 0
+	h.OnConnectionSuccess(func(ctx context.Context, event *ConnectionEvent) error {
+		calls++
+		if calls == 1 {
+			// a duplicate arrives while the first delivery is processed
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+			require.Equal(http.StatusServiceUnavailable, rec.Code)
+			require.NotEmpty(rec.Header().Get("Retry-After"))
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+	require.Equal(http.StatusInternalServerError, rec.Code)
+
+	// the retry of the duplicate is processed
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, suite.newRequest(successPayload, nil))
+	require.Equal(http.StatusNoContent, rec.Code)
+	require.Equal(2, calls)
+}
+
+func (suite *webhookTestSuite) TestParse_TimestampRequired() {
+	require := suite.Require()
+	h := suite.newHandler(nil)
+
+	req := suite.newRequest(successPayload, nil)
+	req.Header.Del(WebhookTimestampHeader)
+	_, err := h.Parse(req)
+	require.ErrorIs(err, WebhookReplayErr)
+
+	// callers opt out with a negative tolerance
+	h.Tolerance = -1
+	req = suite.newRequest(`{"event": "CONNECTION_SUCCESS", "connect_session_id": 125}`, nil)
+	req.Header.Del(WebhookTimestampHeader)
+	event, err := h.Parse(req)
+	require.NoError(err)
+	require.True(event.Timestamp.IsZero())
+}
+
+func (suite *webhookTestSuite) TestParse_RejectsStaleTimestamp() {
+	require := suite.Require()
+	h := suite.newHandler(nil)
+
+	req := suite.newRequest(successPayload, map[string]string{
+		WebhookTimestampHeader: strconv.FormatInt(suite.now.Add(-time.Hour).Unix(), 10),
+	})
+
+	_, err := h.Parse(req)
+	require.True(errors.Is(err, WebhookReplayErr))
+}
+
+func (suite *webhookTestSuite) TestParse_SharedSecret() {
+	require := suite.Require()
+	h := suite.newHandler(SharedSecretVerifier("s3cret"))
+
+	_, err := h.Parse(suite.newRequest(successPayload, map[string]string{WebhookSecretHeader: "wrong"}))
+	require.True(errors.Is(err, WebhookVerificationErr))
+
+	_, err = h.Parse(suite.newRequest(successPayload, map[string]string{WebhookSecretHeader: "s3cret"}))
+	require.NoError(err)
+}
+
+func (suite *webhookTestSuite) TestParse_HMACSignature() {
+	require := suite.Require()
+	h := suite.newHandler(HMACVerifier("s3cret"))
+	ts := strconv.FormatInt(suite.now.Unix(), 10)
+	signature := hex.EncodeToString(SignWebhookPayload("s3cret", ts, []byte(successPayload)))
+
+	_, err := h.Parse(suite.newRequest(successPayload, map[string]string{
+		WebhookSignatureHeader: "sha256=" + strings.Repeat("0", len(signature)),
+	}))
+	require.True(errors.Is(err, WebhookVerificationErr))
+
+	event, err := h.Parse(suite.newRequest(successPayload, map[string]string{
+		WebhookSignatureHeader: "sha256=" + signature,
+	}))
+	require.NoError(err)
+	require.Equal(suite.now, event.Timestamp.UTC())
+}
+
+func TestWebhook(t *testing.T) {
+	suite.Run(t, new(webhookTestSuite))
+}
@@ -33,8 +33,7 @@ func UnmarshalResponse(response *http.Response, result any, okStatusCodes ...int
 			compact.WriteString("[non-JSON body]")
 		}
 
-		statusErr := fmt.Errorf("unexpected status code: %d, body: %s",
-			response.StatusCode, compact.String())
+		statusErr := &StatusError{StatusCode: response.StatusCode, Body: compact.String()}
 		if err != nil {
 			return errors.Join(statusErr, err)
 		}
@@ -44,6 +43,16 @@ func UnmarshalResponse(response *http.Response, result any, okStatusCodes ...int
 	return err
 }
 
+// StatusError is returned by UnmarshalResponse for unexpected status codes
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, body: %s", e.StatusCode, e.Body)
+}
+
 func AddQueryParams(params url.Values, key, value string) {
 	if value != "" {
 		params.Add(key, value)